@@ -4,13 +4,52 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/deadline"
 	"rajomon-gateway/internal/handler"
+	"rajomon-gateway/internal/metrics"
+	"rajomon-gateway/internal/middleware"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	// 1. 注册路由 (只负责处理 MCP 业务，不负责治理)
-	http.HandleFunc("/mcp/chat", handler.HandleMCP)
+	// 0. 后端治理模式 (可选)：RAJOMON_GOVERNANCE=1 时后端也运行自己的 Rajomon 控制器，
+	// 在 Price 响应头里把自身价格广播给上游网关 (多跳价格传播)，并按 Token 头做自己的准入
+	// (网关启用账户时，不带出价的请求由网关把实际出价写进 Token 头再转发)
+	// 网关转发的剩余超时预算 (X-Request-Timeout / grpc-timeout) 总是生效
+	govern := func(h http.Handler, opts ...middleware.Option) http.Handler { return deadline.Handler(h) }
+	if os.Getenv("RAJOMON_GOVERNANCE") == "1" {
+		metrics.Init()
+		http.Handle("/metrics", promhttp.Handler())
+		ctrl := controller.NewController()
+		govern = func(h http.Handler, opts ...middleware.Option) http.Handler {
+			return deadline.Handler(middleware.RajomonMiddleware(ctrl, h, opts...))
+		}
+		fmt.Println("🛡️ 后端治理模式已开启，价格将通过 Price 头广播给上游")
+	}
+
+	// 1. 注册路由 (默认只负责处理 MCP 业务，不负责治理)
+	http.Handle("/mcp/chat", govern(http.HandlerFunc(handler.HandleMCP)))
+
+	// 1.1 标准 MCP 端点 (JSON-RPC 2.0 over Streamable HTTP)
+	// MCP_CONFIG 指向 JSON 文件时，按文件配置工具的耗时/Token/错误率
+	mcpCfg := handler.DefaultMCPServerConfig()
+	if path := os.Getenv("MCP_CONFIG"); path != "" {
+		var err error
+		if mcpCfg, err = handler.LoadMCPServerConfig(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// 只有配置里的工具单独定价，客户端随便写的工具名归入 /mcp:other
+	toolKeys := make([]string, len(mcpCfg.Tools))
+	for i, tool := range mcpCfg.Tools {
+		toolKeys[i] = "/mcp:tools/call/" + tool.Name
+	}
+	http.Handle("/mcp", govern(handler.NewMCPServer(mcpCfg), middleware.WithKeyFunc(middleware.AllowKeys(middleware.ToolKey, toolKeys))))
+	fmt.Printf("🧰 MCP 端点 /mcp 已就绪，工具数: %d\n", len(mcpCfg.Tools))
 
 	// 2. 启动服务 (监听 9001，Docker 内部端口)
 	// 注意：在 Docker 里我们通常让它监听 :8080，通过端口映射区分
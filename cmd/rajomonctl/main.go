@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"rajomon-gateway/internal/admin"
+	"rajomon-gateway/internal/controller"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const usage = `rajomonctl 通过管理接口 (admin_listen) 查看和干预网关
+
+用法:
+  rajomonctl prices list
+  rajomonctl prices pin|floor|ceil <key> <price> [--for 10m] [--reason "..."]
+  rajomonctl prices unpin <key> [--kind pin|floor|ceil]
+  rajomonctl prices reset <key>
+  rajomonctl backends list
+  rajomonctl backends drain|enable <host:port> [--pool <name>]
+  rajomonctl config reload
+  rajomonctl watch [--interval 1s]
+
+通用参数 (可以写在任意位置):
+  --addr     管理接口地址 (默认 $RAJOMON_ADMIN_ADDR 或 http://localhost:9090)
+  --token    Bearer Token (默认 $RAJOMON_ADMIN_TOKEN)
+  -o         输出格式 table / json (默认 table)
+`
+
+// 运维命令行
+//
+//	export RAJOMON_ADMIN_ADDR=http://gateway:9090 RAJOMON_ADMIN_TOKEN=...
+//	rajomonctl prices pin /mcp:tools/call/web_search 50 --for 10m --reason "故障演练"
+//	rajomonctl -o json prices list | jq '.[] | select(.price > 20)'
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	os.Exit(exitCode(run(os.Args[1:], os.Stdout), os.Stderr))
+}
+
+// exitCode 把 run 的错误打印到 stderr 并换算成退出码：成功或 --help 为 0，用法错误为 2，其余为 1
+func exitCode(err error, stderr io.Writer) int {
+	var ue usageError
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		fmt.Fprint(stderr, usage)
+	case errors.As(err, &ue):
+		fmt.Fprintf(stderr, "❌ %v\n\n%s", err, usage)
+		return 2
+	case err != nil:
+		fmt.Fprintf(stderr, "❌ %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// usageError 命令行用法错误 (退出码 2)，其余错误为接口调用失败 (退出码 1)
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// command 一个子命令的参数：通用参数 + 子命令自己的参数
+type command struct {
+	fs     *flag.FlagSet
+	addr   string
+	token  string
+	output string
+}
+
+func newCommand(name string) *command {
+	c := &command{fs: flag.NewFlagSet(name, flag.ContinueOnError)}
+	c.fs.SetOutput(io.Discard)
+	addr := os.Getenv("RAJOMON_ADMIN_ADDR")
+	if addr == "" {
+		addr = "http://localhost:9090"
+	}
+	c.fs.StringVar(&c.addr, "addr", addr, "管理接口地址")
+	c.fs.StringVar(&c.token, "token", os.Getenv("RAJOMON_ADMIN_TOKEN"), "Bearer Token")
+	c.fs.StringVar(&c.output, "o", outputTable, "输出格式 table / json")
+	c.fs.StringVar(&c.output, "output", outputTable, "输出格式 table / json")
+	return c
+}
+
+// parse 解析参数，允许参数和位置参数交错 ("pin <key> <price> --for 10m")
+func (c *command) parse(args []string, want int) ([]string, *client, error) {
+	var positional []string
+	for {
+		if err := c.fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, nil, err
+			}
+			return nil, nil, usageError(err.Error())
+		}
+		if c.fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, c.fs.Arg(0))
+		args = c.fs.Args()[1:]
+	}
+	if len(positional) != want {
+		return nil, nil, usageError(fmt.Sprintf("%s 需要 %d 个参数，实际 %d 个", c.fs.Name(), want, len(positional)))
+	}
+	if c.output != outputTable && c.output != outputJSON {
+		return nil, nil, usageError(fmt.Sprintf("未知的输出格式 %q (table / json)", c.output))
+	}
+	if c.token == "" {
+		return nil, nil, usageError("缺少 Token：使用 --token 或设置 RAJOMON_ADMIN_TOKEN")
+	}
+	return positional, newClient(c.addr, c.token), nil
+}
+
+func run(args []string, w io.Writer) error {
+	// 写在命令前面的通用参数 (都带值) 挪到子命令的参数里统一解析
+	var global []string
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		global, args = append(global, args[0]), args[1:]
+		if !strings.Contains(global[len(global)-1], "=") && len(args) > 0 {
+			global, args = append(global, args[0]), args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return usageError("缺少命令")
+	}
+	rest := append(args[1:len(args):len(args)], global...)
+
+	switch group := args[0]; group {
+	case "prices":
+		return runPrices(rest, w)
+	case "backends":
+		return runBackends(rest, w)
+	case "config":
+		if len(rest) == 0 || rest[0] != "reload" {
+			return usageError("config 只支持 reload")
+		}
+		return configReload(rest[1:], w)
+	case "watch":
+		return runWatch(rest, w)
+	case "-h", "--help", "help":
+		return flag.ErrHelp
+	default:
+		return usageError(fmt.Sprintf("未知的命令 %q", group))
+	}
+}
+
+func runPrices(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return usageError("prices 需要子命令: list / pin / floor / ceil / unpin / reset")
+	}
+	switch sub, rest := args[0], args[1:]; sub {
+	case "list":
+		return pricesList(rest, w)
+	case controller.OverridePin, controller.OverrideFloor, controller.OverrideCeil:
+		return pricesOverride(sub, rest, w)
+	case "unpin":
+		return pricesUnpin(rest, w)
+	case "reset":
+		return pricesReset(rest, w)
+	default:
+		return usageError(fmt.Sprintf("未知的 prices 子命令 %q", sub))
+	}
+}
+
+func pricesList(args []string, w io.Writer) error {
+	cmd := newCommand("prices list")
+	_, c, err := cmd.parse(args, 0)
+	if err != nil {
+		return err
+	}
+	var keys []controller.KeyInfo
+	if err := c.do(http.MethodGet, "/admin/keys", nil, &keys); err != nil {
+		return err
+	}
+	if cmd.output == outputJSON {
+		return printJSON(w, keys)
+	}
+	printKeys(w, keys)
+	return nil
+}
+
+func pricesOverride(kind string, args []string, w io.Writer) error {
+	cmd := newCommand("prices " + kind)
+	dur := cmd.fs.Duration("for", 0, "有效期 (不写表示一直生效直到 unpin)")
+	reason := cmd.fs.String("reason", "", "干预原因 (写入审计日志)")
+	pos, c, err := cmd.parse(args, 2)
+	if err != nil {
+		return err
+	}
+	price, err := strconv.Atoi(pos[1])
+	if err != nil {
+		return usageError(fmt.Sprintf("价格不是整数: %q", pos[1]))
+	}
+
+	req := map[string]any{"key": pos[0], "kind": kind, "price": price, "reason": *reason}
+	if *dur > 0 {
+		req["for"] = dur.String()
+	}
+	var o controller.Override
+	if err := c.do(http.MethodPost, "/admin/overrides", req, &o); err != nil {
+		return err
+	}
+	if cmd.output == outputJSON {
+		return printJSON(w, o)
+	}
+	until := "撤销前一直生效"
+	if !o.Expires.IsZero() {
+		until = "有效至 " + o.Expires.Local().Format(time.DateTime)
+	}
+	fmt.Fprintf(w, "📌 %s %s=%d | %s\n", pos[0], o.Kind, o.Price, until)
+	return nil
+}
+
+func pricesUnpin(args []string, w io.Writer) error {
+	cmd := newCommand("prices unpin")
+	kind := cmd.fs.String("kind", "", "只撤销某一种干预 (pin / floor / ceil)，默认全部")
+	pos, c, err := cmd.parse(args, 1)
+	if err != nil {
+		return err
+	}
+	q := url.Values{"key": {pos[0]}}
+	if *kind != "" {
+		q.Set("kind", *kind)
+	}
+	if err := c.do(http.MethodDelete, "/admin/overrides?"+q.Encode(), nil, nil); err != nil {
+		return err
+	}
+	return report(w, cmd.output, fmt.Sprintf("✅ %s 的价格干预已撤销", pos[0]))
+}
+
+func pricesReset(args []string, w io.Writer) error {
+	cmd := newCommand("prices reset")
+	pos, c, err := cmd.parse(args, 1)
+	if err != nil {
+		return err
+	}
+	if err := c.do(http.MethodPost, "/admin/keys/reset", map[string]string{"key": pos[0]}, nil); err != nil {
+		return err
+	}
+	return report(w, cmd.output, fmt.Sprintf("♻️ %s 的定价状态已重置", pos[0]))
+}
+
+func runBackends(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return usageError("backends 需要子命令: list / drain / enable")
+	}
+	switch sub, rest := args[0], args[1:]; sub {
+	case "list":
+		cmd := newCommand("backends list")
+		_, c, err := cmd.parse(rest, 0)
+		if err != nil {
+			return err
+		}
+		var backends []admin.BackendInfo
+		if err := c.do(http.MethodGet, "/admin/backends", nil, &backends); err != nil {
+			return err
+		}
+		if cmd.output == outputJSON {
+			return printJSON(w, backends)
+		}
+		printBackends(w, backends)
+		return nil
+	case "drain", "enable":
+		cmd := newCommand("backends " + sub)
+		pool := cmd.fs.String("pool", "", "只作用于指定的后端池，默认所有包含该后端的池")
+		pos, c, err := cmd.parse(rest, 1)
+		if err != nil {
+			return err
+		}
+		var backends []admin.BackendInfo
+		req := map[string]string{"backend": pos[0], "pool": *pool}
+		if err := c.do(http.MethodPost, "/admin/backends/"+sub, req, &backends); err != nil {
+			return err
+		}
+		if cmd.output == outputJSON {
+			return printJSON(w, backends)
+		}
+		printBackends(w, backends)
+		return nil
+	default:
+		return usageError(fmt.Sprintf("未知的 backends 子命令 %q", sub))
+	}
+}
+
+func configReload(args []string, w io.Writer) error {
+	cmd := newCommand("config reload")
+	_, c, err := cmd.parse(args, 0)
+	if err != nil {
+		return err
+	}
+	var result map[string]int
+	if err := c.do(http.MethodPost, "/admin/config/reload", struct{}{}, &result); err != nil {
+		return err
+	}
+	if cmd.output == outputJSON {
+		return printJSON(w, result)
+	}
+	fmt.Fprintf(w, "🔄 配置已重新加载 | 路由: %d | 后端池: %d\n", result["routes"], result["pools"])
+	return nil
+}
+
+func runWatch(args []string, w io.Writer) error {
+	cmd := newCommand("watch")
+	interval := cmd.fs.Duration("interval", time.Second, "轮询周期")
+	_, c, err := cmd.parse(args, 0)
+	if err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return usageError("--interval 必须大于 0")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return watch(ctx, c, w, *interval, cmd.output)
+}
+
+// report 没有返回体的操作：表格模式打印提示，JSON 模式输出 {"ok": true}
+func report(w io.Writer, output, msg string) error {
+	if output == outputJSON {
+		return printJSON(w, map[string]bool{"ok": true})
+	}
+	fmt.Fprintln(w, msg)
+	return nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/admin"
+	"rajomon-gateway/internal/controller"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testToken = "t0k"
+
+// adminCall 假管理接口收到的一次请求
+type adminCall struct {
+	method, uri string
+	body        map[string]any
+}
+
+// fakeAdmin 模拟网关的管理接口，记录收到的请求
+type fakeAdmin struct {
+	*httptest.Server
+	keys     []controller.KeyInfo
+	backends []admin.BackendInfo
+
+	mu    sync.Mutex
+	calls []adminCall
+}
+
+func newFakeAdmin(t *testing.T) *fakeAdmin {
+	f := &fakeAdmin{
+		keys: []controller.KeyInfo{
+			{Key: "/mcp", Policy: "composite", Price: 12, OwnPrice: 10, EWMALatency: 85.5, Cost: 3},
+			{Key: "/mcp:tools/call/web_search", Policy: "aimd", Price: 50, OwnPrice: 20,
+				Overrides: []controller.Override{{Kind: controller.OverridePin, Price: 50}}},
+		},
+		backends: []admin.BackendInfo{
+			{Pool: "llm", URL: "http://a:8080", State: "healthy", Breaker: "closed", InFlight: 2, Price: 7},
+			{Pool: "llm", URL: "http://b:8080", State: "ejected", Draining: true},
+		},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeAdmin) serve(w http.ResponseWriter, r *http.Request) {
+	reply := func(code int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(v)
+	}
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		reply(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	call := adminCall{method: r.Method, uri: r.URL.RequestURI()}
+	json.NewDecoder(r.Body).Decode(&call.body)
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /admin/keys":
+		reply(http.StatusOK, f.keys)
+	case "POST /admin/overrides":
+		reply(http.StatusOK, controller.Override{Kind: call.body["kind"].(string), Price: int(call.body["price"].(float64))})
+	case "DELETE /admin/overrides", "POST /admin/keys/reset":
+		w.WriteHeader(http.StatusNoContent)
+	case "GET /admin/backends", "POST /admin/backends/drain", "POST /admin/backends/enable":
+		reply(http.StatusOK, f.backends)
+	case "POST /admin/config/reload":
+		reply(http.StatusOK, map[string]int{"routes": 3, "pools": 1})
+	default:
+		reply(http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+func (f *fakeAdmin) lastCall(t *testing.T) adminCall {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.calls) == 0 {
+		t.Fatal("admin API was not called")
+	}
+	return f.calls[len(f.calls)-1]
+}
+
+// clearEnv 测试不受本机 RAJOMON_ADMIN_* 环境变量影响
+func clearEnv(t *testing.T) {
+	t.Setenv("RAJOMON_ADMIN_ADDR", "")
+	t.Setenv("RAJOMON_ADMIN_TOKEN", "")
+}
+
+func TestRunParsesInterleavedArgs(t *testing.T) {
+	clearEnv(t)
+	f := newFakeAdmin(t)
+	cases := []struct {
+		name   string
+		args   []string
+		method string
+		uri    string
+		body   map[string]any
+		output string
+	}{
+		{
+			name:   "global flags before the command",
+			args:   []string{"--addr", f.URL, "--token", testToken, "prices", "list"},
+			method: "GET", uri: "/admin/keys",
+			output: "/mcp:tools/call/web_search",
+		},
+		{
+			name: "flags between and after positional args",
+			args: []string{"prices", "pin", "--for", "10m", "/mcp:tools/call/web_search", "--addr=" + f.URL,
+				"50", "--reason", "故障演练", "--token", testToken},
+			method: "POST", uri: "/admin/overrides",
+			body:   map[string]any{"key": "/mcp:tools/call/web_search", "kind": "pin", "price": 50.0, "for": "10m0s", "reason": "故障演练"},
+			output: "📌 /mcp:tools/call/web_search pin=50 | 撤销前一直生效",
+		},
+		{
+			name:   "unpin a single kind",
+			args:   []string{"prices", "unpin", "--kind", "floor", "/mcp", "--addr", f.URL, "--token", testToken},
+			method: "DELETE", uri: "/admin/overrides?key=%2Fmcp&kind=floor",
+			output: "✅ /mcp 的价格干预已撤销",
+		},
+		{
+			name:   "reset",
+			args:   []string{"--token=" + testToken, "prices", "reset", "/mcp", "--addr", f.URL},
+			method: "POST", uri: "/admin/keys/reset",
+			body:   map[string]any{"key": "/mcp"},
+			output: "♻️ /mcp 的定价状态已重置",
+		},
+		{
+			name:   "drain in one pool",
+			args:   []string{"backends", "drain", "a:8080", "--pool", "llm", "--addr", f.URL, "--token", testToken},
+			method: "POST", uri: "/admin/backends/drain",
+			body:   map[string]any{"backend": "a:8080", "pool": "llm"},
+			output: "http://a:8080",
+		},
+		{
+			name:   "config reload",
+			args:   []string{"config", "reload", "--addr", f.URL, "--token", testToken},
+			method: "POST", uri: "/admin/config/reload",
+			output: "路由: 3 | 后端池: 1",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(tc.args, &out); err != nil {
+				t.Fatalf("run(%q) = %v", tc.args, err)
+			}
+			call := f.lastCall(t)
+			if call.method != tc.method || call.uri != tc.uri {
+				t.Errorf("request = %s %s, want %s %s", call.method, call.uri, tc.method, tc.uri)
+			}
+			for k, want := range tc.body {
+				if got := call.body[k]; got != want {
+					t.Errorf("body[%q] = %v, want %v", k, got, want)
+				}
+			}
+			if !strings.Contains(out.String(), tc.output) {
+				t.Errorf("output = %q, want it to contain %q", out.String(), tc.output)
+			}
+		})
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	clearEnv(t)
+	f := newFakeAdmin(t)
+	auth := []string{"--addr", f.URL, "--token", testToken}
+	cases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"success", append([]string{"prices", "list"}, auth...), 0},
+		{"help", []string{"--help"}, 0},
+		{"subcommand help", []string{"prices", "list", "-h"}, 0},
+		{"missing command", auth, 2},
+		{"unknown command", []string{"price", "list"}, 2},
+		{"missing subcommand", []string{"prices"}, 2},
+		{"missing positional arg", append([]string{"prices", "pin", "/mcp"}, auth...), 2},
+		{"price not an integer", append([]string{"prices", "pin", "/mcp", "cheap"}, auth...), 2},
+		{"unknown flag", append([]string{"prices", "list", "--bogus"}, auth...), 2},
+		{"unknown output format", append([]string{"-o", "yaml", "prices", "list"}, auth...), 2},
+		{"missing token", []string{"prices", "list", "--addr", f.URL}, 2},
+		{"bad interval", append([]string{"watch", "--interval", "0s"}, auth...), 2},
+		{"rejected by the admin API", []string{"prices", "list", "--addr", f.URL, "--token", "wrong"}, 1},
+		{"unknown backends subcommand", append([]string{"backends", "bounce", "a:8080"}, auth...), 2},
+		{"config only reloads", append([]string{"config", "dump"}, auth...), 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			if got := exitCode(run(tc.args, &bytes.Buffer{}), &stderr); got != tc.code {
+				t.Fatalf("exit code = %d, want %d (stderr: %s)", got, tc.code, stderr.String())
+			}
+			// 用法错误和 --help 都要打印用法，成功和接口错误不打印
+			wantUsage := tc.code == 2 || strings.Contains(tc.name, "help")
+			if printsUsage := strings.Contains(stderr.String(), "用法:"); printsUsage != wantUsage {
+				t.Errorf("stderr = %q, usage printed = %v, want %v", stderr.String(), printsUsage, wantUsage)
+			}
+		})
+	}
+
+	var stderr bytes.Buffer
+	exitCode(run([]string{"prices", "list", "--addr", f.URL, "--token", "wrong"}, &bytes.Buffer{}), &stderr)
+	if !strings.Contains(stderr.String(), "unauthorized (HTTP 401)") {
+		t.Errorf("stderr = %q, want the admin API error", stderr.String())
+	}
+}
+
+func TestPricesListOutput(t *testing.T) {
+	clearEnv(t)
+	f := newFakeAdmin(t)
+
+	var table bytes.Buffer
+	if err := run([]string{"prices", "list", "--addr", f.URL, "--token", testToken}, &table); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "KEY") {
+		t.Fatalf("table = %q, want a header and one row per key", table.String())
+	}
+	wantRows := [][]string{
+		{"/mcp", "composite", "12", "10", "85.5ms", "0.0", "0.00", "3.0", "-"},
+		{"/mcp:tools/call/web_search", "aimd", "50", "20", "0.0ms", "0.0", "0.00", "0.0", "pin=50"},
+	}
+	for i, want := range wantRows {
+		if got := strings.Fields(lines[i+1]); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("row %d = %q, want %q", i, got, want)
+		}
+	}
+
+	var js bytes.Buffer
+	if err := run([]string{"-o", "json", "prices", "list", "--addr", f.URL, "--token", testToken}, &js); err != nil {
+		t.Fatal(err)
+	}
+	var keys []controller.KeyInfo
+	if err := json.Unmarshal(js.Bytes(), &keys); err != nil {
+		t.Fatalf("JSON output %q: %v", js.String(), err)
+	}
+	if len(keys) != 2 || keys[1].Key != "/mcp:tools/call/web_search" || keys[1].Overrides[0].Price != 50 {
+		t.Errorf("JSON keys = %+v", keys)
+	}
+}
+
+func TestBackendsListOutput(t *testing.T) {
+	clearEnv(t)
+	f := newFakeAdmin(t)
+
+	var table bytes.Buffer
+	if err := run([]string{"backends", "list", "--addr", f.URL, "--token", testToken}, &table); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "POOL") {
+		t.Fatalf("table = %q, want a header and one row per backend", table.String())
+	}
+	// 未启用熔断的后端显示为 "-"
+	if got, want := strings.Fields(lines[2]), "llm http://b:8080 ejected true - 0 0 0.0ms"; strings.Join(got, " ") != want {
+		t.Errorf("row = %q, want %q", got, want)
+	}
+
+	var js bytes.Buffer
+	if err := run([]string{"backends", "list", "-o", "json", "--addr", f.URL, "--token", testToken}, &js); err != nil {
+		t.Fatal(err)
+	}
+	var backends []admin.BackendInfo
+	if err := json.Unmarshal(js.Bytes(), &backends); err != nil {
+		t.Fatalf("JSON output %q: %v", js.String(), err)
+	}
+	if len(backends) != 2 || backends[0] != f.backends[0] {
+		t.Errorf("JSON backends = %+v", backends)
+	}
+}
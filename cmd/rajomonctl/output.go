@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"rajomon-gateway/internal/admin"
+	"rajomon-gateway/internal/controller"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// 输出格式
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printKeys prices list 的表格输出
+func printKeys(w io.Writer, keys []controller.KeyInfo) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tPOLICY\tPRICE\tOWN\tEWMA_LATENCY\tEWMA_TOKENS\tEWMA_RETRIES\tCOST\tOVERRIDES")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1fms\t%.1f\t%.2f\t%.1f\t%s\n",
+			k.Key, k.Policy, k.Price, k.OwnPrice, k.EWMALatency, k.EWMATokens, k.EWMARetries, k.Cost, formatOverrides(k.Overrides))
+	}
+	tw.Flush()
+}
+
+// printBackends backends list 的表格输出
+func printBackends(w io.Writer, backends []admin.BackendInfo) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "POOL\tBACKEND\tSTATE\tDRAINING\tBREAKER\tIN_FLIGHT\tPRICE\tEWMA_LATENCY")
+	for _, b := range backends {
+		breaker := b.Breaker
+		if breaker == "" {
+			breaker = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%v\t%s\t%d\t%d\t%.1fms\n",
+			b.Pool, b.URL, b.State, b.Draining, breaker, b.InFlight, b.Price, b.EWMALatency)
+	}
+	tw.Flush()
+}
+
+// formatOverrides 把价格干预格式化为 "pin=50(9m59s) floor=10"
+func formatOverrides(list []controller.Override) string {
+	if len(list) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(list))
+	for _, o := range list {
+		parts = append(parts, formatOverride(o))
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatOverride(o controller.Override) string {
+	s := fmt.Sprintf("%s=%d", o.Kind, o.Price)
+	if !o.Expires.IsZero() {
+		s += fmt.Sprintf("(%v)", time.Until(o.Expires).Round(time.Second))
+	}
+	return s
+}
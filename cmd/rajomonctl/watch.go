@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"rajomon-gateway/internal/controller"
+	"time"
+)
+
+// priceChange watch 输出的一条价格变化
+type priceChange struct {
+	Time        time.Time `json:"ts"`
+	Key         string    `json:"key"`
+	From        int       `json:"from"` // 新出现的 Key 为 -1，消失的 Key 的 to 为 -1
+	To          int       `json:"to"`
+	EWMALatency float64   `json:"ewma_latency_ms"`
+	EWMATokens  float64   `json:"ewma_tokens"`
+	Cost        float64   `json:"cost"`
+	Overrides   string    `json:"overrides,omitempty"`
+}
+
+// watch 按周期轮询管理接口，只输出价格发生变化的 Key，直到 ctx 被取消
+// JSON 模式每行一条变化 (JSONL)，方便接 jq
+func watch(ctx context.Context, c *client, w io.Writer, interval time.Duration, output string) error {
+	last := make(map[string]int)
+	first := true
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var keys []controller.KeyInfo
+		if err := c.do(http.MethodGet, "/admin/keys", nil, &keys); err != nil {
+			// 网关重启等短暂不可用时继续等
+			fmt.Fprintf(w, "⚠️ %s 拉取失败: %v\n", time.Now().Format(time.TimeOnly), err)
+		} else {
+			now := time.Now()
+			seen := make(map[string]bool, len(keys))
+			for _, k := range keys {
+				seen[k.Key] = true
+				from, ok := last[k.Key]
+				if ok && from == k.Price {
+					continue
+				}
+				if !ok {
+					from = -1
+				}
+				last[k.Key] = k.Price
+				emit(w, output, first, priceChange{
+					Time: now, Key: k.Key, From: from, To: k.Price,
+					EWMALatency: k.EWMALatency, EWMATokens: k.EWMATokens, Cost: k.Cost,
+					Overrides: formatOverrides(k.Overrides),
+				})
+			}
+			for key, from := range last {
+				if !seen[key] {
+					delete(last, key)
+					emit(w, output, first, priceChange{Time: now, Key: key, From: from, To: -1})
+				}
+			}
+			first = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func emit(w io.Writer, output string, initial bool, ch priceChange) {
+	if output == outputJSON {
+		line, _ := json.Marshal(ch)
+		fmt.Fprintf(w, "%s\n", line)
+		return
+	}
+
+	ts := ch.Time.Format(time.TimeOnly)
+	switch {
+	case ch.To < 0:
+		fmt.Fprintf(w, "%s  %-40s  已移除 (最后价格 %d)\n", ts, ch.Key, ch.From)
+	case ch.From < 0 && initial:
+		fmt.Fprintf(w, "%s  %-40s  %4d      ewma %.0fms / %.0f tokens  cost %.0f  %s\n",
+			ts, ch.Key, ch.To, ch.EWMALatency, ch.EWMATokens, ch.Cost, ch.Overrides)
+	default:
+		from, arrow := "-", "🆕"
+		if ch.From >= 0 {
+			from, arrow = fmt.Sprint(ch.From), "📉"
+			if ch.To > ch.From {
+				arrow = "📈"
+			}
+		}
+		fmt.Fprintf(w, "%s  %-40s  %4s -> %-4d %s ewma %.0fms / %.0f tokens  cost %.0f  %s\n",
+			ts, ch.Key, from, ch.To, arrow, ch.EWMALatency, ch.EWMATokens, ch.Cost, ch.Overrides)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/controller"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// snapshotServer 依次返回预设的 Key 列表，取完后保持最后一个，并在返回最后一个时调用 done
+func snapshotServer(t *testing.T, done func(), snapshots ...[]controller.KeyInfo) *httptest.Server {
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys := snapshots[0]
+		if len(snapshots) > 1 {
+			snapshots = snapshots[1:]
+		} else {
+			done()
+		}
+		json.NewEncoder(w).Encode(keys)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func runWatchOnce(t *testing.T, output string) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := snapshotServer(t, cancel,
+		[]controller.KeyInfo{{Key: "/a", Price: 5}, {Key: "/b", Price: 7}},
+		[]controller.KeyInfo{{Key: "/a", Price: 5}, {Key: "/b", Price: 9}, {Key: "/c", Price: 1}},
+		[]controller.KeyInfo{{Key: "/a", Price: 6}, {Key: "/c", Price: 1}},
+	)
+
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() { done <- watch(ctx, newClient(srv.URL, testToken), &out, time.Millisecond, output) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("watch() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop after the context was cancelled")
+	}
+	return out.String()
+}
+
+func TestWatchReportsChangedNewAndRemovedKeys(t *testing.T) {
+	var got []string
+	sc := bufio.NewScanner(strings.NewReader(runWatchOnce(t, outputJSON)))
+	for sc.Scan() {
+		var ch priceChange
+		if err := json.Unmarshal(sc.Bytes(), &ch); err != nil {
+			t.Fatalf("line %q is not JSON: %v", sc.Text(), err)
+		}
+		got = append(got, ch.Key+" "+strconv.Itoa(ch.From)+"->"+strconv.Itoa(ch.To))
+	}
+
+	// 价格没变的 Key 不输出；新出现的 from 为 -1，消失的 to 为 -1
+	want := []string{"/a -1->5", "/b -1->7", "/b 7->9", "/c -1->1", "/a 5->6", "/b 9->-1"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+}
+
+func TestWatchTableOutput(t *testing.T) {
+	out := runWatchOnce(t, outputTable)
+	for _, want := range []string{"5 -> 6    📈", "7 -> 9    📈", "-> 1    🆕", "已移除 (最后价格 9)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table output does not contain %q:\n%s", want, out)
+		}
+	}
+	// 第一轮是初始快照，不画箭头
+	if first := strings.SplitN(out, "\n", 2)[0]; strings.Contains(first, "->") {
+		t.Errorf("initial snapshot line = %q, want no arrow", first)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"rajomon-gateway/internal/trace"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 回放网关录制的请求追踪 (TRACE_FILE)，在本地复现线上的过载现场
+//
+//	go run ./cmd/replay -trace traces/requests.jsonl -target http://localhost:8080 -speed 2
+//
+// 目标可以是网关，也可以直接是某个后端 (绕过准入控制，观察后端本身的承载能力)
+// 网关启用了账户时用 -keys (默认读 ACCOUNT_KEYS) 给每个客户端附加 API Key，追踪文件里不保存凭证:
+//
+//	ACCOUNT_KEYS=alice=sk-1,bob=sk-2 go run ./cmd/replay -trace traces/requests.jsonl
+func main() {
+	cfg := trace.DefaultReplayConfig()
+	tracePath := flag.String("trace", "traces/requests.jsonl", "JSONL 追踪文件")
+	flag.StringVar(&cfg.Target, "target", cfg.Target, "网关或后端地址")
+	flag.Float64Var(&cfg.Speed, "speed", cfg.Speed, "回放倍速: 1 实时, 2 两倍速, 0 尽快发出")
+	flag.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "单个请求超时")
+	flag.IntVar(&cfg.MaxInFlight, "max-inflight", cfg.MaxInFlight, "在途请求上限")
+	apiKeys := flag.String("keys", "", "客户端 -> API Key (alice=sk-1,bob=sk-2)，为空时读 ACCOUNT_KEYS")
+	jsonOut := flag.Bool("json", false, "以 JSON 输出结果")
+	flag.Parse()
+
+	// 默认值不放进 flag，避免 -h 把环境变量里的 Key 打印出来
+	if *apiKeys == "" {
+		*apiKeys = os.Getenv("ACCOUNT_KEYS")
+	}
+	var err error
+	if cfg.APIKeys, err = parseKeys(*apiKeys); err != nil {
+		fmt.Printf("❌ -keys 无效: %v\n", err)
+		os.Exit(2)
+	}
+
+	records, err := trace.ReadFile(*tracePath)
+	if err != nil {
+		fmt.Printf("❌ 读取追踪文件失败: %v\n", err)
+		os.Exit(1)
+	}
+	if len(records) == 0 {
+		fmt.Printf("⚠️ 追踪文件 %s 为空\n", *tracePath)
+		return
+	}
+	span := records[len(records)-1].Time.Sub(records[0].Time)
+	fmt.Printf("📼 回放 %d 个请求 (录制时长 %v) -> %s | 倍速: %v\n", len(records), span.Round(time.Millisecond), cfg.Target, cfg.Speed)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := trace.Replay(ctx, records, cfg)
+	if err != nil {
+		fmt.Printf("⚠️ 回放中断: %v\n", err)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+		return
+	}
+
+	fmt.Printf("\n📋 回放完成 | 发出 %d | 网络错误 %d | 耗时 %v\n", result.Sent, result.Errors, result.Elapsed.Round(time.Millisecond))
+	keys := make([]string, 0, len(result.Keys))
+	for k := range result.Keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Printf("%-40s %6s  %-28s %-28s\n", "key", "sent", "recorded", "replayed")
+	for _, k := range keys {
+		kr := result.Keys[k]
+		fmt.Printf("%-40s %6d  %-28s %-28s\n", k, kr.Sent, formatCodes(kr.Recorded), formatCodes(kr.Replayed))
+	}
+}
+
+// parseKeys 解析 "client=key,client=key"
+func parseKeys(s string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for i, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		client, key, ok := strings.Cut(pair, "=")
+		if !ok || client == "" || key == "" {
+			return nil, fmt.Errorf("第 %d 项应为 client=key", i+1) // 不回显内容，里面可能是 Key
+		}
+		keys[client] = key
+	}
+	return keys, nil
+}
+
+// formatCodes 把状态码分布格式化为 "200:12 429:3"
+func formatCodes(codes map[int]int) string {
+	keys := make([]int, 0, len(codes))
+	for c := range codes {
+		keys = append(keys, c)
+	}
+	sort.Ints(keys)
+	out := ""
+	for i, c := range keys {
+		if i > 0 {
+			out += " "
+		}
+		name := fmt.Sprint(c)
+		if c == 0 {
+			name = "err"
+		}
+		out += fmt.Sprintf("%s:%d", name, codes[c])
+	}
+	return out
+}
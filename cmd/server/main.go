@@ -1,120 +1,151 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
-	"rajomon-gateway/internal/controller"
-	"rajomon-gateway/internal/handler"
+	"os/signal"
+	"rajomon-gateway/internal/admin"
+	"rajomon-gateway/internal/config"
+	"rajomon-gateway/internal/gateway"
 	"rajomon-gateway/internal/metrics"
-	"rajomon-gateway/internal/middleware"
-	"strings"
-	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// SimpleLoadBalancer 简单的轮询负载均衡器
-type SimpleLoadBalancer struct {
-	backends []*url.URL
-	current  uint64
-}
+// shutdownTimeout 优雅退出时等待在途请求结束的最长时间
+const shutdownTimeout = 10 * time.Second
 
-func NewLoadBalancer(targets []string) *SimpleLoadBalancer {
-	var backends []*url.URL
-	for _, target := range targets {
-		u, err := url.Parse(target)
-		if err != nil {
-			log.Fatalf("后端地址解析失败: %s", err)
-		}
-		backends = append(backends, u)
-	}
-	return &SimpleLoadBalancer{backends: backends}
-}
-
-// ServeHTTP 实现反向代理转发
-func (lb *SimpleLoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-
-	if len(lb.backends) == 0 {
-		http.Error(w, "No backend available", http.StatusServiceUnavailable)
+// 配置优先级 (低 -> 高): 内置默认值 < 配置文件 (-config / GATEWAY_CONFIG) < 环境变量 < 命令行参数
+//
+//	gateway -config deploy/gateway.yaml
+//	gateway -config deploy/gateway.yaml -check   # 只校验配置
+//	kill -HUP <pid>                              # 热加载配置 (修改配置文件也会自动触发)
+func main() {
+	// 0. 读取配置，任何错误都在启动时一次性报告
+	flags, err := config.ParseFlags(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
 		return
+	} else if err != nil {
+		os.Exit(2)
 	}
-
-	// 1. 轮询算法选择后端
-	idx := atomic.AddUint64(&lb.current, 1) % uint64(len(lb.backends))
-	target := lb.backends[idx]
-
-	// 2. 创建反向代理
-	proxy := httputil.NewSingleHostReverseProxy(target)
-
-	// 修改请求头，确保 Host 正确
-	originalDirector := proxy.Director
-	proxy.Director = func(req *http.Request) {
-		originalDirector(req)
-		req.Host = target.Host
-		// 可以在这里加一个 Header 标识经过了网关
-		req.Header.Set("X-Forwarded-By", "Rajomon-Gateway")
+	cfg, err := config.Load(flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
 	}
-
-	// 自定义错误处理 (比如后端挂了)
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		fmt.Printf("❌ [LB] 转发失败 -> %s: %v\n", target.Host, err)
-		w.WriteHeader(http.StatusBadGateway)
+	if flags.Check {
+		fmt.Print(cfg)
+		fmt.Println("✅ 配置有效")
+		return
 	}
 
-	fmt.Printf("🔀 [LB] 转发请求 -> %s\n", target.Host)
-	proxy.ServeHTTP(w, r)
-}
-
-func main() {
-	// [新增] 0. 初始化 Metrics
+	// [新增] 1. 初始化 Metrics
 	metrics.Init()
 
-	// 1. 从环境变量获取后端列表
-	// 格式: "http://backend-1:8080,http://backend-2:8080"
-	backendEnv := os.Getenv("BACKEND_HOSTS")
-	if backendEnv == "" {
-		// 默认值，方便本地非Docker调试（假设本地起了backend在9001）
-		backendEnv = "http://localhost:9001"
+	// 2. 按配置组装网关 (控制器、后端池、路由)
+	gw, err := gateway.New(cfg)
+	if err != nil {
+		log.Fatalf("❌ 网关初始化失败: %v", err)
+	}
+	// SIGINT / SIGTERM (docker stop、k8s) 时优雅退出：停止接收新请求，等在途请求结束后把账户、Trace、审计日志落盘
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	gw.Start(ctx)
+
+	// 2.1 热加载：SIGHUP 或配置文件变化时重新读取配置 (环境变量和命令行参数照常覆盖)
+	// 新配置无效时继续使用旧配置
+	load := func() (*config.Config, error) { return config.Load(flags, os.Getenv) }
+	reload := func(trigger string) { gw.Reload(trigger, load) }
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload("SIGHUP")
+		}
+	}()
+	if path := flags.File(os.Getenv); path != "" && flags.WatchInterval > 0 {
+		go config.Watch(ctx, path, flags.WatchInterval, func() { reload("file") })
+		fmt.Printf("👁️ 配置热加载已启用 | 文件: %s | 轮询周期: %v | 也可发送 SIGHUP\n", path, flags.WatchInterval)
 	}
-	targets := strings.Split(backendEnv, ",")
-
-	// 2. 初始化负载均衡器
-	lb := NewLoadBalancer(targets)
-	fmt.Printf("⚖️ 负载均衡器已就绪，后端节点: %v\n", targets)
 
-	// 3. 初始化控制器
-	rajomonCtrl := controller.NewController()
 	mux := http.NewServeMux()
-
-	// 4. 组装核心链路: Client -> Rajomon Middleware -> LoadBalancer -> Backend
-	// 注意：我们把 lb 当作 next handler 传给 Middleware
-	wrappedLB := middleware.RajomonMiddleware(rajomonCtrl, lb)
-
-	// 注册路由
-	mux.Handle("/mcp/chat", wrappedLB)
-
-	// 保留 context 测试接口
-	contextBizHandler := http.HandlerFunc(handler.ContextHandler)
-	mux.Handle("/context", middleware.RajomonMiddleware(rajomonCtrl, contextBizHandler))
+	mux.Handle("/", gw.Handler())
+
+	// 所有监听端口共用一个错误通道，任何一个启动失败都触发退出
+	var servers []*http.Server
+	serveErr := make(chan error, 3)
+	serve := func(name string, srv *http.Server) {
+		servers = append(servers, srv)
+		go func() {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("%s启动失败: %w", name, err)
+			}
+		}()
+	}
 
 	// --- 🆕 新增: 注册 Prometheus Metrics 接口 ---
-	// Prometheus 会来这里拉取数据
-	mux.Handle("/metrics", promhttp.Handler())
-	fmt.Println("👀 Prometheus Metrics 已暴露在 /metrics")
+	// Prometheus 会来这里拉取数据；metrics_listen 非空时使用独立端口
+	if cfg.MetricsListen == "" {
+		mux.Handle("/metrics", promhttp.Handler())
+		fmt.Println("👀 Prometheus Metrics 已暴露在 /metrics")
+	} else {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", promhttp.Handler())
+		fmt.Printf("👀 Prometheus Metrics 已暴露在 %s/metrics\n", cfg.MetricsListen)
+		serve("Metrics 端口", &http.Server{Addr: cfg.MetricsListen, Handler: metricsMux})
+	}
+
+	// 2.2 管理接口 (独立端口，需要 Token)
+	var adminSrv *admin.Server
+	if cfg.AdminListen != "" {
+		adminSrv, err = admin.NewServer(gw, cfg.Admin, admin.WithConfigLoader(load))
+		if err != nil {
+			log.Fatalf("❌ 管理接口初始化失败: %v", err)
+		}
+		fmt.Printf("🔐 管理接口已启动，监听 %s (操作人: %d)\n", cfg.AdminListen, len(cfg.Admin.Tokens))
+		serve("管理接口", &http.Server{Addr: cfg.AdminListen, Handler: adminSrv})
+	}
 
-	// 5. 启动服务
-	addr := ":8080"
-	fmt.Printf("🚀 rajomon 服务端已启动，监听 %s\n", addr)
+	// 3. 启动服务
+	fmt.Printf("🚀 rajomon 服务端已启动，监听 %s\n", cfg.Listen)
 	// 这里传入 mux，而不是 nil
-	server := &http.Server{
-		Addr:    addr,
-		Handler: mux,
+	serve("业务端口", &http.Server{Addr: cfg.Listen, Handler: mux})
+
+	// 4. 等待退出信号或监听失败
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		fmt.Println("🛑 收到退出信号，正在优雅关闭 (再次发送信号强制退出)")
+	case err := <-serveErr:
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		exitCode = 1
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			fmt.Printf("⚠️ 关闭 %s 超时，仍有请求未结束: %v\n", srv.Addr, err)
+		}
 	}
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatal("启动失败", err)
+	cancel()
+	if err := gw.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 网关落盘失败: %v\n", err)
+		exitCode = 1
+	}
+	if adminSrv != nil {
+		if err := adminSrv.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ 审计日志关闭失败: %v\n", err)
+			exitCode = 1
+		}
 	}
+	fmt.Println("👋 网关已退出")
+	os.Exit(exitCode)
 }
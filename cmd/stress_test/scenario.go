@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Duration 支持 "30s" / "1m30s" 写法的时间长度 (JSON 与 YAML 通用)
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("时间长度必须是字符串 (例如 \"30s\"): %s", b)
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) parse(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Scenario 压测场景
+type Scenario struct {
+	Name     string   `json:"name" yaml:"name"`
+	Target   string   `json:"target" yaml:"target"`     // 网关地址，例如 http://localhost:8080
+	Duration Duration `json:"duration" yaml:"duration"` // 总时长，为空时取各用户类阶段时长的最大值
+	Timeout  Duration `json:"timeout" yaml:"timeout"`   // 单个请求超时
+	// SampleInterval 价格轨迹的采样间隔
+	SampleInterval Duration `json:"sample_interval" yaml:"sample_interval"`
+	// MaxInFlight 同时在途的请求上限，超出的到达直接记为 dropped (开环压测不等待)
+	MaxInFlight int         `json:"max_inflight" yaml:"max_inflight"`
+	Seed        int64       `json:"seed" yaml:"seed"`
+	Classes     []ClassSpec `json:"classes" yaml:"classes"`
+}
+
+// ClassSpec 一类用户
+type ClassSpec struct {
+	Name   string            `json:"name" yaml:"name"`
+	Method string            `json:"method" yaml:"method"` // 默认 GET
+	Path   string            `json:"path" yaml:"path"`     // 默认 /mcp/chat
+	Body   string            `json:"body" yaml:"body"`
+	Header map[string]string `json:"header" yaml:"header"`
+	Users  int               `json:"users" yaml:"users"` // 用户数，每个用户一个独立钱包和 Client-ID
+
+	Balance    int64      `json:"balance" yaml:"balance"`         // 初始余额
+	MaxBalance int64      `json:"max_balance" yaml:"max_balance"` // 余额上限，默认等于 10 倍初始余额
+	Bid        string     `json:"bid" yaml:"bid"`                 // static / fixed / random
+	Refill     RefillSpec `json:"refill" yaml:"refill"`
+	Arrival    Arrival    `json:"arrival" yaml:"arrival"`
+}
+
+// RefillSpec 钱包充值
+type RefillSpec struct {
+	Dist     string   `json:"dist" yaml:"dist"` // poisson / fixed / uniform / mmpp，为空表示不充值
+	Interval Duration `json:"interval" yaml:"interval"`
+	Step     int64    `json:"step" yaml:"step"`
+}
+
+// Arrival 到达过程 (开环：到达时间与响应快慢无关)
+type Arrival struct {
+	Process string  `json:"process" yaml:"process"` // poisson (默认) / fixed / uniform / mmpp
+	Rate    float64 `json:"rate" yaml:"rate"`       // 没有 Phases 时的恒定速率 (请求/秒)
+	// mmpp 的 ON/OFF 平均持续时间
+	OnDuration  Duration `json:"on_duration" yaml:"on_duration"`
+	OffDuration Duration `json:"off_duration" yaml:"off_duration"`
+	Phases      []Phase  `json:"phases" yaml:"phases"`
+}
+
+// Phase 负载阶段
+//   - step: 以 Rate 恒定速率持续 Duration
+//   - ramp: 在 Duration 内从 Rate 线性变化到 To
+//   - spike: 以 Rate 持续 Duration 的短时突发，语义同 step，在汇总里单独标注
+type Phase struct {
+	Type     string   `json:"type" yaml:"type"`
+	Duration Duration `json:"duration" yaml:"duration"`
+	Rate     float64  `json:"rate" yaml:"rate"`
+	To       float64  `json:"to" yaml:"to"`
+}
+
+// LoadScenario 按扩展名解析 YAML / JSON 场景文件
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scenario{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, s)
+	case ".json":
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(s)
+	default:
+		return nil, fmt.Errorf("不支持的场景文件格式: %s (只支持 .yaml/.yml/.json)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析场景文件 %s 失败: %w", path, err)
+	}
+	if err := s.normalize(); err != nil {
+		return nil, fmt.Errorf("场景文件 %s 无效: %w", path, err)
+	}
+	return s, nil
+}
+
+// normalize 填充默认值并校验
+func (s *Scenario) normalize() error {
+	if s.Target == "" {
+		s.Target = "http://localhost:8080"
+	}
+	s.Target = strings.TrimRight(s.Target, "/")
+	if s.Timeout == 0 {
+		s.Timeout = Duration(30 * time.Second)
+	}
+	if s.SampleInterval == 0 {
+		s.SampleInterval = Duration(time.Second)
+	}
+	if s.MaxInFlight == 0 {
+		s.MaxInFlight = 1000
+	}
+	if s.Seed == 0 {
+		s.Seed = time.Now().UnixNano()
+	}
+	if len(s.Classes) == 0 {
+		return fmt.Errorf("至少需要一个用户类 (classes)")
+	}
+
+	var longest time.Duration
+	for i := range s.Classes {
+		c := &s.Classes[i]
+		if c.Name == "" {
+			return fmt.Errorf("classes[%d]: 缺少 name", i)
+		}
+		if c.Method == "" {
+			c.Method = "GET"
+		}
+		if c.Path == "" {
+			c.Path = "/mcp/chat"
+		}
+		if c.Users <= 0 {
+			c.Users = 1
+		}
+		if c.MaxBalance == 0 {
+			c.MaxBalance = 10 * c.Balance
+		}
+		switch c.Bid {
+		case "":
+			c.Bid = "static"
+		case "static", "fixed", "random":
+		default:
+			return fmt.Errorf("%s: 未知的出价策略 %q (static / fixed / random)", c.Name, c.Bid)
+		}
+		if c.Refill.Dist != "" && (c.Refill.Interval <= 0 || c.Refill.Step <= 0) {
+			return fmt.Errorf("%s: refill 需要正的 interval 和 step", c.Name)
+		}
+
+		a := &c.Arrival
+		switch a.Process {
+		case "":
+			a.Process = "poisson"
+		case "poisson", "fixed", "uniform":
+		case "mmpp":
+			if a.OnDuration == 0 {
+				a.OnDuration = Duration(2 * time.Second)
+			}
+			if a.OffDuration == 0 {
+				a.OffDuration = Duration(6 * time.Second)
+			}
+		default:
+			return fmt.Errorf("%s: 未知的到达过程 %q", c.Name, a.Process)
+		}
+		if len(a.Phases) == 0 && a.Rate <= 0 {
+			return fmt.Errorf("%s: 需要 arrival.rate 或 arrival.phases", c.Name)
+		}
+		var total time.Duration
+		for j, p := range a.Phases {
+			switch p.Type {
+			case "step", "spike", "ramp":
+			default:
+				return fmt.Errorf("%s: phases[%d] 未知类型 %q (step / ramp / spike)", c.Name, j, p.Type)
+			}
+			if p.Duration <= 0 || p.Rate < 0 || p.To < 0 {
+				return fmt.Errorf("%s: phases[%d] 需要正的 duration 和非负的速率", c.Name, j)
+			}
+			total += time.Duration(p.Duration)
+		}
+		if total > longest {
+			longest = total
+		}
+	}
+
+	if s.Duration == 0 {
+		s.Duration = Duration(longest)
+	}
+	if s.Duration <= 0 {
+		return fmt.Errorf("需要 duration (或在 phases 里给出阶段时长)")
+	}
+	return nil
+}
+
+// RateAt 返回相对开始时间 t 时的目标速率 (请求/秒)，以及当前阶段名称
+// 阶段全部结束后速率为 0
+func (a *Arrival) RateAt(t time.Duration) (float64, string) {
+	if len(a.Phases) == 0 {
+		return a.Rate, "steady"
+	}
+	var offset time.Duration
+	for i, p := range a.Phases {
+		d := time.Duration(p.Duration)
+		if t < offset+d {
+			name := fmt.Sprintf("%d:%s", i, p.Type)
+			if p.Type == "ramp" {
+				frac := float64(t-offset) / float64(d)
+				return p.Rate + (p.To-p.Rate)*frac, name
+			}
+			return p.Rate, name
+		}
+		offset += d
+	}
+	return 0, "done"
+}
+
+// defaultScenario 没有场景文件时使用：复刻旧版三类用户，恒定泊松到达跑 60 秒
+func defaultScenario(target string) *Scenario {
+	s := &Scenario{
+		Name:     "default",
+		Target:   target,
+		Duration: Duration(60 * time.Second),
+		Classes: []ClassSpec{
+			{Name: "Student", Balance: 10, Users: 5, Arrival: Arrival{Rate: 5}},
+			{Name: "Engineer", Balance: 20, Users: 5, Arrival: Arrival{Rate: 5}},
+			{Name: "VIP_Boss", Balance: 100, Users: 5, Arrival: Arrival{Rate: 5}},
+		},
+	}
+	if err := s.normalize(); err != nil {
+		panic(err)
+	}
+	return s
+}
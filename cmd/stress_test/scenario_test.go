@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeScenario(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBundledScenariosLoad(t *testing.T) {
+	cases := []struct {
+		file     string
+		duration time.Duration
+		classes  int
+	}{
+		// 场景时长取各用户类阶段时长之和的最大值
+		{"scenarios/spike.yaml", 50 * time.Second, 3},
+		{"scenarios/ramp.json", 80 * time.Second, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.file, func(t *testing.T) {
+			s, err := LoadScenario(tc.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if time.Duration(s.Duration) != tc.duration || len(s.Classes) != tc.classes {
+				t.Errorf("duration = %v, classes = %d; want %v, %d", s.Duration, len(s.Classes), tc.duration, tc.classes)
+			}
+		})
+	}
+}
+
+func TestLoadScenarioFillsDefaults(t *testing.T) {
+	path := writeScenario(t, "s.yaml", `
+target: http://gw:8080/
+duration: 10s
+classes:
+  - name: A
+    balance: 20
+    arrival: {process: mmpp, rate: 2}
+`)
+	s, err := LoadScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Target != "http://gw:8080" || time.Duration(s.Timeout) != 30*time.Second || s.MaxInFlight != 1000 {
+		t.Errorf("scenario = %+v, want trimmed target and default timeout / max_inflight", s)
+	}
+	c := s.Classes[0]
+	if c.Method != "GET" || c.Path != "/mcp/chat" || c.Users != 1 || c.Bid != "static" || c.MaxBalance != 200 {
+		t.Errorf("class = %+v, want default method, path, users, bid and 10x max_balance", c)
+	}
+	if time.Duration(c.Arrival.OnDuration) != 2*time.Second || time.Duration(c.Arrival.OffDuration) != 6*time.Second {
+		t.Errorf("mmpp on/off = %v/%v, want 2s/6s", c.Arrival.OnDuration, c.Arrival.OffDuration)
+	}
+}
+
+func TestLoadScenarioRejectsInvalid(t *testing.T) {
+	cases := []struct {
+		name, file, content, want string
+	}{
+		{"unknown extension", "s.toml", "name = 'x'", "不支持的场景文件格式"},
+		{"unknown yaml field", "s.yaml", "duration: 1s\nclases: []\n", "clases"},
+		{"unknown json field", "s.json", `{"duration": "1s", "clases": []}`, "clases"},
+		{"duration not a string", "s.json", `{"duration": 10}`, "时间长度必须是字符串"},
+		{"no classes", "s.yaml", "duration: 1s\n", "至少需要一个用户类"},
+		{"class without name", "s.yaml", "duration: 1s\nclasses: [{arrival: {rate: 1}}]\n", "classes[0]: 缺少 name"},
+		{"unknown bid", "s.yaml", "duration: 1s\nclasses: [{name: A, bid: max, arrival: {rate: 1}}]\n", "未知的出价策略"},
+		{"refill without step", "s.yaml", "duration: 1s\nclasses: [{name: A, refill: {dist: poisson, interval: 1s}, arrival: {rate: 1}}]\n", "refill"},
+		{"unknown process", "s.yaml", "duration: 1s\nclasses: [{name: A, arrival: {process: burst, rate: 1}}]\n", "未知的到达过程"},
+		{"no rate", "s.yaml", "duration: 1s\nclasses: [{name: A}]\n", "arrival.rate"},
+		{"unknown phase", "s.yaml", "classes: [{name: A, arrival: {phases: [{type: wave, duration: 1s, rate: 1}]}}]\n", "phases[0] 未知类型"},
+		{"negative phase rate", "s.yaml", "classes: [{name: A, arrival: {phases: [{type: ramp, duration: 1s, rate: 1, to: -1}]}}]\n", "phases[0]"},
+		{"no duration", "s.yaml", "classes: [{name: A, arrival: {rate: 1}}]\n", "需要 duration"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadScenario(writeScenario(t, tc.file, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("LoadScenario() error = %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestArrivalRateAt(t *testing.T) {
+	phased := Arrival{Phases: []Phase{
+		{Type: "step", Duration: Duration(10 * time.Second), Rate: 2},
+		{Type: "ramp", Duration: Duration(10 * time.Second), Rate: 2, To: 22},
+		{Type: "spike", Duration: Duration(5 * time.Second), Rate: 50},
+	}}
+	cases := []struct {
+		name    string
+		arrival Arrival
+		at      time.Duration
+		rate    float64
+		phase   string
+	}{
+		{"no phases is steady", Arrival{Rate: 7}, time.Hour, 7, "steady"},
+		{"step", phased, 0, 2, "0:step"},
+		{"step end is exclusive", phased, 10 * time.Second, 2, "1:ramp"},
+		{"ramp midpoint", phased, 15 * time.Second, 12, "1:ramp"},
+		{"ramp near end", phased, 19500 * time.Millisecond, 21, "1:ramp"},
+		{"spike", phased, 22 * time.Second, 50, "2:spike"},
+		{"after last phase", phased, 25 * time.Second, 0, "done"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rate, phase := tc.arrival.RateAt(tc.at)
+			if rate != tc.rate || phase != tc.phase {
+				t.Errorf("RateAt(%v) = %v, %q; want %v, %q", tc.at, rate, phase, tc.rate, tc.phase)
+			}
+		})
+	}
+}
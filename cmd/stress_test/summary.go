@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// classStats 一类用户的统计
+type classStats struct {
+	mu        sync.Mutex
+	counts    classCounts
+	latencies []time.Duration // 成功请求的耗时
+}
+
+type classCounts struct {
+	Arrivals int `json:"arrivals"`
+	OK       int `json:"ok"`
+	Rejected int `json:"rejected"`
+	Shed     int `json:"shed"`
+	Dropped  int `json:"dropped"`
+	Errors   int `json:"errors"`
+}
+
+func (s *classStats) record(outcome string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts.Arrivals++
+	switch outcome {
+	case outcomeOK:
+		s.counts.OK++
+		s.latencies = append(s.latencies, latency)
+	case outcomeRejected:
+		s.counts.Rejected++
+	case outcomeShed:
+		s.counts.Shed++
+	case outcomeDropped:
+		s.counts.Dropped++
+	default:
+		s.counts.Errors++
+	}
+}
+
+func (s *classStats) snapshot() classCounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts
+}
+
+// priceTrajectory 按采样间隔记录每个路径观测到的最高价格
+type priceTrajectory struct {
+	mu       sync.Mutex
+	interval time.Duration
+	buckets  map[string]map[int]int64 // path -> bucket -> price
+	last     map[string]int64
+}
+
+func newPriceTrajectory(interval time.Duration) *priceTrajectory {
+	return &priceTrajectory{
+		interval: interval,
+		buckets:  make(map[string]map[int]int64),
+		last:     make(map[string]int64),
+	}
+}
+
+func (p *priceTrajectory) observe(at time.Duration, path string, price int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b := int(at / p.interval)
+	if p.buckets[path] == nil {
+		p.buckets[path] = make(map[int]int64)
+	}
+	if old, ok := p.buckets[path][b]; !ok || price > old {
+		p.buckets[path][b] = price
+	}
+	p.last[path] = price
+}
+
+func (p *priceTrajectory) latest() map[string]int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]int64, len(p.last))
+	for k, v := range p.last {
+		out[k] = v
+	}
+	return out
+}
+
+// PricePoint 价格轨迹上的一个点
+type PricePoint struct {
+	At    float64 `json:"at_seconds"`
+	Price int64   `json:"price"`
+}
+
+func (p *priceTrajectory) series() map[string][]PricePoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string][]PricePoint, len(p.buckets))
+	for path, buckets := range p.buckets {
+		points := make([]PricePoint, 0, len(buckets))
+		for b, price := range buckets {
+			points = append(points, PricePoint{At: (time.Duration(b) * p.interval).Seconds(), Price: price})
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i].At < points[j].At })
+		out[path] = points
+	}
+	return out
+}
+
+// ClassSummary 一类用户的汇总
+type ClassSummary struct {
+	Name string `json:"name"`
+	classCounts
+	Goodput       float64 `json:"goodput_rps"`    // 成功请求/秒
+	RejectionRate float64 `json:"rejection_rate"` // (网关拒绝 + 本地丢弃) / 到达数
+	P50Ms         float64 `json:"p50_ms"`
+	P90Ms         float64 `json:"p90_ms"`
+	P99Ms         float64 `json:"p99_ms"`
+	MaxMs         float64 `json:"max_ms"`
+}
+
+// Summary 整个场景的汇总
+type Summary struct {
+	Scenario string                  `json:"scenario"`
+	Duration float64                 `json:"duration_seconds"`
+	Classes  []ClassSummary          `json:"classes"`
+	Prices   map[string][]PricePoint `json:"price_trajectory"`
+}
+
+func (r *Runner) summary(elapsed time.Duration) *Summary {
+	out := &Summary{Scenario: r.scenario.Name, Duration: elapsed.Seconds(), Prices: r.prices.series()}
+	for _, c := range r.scenario.Classes {
+		st := r.stats[c.Name]
+		st.mu.Lock()
+		cs := ClassSummary{Name: c.Name, classCounts: st.counts}
+		lat := append([]time.Duration(nil), st.latencies...)
+		st.mu.Unlock()
+
+		if elapsed > 0 {
+			cs.Goodput = float64(cs.OK) / elapsed.Seconds()
+		}
+		if cs.Arrivals > 0 {
+			cs.RejectionRate = float64(cs.Rejected+cs.Shed) / float64(cs.Arrivals)
+		}
+		sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+		cs.P50Ms = percentileMs(lat, 0.50)
+		cs.P90Ms = percentileMs(lat, 0.90)
+		cs.P99Ms = percentileMs(lat, 0.99)
+		cs.MaxMs = percentileMs(lat, 1)
+		out.Classes = append(out.Classes, cs)
+	}
+	return out
+}
+
+// percentileMs 最近秩法求百分位 (输入已排序)
+func percentileMs(sorted []time.Duration, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return float64(sorted[idx].Microseconds()) / 1000
+}
+
+// Print 打印人类可读的汇总表
+func (s *Summary) Print(w io.Writer) {
+	fmt.Fprintf(w, "\n📋 场景 %q 汇总 (%.1fs)\n", s.Scenario, s.Duration)
+	fmt.Fprintf(w, "%-12s %8s %8s %8s %8s %8s %8s %10s %8s %9s %9s %9s\n",
+		"class", "arrivals", "ok", "rejected", "shed", "dropped", "errors", "goodput/s", "reject%", "p50(ms)", "p90(ms)", "p99(ms)")
+	for _, c := range s.Classes {
+		fmt.Fprintf(w, "%-12s %8d %8d %8d %8d %8d %8d %10.2f %7.1f%% %9.1f %9.1f %9.1f\n",
+			c.Name, c.Arrivals, c.OK, c.Rejected, c.Shed, c.Dropped, c.Errors,
+			c.Goodput, c.RejectionRate*100, c.P50Ms, c.P90Ms, c.P99Ms)
+	}
+
+	paths := make([]string, 0, len(s.Prices))
+	for path := range s.Prices {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(w, "\n🏷️ 价格轨迹 %s\n", path)
+		for _, p := range s.Prices[path] {
+			fmt.Fprintf(w, "  %6.1fs  %d\n", p.At, p.Price)
+		}
+	}
+}
+
+// WriteJSON 输出 JSON 格式的汇总
+func (s *Summary) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
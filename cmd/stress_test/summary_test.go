@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPercentileMs(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	cases := []struct {
+		name   string
+		sorted []time.Duration
+		q      float64
+		want   float64
+	}{
+		{"empty", nil, 0.99, 0},
+		{"single sample", []time.Duration{1500 * time.Microsecond}, 0.5, 1.5},
+		{"p0 is the minimum", sorted, 0, 1},
+		{"p50 nearest rank", sorted, 0.5, 50},
+		{"p99 nearest rank", sorted, 0.99, 99},
+		{"max", sorted, 1, 100},
+		{"rank rounds up", sorted[:3], 0.5, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := percentileMs(tc.sorted, tc.q); got != tc.want {
+				t.Errorf("percentileMs(q=%v) = %v, want %v", tc.q, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSummaryAggregatesOutcomes(t *testing.T) {
+	s := &Scenario{Name: "unit", SampleInterval: Duration(time.Second), Classes: []ClassSpec{{Name: "A"}, {Name: "B"}}}
+	r := NewRunner(s)
+	a := r.stats["A"]
+	// 延迟乱序记录，汇总时排序
+	for _, ms := range []int{30, 10, 20, 40} {
+		a.record(outcomeOK, time.Duration(ms)*time.Millisecond)
+	}
+	a.record(outcomeRejected, time.Millisecond)
+	a.record(outcomeShed, 0)
+	a.record(outcomeDropped, 0)
+	a.record(outcomeError, 0)
+
+	r.prices.observe(200*time.Millisecond, "/mcp", 5)
+	r.prices.observe(800*time.Millisecond, "/mcp", 9)
+	r.prices.observe(900*time.Millisecond, "/mcp", 6)
+	r.prices.observe(2500*time.Millisecond, "/mcp", 3)
+
+	sum := r.summary(2 * time.Second)
+	got := sum.Classes[0]
+	want := classCounts{Arrivals: 8, OK: 4, Rejected: 1, Shed: 1, Dropped: 1, Errors: 1}
+	if got.classCounts != want {
+		t.Errorf("counts = %+v, want %+v", got.classCounts, want)
+	}
+	if got.Goodput != 2 {
+		t.Errorf("goodput = %v, want 2/s (4 ok in 2s)", got.Goodput)
+	}
+	// 拒绝率只算网关拒绝和本地丢弃，不算在途上限丢弃和错误
+	if got.RejectionRate != 0.25 {
+		t.Errorf("rejection rate = %v, want 0.25", got.RejectionRate)
+	}
+	if got.P50Ms != 20 || got.P90Ms != 40 || got.MaxMs != 40 {
+		t.Errorf("latency = p50 %v p90 %v max %v, want 20 / 40 / 40", got.P50Ms, got.P90Ms, got.MaxMs)
+	}
+	if b := sum.Classes[1]; b.Arrivals != 0 || b.RejectionRate != 0 || b.P99Ms != 0 {
+		t.Errorf("idle class = %+v, want zero values", b)
+	}
+
+	// 每个采样间隔保留最高价，按时间排序
+	wantPrices := []PricePoint{{At: 0, Price: 9}, {At: 2, Price: 3}}
+	if prices := sum.Prices["/mcp"]; len(prices) != len(wantPrices) || prices[0] != wantPrices[0] || prices[1] != wantPrices[1] {
+		t.Errorf("price trajectory = %+v, want %+v", prices, wantPrices)
+	}
+
+	var buf bytes.Buffer
+	if err := sum.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Summary
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Classes[0].Arrivals != 8 || decoded.Classes[0].P50Ms != 20 {
+		t.Errorf("JSON summary = %s", buf.String())
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"rajomon-gateway/pkg/rajomonclient"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 请求结果分类
+const (
+	outcomeOK       = "ok"       // 2xx
+	outcomeRejected = "rejected" // 网关拒绝 (429 / 403，包括超时预算不足的 rejected_deadline)
+	outcomeShed     = "shed"     // 客户端本地丢弃 (出价低于已知价格或钱包为空)
+	outcomeDropped  = "dropped"  // 在途请求达到上限，到达被丢弃
+	outcomeError    = "error"    // 网络错误或 5xx
+)
+
+// user 一个模拟用户：独立的钱包、Client-ID 和 HTTP 客户端
+type user struct {
+	id     string
+	wallet *rajomonclient.Wallet
+	client *http.Client
+}
+
+// Runner 开环压测执行器
+type Runner struct {
+	scenario *Scenario
+	start    time.Time
+	inFlight atomic.Int64
+	wg       sync.WaitGroup
+
+	stats  map[string]*classStats
+	prices *priceTrajectory
+}
+
+func NewRunner(s *Scenario) *Runner {
+	r := &Runner{
+		scenario: s,
+		stats:    make(map[string]*classStats),
+		prices:   newPriceTrajectory(time.Duration(s.SampleInterval)),
+	}
+	for _, c := range s.Classes {
+		r.stats[c.Name] = &classStats{}
+	}
+	return r
+}
+
+// Run 执行场景，到达 Duration 后停止产生新请求，等待在途请求结束 (最多一个请求超时) 后返回汇总
+func (r *Runner) Run(ctx context.Context) *Summary {
+	s := r.scenario
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.Duration))
+	defer cancel()
+
+	r.start = time.Now()
+	var gen sync.WaitGroup
+	for i := range s.Classes {
+		c := &s.Classes[i]
+		users := r.newUsers(ctx, c)
+		rng := rand.New(rand.NewSource(s.Seed + int64(i)))
+		gen.Add(1)
+		go func() {
+			defer gen.Done()
+			r.runClass(ctx, c, users, rng)
+		}()
+	}
+
+	go r.progress(ctx)
+	gen.Wait()
+	elapsed := time.Since(r.start)
+
+	// 开环：停止产生新请求，但让在途请求跑完
+	fmt.Printf("🛑 场景结束，等待 %d 个在途请求...\n", r.inFlight.Load())
+	drained := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(time.Duration(s.Timeout)):
+		fmt.Printf("⚠️ 等待超时，仍有 %d 个请求在途\n", r.inFlight.Load())
+	}
+
+	return r.summary(elapsed)
+}
+
+func (r *Runner) newUsers(ctx context.Context, c *ClassSpec) []*user {
+	users := make([]*user, c.Users)
+	for i := range users {
+		u := &user{id: fmt.Sprintf("%s-%d", c.Name, i)}
+		transport := http.DefaultTransport
+		if c.Bid != "static" {
+			// 钱包出价：每次请求从钱包里扣出价，价格缓存由 Transport 维护
+			u.wallet = rajomonclient.NewWallet(c.Balance, c.MaxBalance)
+			transport = &rajomonclient.Transport{
+				Wallet:   u.wallet,
+				Strategy: rajomonclient.BidStrategy(c.Bid),
+				Prices:   rajomonclient.NewPriceCache(),
+			}
+			if c.Refill.Dist != "" {
+				if _, err := u.wallet.StartTokenGenerator(ctx, c.Refill.Dist, time.Duration(c.Refill.Interval), c.Refill.Step); err != nil {
+					fmt.Printf("⚠️ [%s] 充值配置无效: %v\n", u.id, err)
+				}
+			}
+		}
+		u.client = &http.Client{Timeout: time.Duration(r.scenario.Timeout), Transport: transport}
+		users[i] = u
+	}
+	return users
+}
+
+// runClass 按到达过程产生请求，不等待响应
+func (r *Runner) runClass(ctx context.Context, c *ClassSpec, users []*user, rng *rand.Rand) {
+	process, err := rajomonclient.NewArrivalProcess(c.Arrival.Process, time.Second, rng)
+	if err != nil {
+		fmt.Printf("❌ [%s] %v\n", c.Name, err)
+		return
+	}
+
+	next := 0
+	for {
+		rate, _ := c.Arrival.RateAt(time.Since(r.start))
+		wait := 100 * time.Millisecond // 速率为 0 的阶段：定期检查是否进入下一阶段
+		if rate > 0 {
+			setRate(process, rate, &c.Arrival)
+			wait = process.Next()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if rate <= 0 {
+			continue
+		}
+
+		u := users[next%len(users)]
+		next++
+		r.fire(c, u)
+	}
+}
+
+// setRate 按当前阶段的速率调整到达过程的平均间隔
+func setRate(p rajomonclient.ArrivalProcess, rate float64, a *Arrival) {
+	mean := time.Duration(float64(time.Second) / rate)
+	switch p := p.(type) {
+	case *rajomonclient.PoissonProcess:
+		p.Mean = mean
+	case *rajomonclient.FixedProcess:
+		p.Interval = mean
+	case *rajomonclient.UniformProcess:
+		p.Mean = mean
+	case *rajomonclient.OnOffProcess:
+		// 只在 ON 期间到达，ON 期间的速率放大 (on+off)/on 倍，整体平均速率等于 rate
+		on, off := float64(a.OnDuration), float64(a.OffDuration)
+		p.OnMean = time.Duration(float64(mean) * on / (on + off))
+		p.OnDuration = time.Duration(a.OnDuration)
+		p.OffDuration = time.Duration(a.OffDuration)
+		p.OffMean = 0
+	}
+}
+
+// fire 异步发出一个请求
+func (r *Runner) fire(c *ClassSpec, u *user) {
+	stats := r.stats[c.Name]
+	if r.inFlight.Load() >= int64(r.scenario.MaxInFlight) {
+		stats.record(outcomeDropped, 0)
+		return
+	}
+	r.inFlight.Add(1)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer r.inFlight.Add(-1)
+		outcome, latency := r.do(c, u)
+		stats.record(outcome, latency)
+	}()
+}
+
+func (r *Runner) do(c *ClassSpec, u *user) (string, time.Duration) {
+	var body io.Reader
+	if c.Body != "" {
+		body = strings.NewReader(c.Body)
+	}
+	req, err := http.NewRequest(c.Method, r.scenario.Target+c.Path, body)
+	if err != nil {
+		return outcomeError, 0
+	}
+	for k, v := range c.Header {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Client-ID", u.id)
+	req.Header.Set("User-Agent", c.Name)
+	if c.Bid == "static" {
+		// 旧版行为：每次都带上固定的出价，不扣本地余额
+		req.Header.Set("Token", strconv.FormatInt(c.Balance, 10))
+	}
+
+	start := time.Now()
+	resp, err := u.client.Do(req)
+	if err != nil {
+		if errors.Is(err, rajomonclient.ErrShed) || errors.Is(err, rajomonclient.ErrEmptyWallet) {
+			return outcomeShed, 0
+		}
+		return outcomeError, 0
+	}
+	// 必须读完 Body 才能得到完整的服务端耗时 (SSE 流式响应)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	latency := time.Since(start)
+
+	if price, err := strconv.ParseInt(resp.Header.Get("Price"), 10, 64); err == nil {
+		r.prices.observe(time.Since(r.start), c.Path, price)
+	}
+
+	switch {
+	case resp.StatusCode < 300:
+		return outcomeOK, latency
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden:
+		return outcomeRejected, latency
+	case resp.Header.Get("X-Rajomon-Reject") != "":
+		// 网关的治理拒绝 (如 rejected_deadline)，不是后端故障
+		return outcomeRejected, latency
+	default:
+		return outcomeError, latency
+	}
+}
+
+// progress 每个采样间隔打印一行进度
+func (r *Runner) progress(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(r.scenario.SampleInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var b strings.Builder
+			fmt.Fprintf(&b, "⏱️ %5.1fs | 在途 %d", time.Since(r.start).Seconds(), r.inFlight.Load())
+			for _, c := range r.scenario.Classes {
+				st := r.stats[c.Name].snapshot()
+				fmt.Fprintf(&b, " | %s ✅%d ⛔%d", c.Name, st.OK, st.Rejected+st.Shed)
+			}
+			for path, price := range r.prices.latest() {
+				fmt.Fprintf(&b, " | 🏷️ %s=%d", path, price)
+			}
+			fmt.Println(b.String())
+		}
+	}
+}
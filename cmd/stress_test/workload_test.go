@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/pkg/rajomonclient"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetRate(t *testing.T) {
+	onOff := Arrival{OnDuration: Duration(2 * time.Second), OffDuration: Duration(6 * time.Second)}
+	cases := []struct {
+		name    string
+		process rajomonclient.ArrivalProcess
+		check   func(p rajomonclient.ArrivalProcess) bool
+	}{
+		{"poisson", &rajomonclient.PoissonProcess{}, func(p rajomonclient.ArrivalProcess) bool {
+			return p.(*rajomonclient.PoissonProcess).Mean == 100*time.Millisecond
+		}},
+		{"fixed", &rajomonclient.FixedProcess{}, func(p rajomonclient.ArrivalProcess) bool {
+			return p.(*rajomonclient.FixedProcess).Interval == 100*time.Millisecond
+		}},
+		{"uniform", &rajomonclient.UniformProcess{}, func(p rajomonclient.ArrivalProcess) bool {
+			return p.(*rajomonclient.UniformProcess).Mean == 100*time.Millisecond
+		}},
+		// 只在 ON 期间 (占 1/4) 到达，ON 期间的间隔缩短到 1/4，整体平均速率不变
+		{"on/off", &rajomonclient.OnOffProcess{OffMean: time.Second}, func(p rajomonclient.ArrivalProcess) bool {
+			o := p.(*rajomonclient.OnOffProcess)
+			return o.OnMean == 25*time.Millisecond && o.OffMean == 0 &&
+				o.OnDuration == 2*time.Second && o.OffDuration == 6*time.Second
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRate(tc.process, 10, &onOff)
+			if !tc.check(tc.process) {
+				t.Errorf("setRate(10/s) left %+v", tc.process)
+			}
+		})
+	}
+}
+
+func TestOutcomeClassification(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if r.URL.Query().Get("reject") != "" {
+			w.Header().Set("X-Rajomon-Reject", r.URL.Query().Get("reject"))
+		}
+		w.Header().Set("Price", "7")
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		path   string
+		wallet *rajomonclient.Wallet
+		want   string
+	}{
+		{"2xx", "/200", nil, outcomeOK},
+		{"rate limited", "/429", nil, outcomeRejected},
+		{"forbidden", "/403", nil, outcomeRejected},
+		{"gateway rejection", "/504?reject=rejected_deadline", nil, outcomeRejected},
+		{"backend failure", "/502", nil, outcomeError},
+		{"empty wallet is shed locally", "/200", rajomonclient.NewWallet(0, 10), outcomeShed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Scenario{Target: srv.URL, SampleInterval: Duration(time.Second)}
+			r := NewRunner(s)
+			r.start = time.Now()
+			c := &ClassSpec{Name: "A", Method: "GET", Path: tc.path, Bid: "static", Balance: 10}
+			u := &user{id: "A-0", client: srv.Client()}
+			if tc.wallet != nil {
+				c.Bid = "fixed"
+				u.wallet = tc.wallet
+				u.client = &http.Client{Transport: &rajomonclient.Transport{
+					Base:     srv.Client().Transport,
+					Wallet:   tc.wallet,
+					Strategy: rajomonclient.BidStrategy(c.Bid),
+					Prices:   rajomonclient.NewPriceCache(),
+				}}
+			}
+			if got, _ := r.do(c, u); got != tc.want {
+				t.Errorf("do(%s) = %q, want %q", tc.path, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultFlushInterval 文件存储默认每秒把余额表写回一次
+const DefaultFlushInterval = time.Second
+
+// FileStore 基于 JSON 文件的持久化存储
+// 内存中维护一份余额表，整体写回文件 (先写临时文件再 rename，保证原子替换)
+//
+// interval > 0 时异步落盘：修改只标记为脏，由 Run 按周期写回，准入路径上没有文件 I/O，
+// 进程崩溃最多丢失最近一个周期的流水；interval <= 0 时每次修改都同步写回，扣费落盘失败会回滚
+type FileStore struct {
+	path     string
+	interval time.Duration
+	mem      *MemoryStore
+
+	mu    sync.Mutex // 同步模式下串行化 "修改 + 落盘"，异步模式下保护 dirty
+	dirty bool
+
+	flushMu sync.Mutex // 串行化写文件
+}
+
+// NewFileStore 打开 (或创建) 指定路径的余额文件，interval 为落盘周期
+func NewFileStore(path string, interval time.Duration) (*FileStore, error) {
+	s := &FileStore{path: path, interval: interval, mem: NewMemoryStore()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取账户文件失败: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.mem.balances); err != nil {
+			return nil, fmt.Errorf("解析账户文件 %s 失败: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *FileStore) Open(id string, initial int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok, _ := s.mem.Balance(id); ok {
+		return nil
+	}
+	s.mem.Open(id, initial)
+	return s.changedLocked()
+}
+
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok, _ := s.mem.Balance(id); !ok {
+		return nil
+	}
+	s.mem.Delete(id)
+	return s.changedLocked()
+}
+
+func (s *FileStore) Balance(id string) (int64, bool, error) {
+	return s.mem.Balance(id)
+}
+
+func (s *FileStore) Debit(id string, amount int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balance, err := s.mem.Debit(id, amount)
+	if err != nil {
+		return balance, err
+	}
+	if err := s.changedLocked(); err != nil {
+		// 落盘失败视为扣费失败，回滚内存中的扣减
+		balance, _ = s.mem.Credit(id, amount, 0)
+		return balance, err
+	}
+	return balance, nil
+}
+
+func (s *FileStore) Credit(id string, amount, max int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balance, err := s.mem.Credit(id, amount, max)
+	if err != nil {
+		return balance, err
+	}
+	return balance, s.changedLocked()
+}
+
+func (s *FileStore) CreditAll(amount, max int64) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balances, _ := s.mem.CreditAll(amount, max)
+	return balances, s.changedLocked()
+}
+
+func (s *FileStore) Accounts() (map[string]int64, error) {
+	return s.mem.Accounts()
+}
+
+// changedLocked 余额表有修改：同步模式立即写回，异步模式只标记为脏 (调用方需持有 s.mu)
+func (s *FileStore) changedLocked() error {
+	if s.interval > 0 {
+		s.dirty = true
+		return nil
+	}
+	return s.write()
+}
+
+// Run 异步模式下按周期把修改写回文件，ctx 取消时最后写回一次
+func (s *FileStore) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(); err != nil {
+				fmt.Printf("⚠️ [Ledger] 账户文件落盘失败: %v\n", err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				fmt.Printf("⚠️ [Ledger] 账户文件落盘失败: %v\n", err)
+			}
+		}
+	}
+}
+
+// Flush 有未落盘的修改时写回文件；写失败时保留脏标记，下个周期重试
+func (s *FileStore) Flush() error {
+	s.mu.Lock()
+	dirty := s.dirty
+	s.dirty = false
+	s.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+	if err := s.write(); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// write 把余额表的快照写回文件
+func (s *FileStore) write() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	snapshot, _ := s.mem.Accounts()
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".accounts-*.json")
+	if err != nil {
+		return fmt.Errorf("写入账户文件失败: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入账户文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入账户文件失败: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
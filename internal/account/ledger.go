@@ -0,0 +1,168 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"rajomon-gateway/internal/metrics"
+	"sync"
+	"time"
+)
+
+// 流水类型
+const (
+	KindDebit  = "debit"  // 准入扣费
+	KindRefund = "refund" // 后端失败退款
+	KindRefill = "refill" // 定期充值
+)
+
+// Entry 一条账户流水
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Client  string    `json:"client"`
+	Key     string    `json:"key,omitempty"` // 产生流水的接口 (充值时为空)
+	Kind    string    `json:"kind"`
+	Amount  int64     `json:"amount"`
+	Balance int64     `json:"balance"` // 操作后的余额
+}
+
+// LedgerConfig 账户体系参数
+type LedgerConfig struct {
+	InitialBalance int64        // 开户时的初始余额
+	MaxBalance     int64        // 余额上限 (0 表示不限)
+	Refill         RefillPolicy // 充值策略
+	HistorySize    int          // 内存中保留最近多少条流水
+}
+
+// DefaultLedgerConfig 默认参数：与 cmd/client3 的钱包保持一致 (初始 50，上限 500，平均 200ms 充 15)
+func DefaultLedgerConfig() LedgerConfig {
+	return LedgerConfig{
+		InitialBalance: 50,
+		MaxBalance:     500,
+		Refill:         RefillPolicy{Dist: "poisson", Interval: 200 * time.Millisecond, Step: 15},
+		HistorySize:    1000,
+	}
+}
+
+// Ledger 网关侧的代币账户体系
+// 余额由网关记账，客户端在 Token 头里带的只是 "出价"，真正扣的是账户余额
+type Ledger struct {
+	store Store
+	cfg   LedgerConfig
+
+	mu      sync.Mutex
+	history []Entry // 环形缓冲区
+	next    int
+}
+
+func NewLedger(store Store, cfg LedgerConfig) *Ledger {
+	return &Ledger{store: store, cfg: cfg}
+}
+
+// Provision 为 clients 开户 (已有账户保留余额)，并删除不在列表中的账户 (如 API Key 已被吊销的客户端)
+// 请求路径上不会开户：只有显式开户的客户端才有余额，其余客户端的余额视为 0
+func (l *Ledger) Provision(clients []string) (opened, removed int, err error) {
+	existing, err := l.store.Accounts()
+	if err != nil {
+		return 0, 0, err
+	}
+	keep := make(map[string]bool, len(clients))
+	for _, client := range clients {
+		keep[client] = true
+		if _, ok := existing[client]; ok {
+			continue
+		}
+		if err := l.store.Open(client, l.cfg.InitialBalance); err != nil {
+			return opened, removed, err
+		}
+		opened++
+	}
+	for client := range existing {
+		if keep[client] {
+			continue
+		}
+		if err := l.store.Delete(client); err != nil {
+			return opened, removed, err
+		}
+		removed++
+	}
+	return opened, removed, nil
+}
+
+// Balance 查询余额，没有账户的客户端余额为 0
+func (l *Ledger) Balance(client string) (int64, error) {
+	balance, _, err := l.store.Balance(client)
+	return balance, err
+}
+
+// Debit 准入时原子扣除本次的成交价格，余额不足 (或没有账户) 返回 ErrInsufficientBalance
+func (l *Ledger) Debit(client, key string, amount int64) (int64, error) {
+	balance, err := l.store.Debit(client, amount)
+	if err != nil {
+		return balance, err
+	}
+	l.append(Entry{Client: client, Key: key, Kind: KindDebit, Amount: amount, Balance: balance})
+	return balance, nil
+}
+
+// Refund 退款钩子：后端处理失败时把扣掉的钱还回去
+func (l *Ledger) Refund(client, key string, amount int64) (int64, error) {
+	balance, err := l.store.Credit(client, amount, l.cfg.MaxBalance)
+	if err != nil {
+		return balance, err
+	}
+	l.append(Entry{Client: client, Key: key, Kind: KindRefund, Amount: amount, Balance: balance})
+	return balance, nil
+}
+
+// History 返回最近的流水 (按时间从旧到新)
+func (l *Ledger) History() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.history) < l.cfg.HistorySize {
+		return append([]Entry(nil), l.history...)
+	}
+	out := make([]Entry, 0, len(l.history))
+	out = append(out, l.history[l.next:]...)
+	return append(out, l.history[:l.next]...)
+}
+
+func (l *Ledger) append(e Entry) {
+	e.Time = time.Now()
+	metrics.LedgerTokens.WithLabelValues(e.Kind).Add(float64(e.Amount))
+	if l.cfg.HistorySize <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.history) < l.cfg.HistorySize {
+		l.history = append(l.history, e)
+		return
+	}
+	l.history[l.next] = e
+	l.next = (l.next + 1) % l.cfg.HistorySize
+}
+
+// StartRefill 按充值策略给所有账户定期充值，直到 ctx 被取消
+func (l *Ledger) StartRefill(ctx context.Context) {
+	policy := l.cfg.Refill
+	if policy.Step <= 0 || policy.Interval <= 0 {
+		return
+	}
+	go func() {
+		fmt.Printf("🔋 [Ledger] 账户充值启动 | 模式: %s | 速率: %v/次 | 步长: %d\n", policy.Dist, policy.Interval, policy.Step)
+		policy.run(ctx, l.refill)
+	}()
+}
+
+// refill 一次充值：所有账户批量入账
+func (l *Ledger) refill(amount int64) {
+	balances, err := l.store.CreditAll(amount, l.cfg.MaxBalance)
+	if err != nil {
+		fmt.Printf("⚠️ [Ledger] 充值失败: %v\n", err)
+		return
+	}
+	for client, balance := range balances {
+		l.append(Entry{Client: client, Kind: KindRefill, Amount: amount, Balance: balance})
+	}
+}
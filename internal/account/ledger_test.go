@@ -0,0 +1,193 @@
+package account
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestLedger(t *testing.T, cfg LedgerConfig, clients ...string) *Ledger {
+	t.Helper()
+	l := NewLedger(NewMemoryStore(), cfg)
+	if _, _, err := l.Provision(clients); err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	return l
+}
+
+func TestLedgerDebitAndRefund(t *testing.T) {
+	cases := []struct {
+		name        string
+		client      string
+		debit       int64
+		refund      int64
+		wantErr     error
+		wantBalance int64
+	}{
+		{name: "enough balance", client: "alice", debit: 30, wantBalance: 20},
+		{name: "exact balance", client: "alice", debit: 50, wantBalance: 0},
+		{name: "balance too low leaves it unchanged", client: "alice", debit: 51, wantErr: ErrInsufficientBalance, wantBalance: 50},
+		{name: "unknown client has no balance", client: "mallory", debit: 1, wantErr: ErrInsufficientBalance, wantBalance: 0},
+		{name: "refund restores the debit", client: "alice", debit: 30, refund: 30, wantBalance: 50},
+		{name: "refund is capped at MaxBalance", client: "alice", debit: 10, refund: 100, wantBalance: 60},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newTestLedger(t, LedgerConfig{InitialBalance: 50, MaxBalance: 60, HistorySize: 10}, "alice")
+
+			_, err := l.Debit(tc.client, "/mcp", tc.debit)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Debit(%d) error = %v, want %v", tc.debit, err, tc.wantErr)
+			}
+			if tc.refund > 0 {
+				if _, err := l.Refund(tc.client, "/mcp", tc.refund); err != nil {
+					t.Fatalf("Refund: %v", err)
+				}
+			}
+			if balance, _ := l.Balance(tc.client); balance != tc.wantBalance {
+				t.Errorf("balance = %d, want %d", balance, tc.wantBalance)
+			}
+		})
+	}
+}
+
+func TestLedgerRefundUnknownClient(t *testing.T) {
+	l := newTestLedger(t, DefaultLedgerConfig(), "alice")
+	if _, err := l.Refund("mallory", "/mcp", 10); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("Refund for unknown client error = %v, want ErrNoAccount", err)
+	}
+	if accounts, _ := l.store.Accounts(); len(accounts) != 1 {
+		t.Errorf("accounts = %v, want only alice (refund must not open accounts)", accounts)
+	}
+}
+
+func TestLedgerProvision(t *testing.T) {
+	l := newTestLedger(t, LedgerConfig{InitialBalance: 50}, "alice", "bob")
+	if _, err := l.Debit("alice", "/mcp", 20); err != nil {
+		t.Fatalf("Debit: %v", err)
+	}
+
+	opened, removed, err := l.Provision([]string{"alice", "carol"})
+	if err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	if opened != 1 || removed != 1 {
+		t.Errorf("Provision = opened %d removed %d, want 1 and 1", opened, removed)
+	}
+	want := map[string]int64{"alice": 30, "carol": 50}
+	accounts, _ := l.store.Accounts()
+	if len(accounts) != len(want) {
+		t.Fatalf("accounts = %v, want %v", accounts, want)
+	}
+	for client, balance := range want {
+		if accounts[client] != balance {
+			t.Errorf("balance[%s] = %d, want %d (existing accounts keep their balance)", client, accounts[client], balance)
+		}
+	}
+}
+
+func TestLedgerRefillCreditsAllAccounts(t *testing.T) {
+	l := newTestLedger(t, LedgerConfig{InitialBalance: 50, MaxBalance: 60, HistorySize: 10}, "alice", "bob")
+	if _, err := l.Debit("alice", "/mcp", 40); err != nil {
+		t.Fatalf("Debit: %v", err)
+	}
+
+	l.refill(15)
+	for client, want := range map[string]int64{"alice": 25, "bob": 60} {
+		if balance, _ := l.Balance(client); balance != want {
+			t.Errorf("balance[%s] after refill = %d, want %d", client, balance, want)
+		}
+	}
+	if n := len(l.History()); n != 3 {
+		t.Errorf("history has %d entries, want 3 (one debit + one refill per account)", n)
+	}
+}
+
+func TestLedgerHistoryWraps(t *testing.T) {
+	cases := []struct {
+		name    string
+		size    int
+		debits  int
+		wantLen int
+		wantOld int64 // 最旧一条流水的金额
+	}{
+		{name: "not full", size: 4, debits: 3, wantLen: 3, wantOld: 1},
+		{name: "exactly full", size: 4, debits: 4, wantLen: 4, wantOld: 1},
+		{name: "wrapped", size: 4, debits: 6, wantLen: 4, wantOld: 3},
+		{name: "wrapped twice", size: 4, debits: 9, wantLen: 4, wantOld: 6},
+		{name: "disabled", size: 0, debits: 3, wantLen: 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newTestLedger(t, LedgerConfig{InitialBalance: 1000, HistorySize: tc.size}, "alice")
+			for i := 1; i <= tc.debits; i++ {
+				if _, err := l.Debit("alice", "/mcp", int64(i)); err != nil {
+					t.Fatalf("Debit: %v", err)
+				}
+			}
+
+			history := l.History()
+			if len(history) != tc.wantLen {
+				t.Fatalf("len(History()) = %d, want %d", len(history), tc.wantLen)
+			}
+			for i, e := range history {
+				if want := tc.wantOld + int64(i); e.Amount != want {
+					t.Errorf("History()[%d].Amount = %d, want %d (oldest first)", i, e.Amount, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStoreRollsBackDebitWhenFlushFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "accounts.json")
+	s, err := NewFileStore(path, 0)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if err := s.Open("alice", 50); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// 目录没了，临时文件建不出来，落盘必然失败
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Debit("alice", 20); err == nil {
+		t.Fatal("Debit succeeded although the flush failed")
+	}
+	if balance, _, _ := s.Balance("alice"); balance != 50 {
+		t.Errorf("balance after failed flush = %d, want 50 (debit rolled back)", balance)
+	}
+}
+
+func TestFileStoreAsyncFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	s, err := NewFileStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	s.Open("alice", 50)
+	s.Open("bob", 50)
+	if _, err := s.CreditAll(10, 0); err != nil {
+		t.Fatalf("CreditAll: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file written before Flush (stat err = %v), want writes deferred to the flush interval", err)
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	reopened, err := NewFileStore(path, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	accounts, _ := reopened.Accounts()
+	if accounts["alice"] != 60 || accounts["bob"] != 60 {
+		t.Errorf("reloaded accounts = %v, want alice and bob at 60", accounts)
+	}
+}
@@ -0,0 +1,91 @@
+package account
+
+import "sync"
+
+// MemoryStore 进程内存储，网关重启后余额清零
+type MemoryStore struct {
+	mu       sync.Mutex
+	balances map[string]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{balances: make(map[string]int64)}
+}
+
+func (s *MemoryStore) Open(id string, initial int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.balances[id]; !exists {
+		s.balances[id] = initial
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.balances, id)
+	return nil
+}
+
+func (s *MemoryStore) Balance(id string) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balance, ok := s.balances[id]
+	return balance, ok, nil
+}
+
+func (s *MemoryStore) Debit(id string, amount int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balance, ok := s.balances[id]
+	if !ok || balance < amount {
+		return balance, ErrInsufficientBalance
+	}
+	balance -= amount
+	s.balances[id] = balance
+	return balance, nil
+}
+
+func (s *MemoryStore) Credit(id string, amount, max int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balance, ok := s.balances[id]
+	if !ok {
+		return 0, ErrNoAccount
+	}
+	balance = capBalance(balance+amount, max)
+	s.balances[id] = balance
+	return balance, nil
+}
+
+func (s *MemoryStore) CreditAll(amount, max int64) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, balance := range s.balances {
+		s.balances[id] = capBalance(balance+amount, max)
+	}
+	return s.snapshotLocked(), nil
+}
+
+func (s *MemoryStore) Accounts() (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshotLocked(), nil
+}
+
+func (s *MemoryStore) snapshotLocked() map[string]int64 {
+	snapshot := make(map[string]int64, len(s.balances))
+	for id, balance := range s.balances {
+		snapshot[id] = balance
+	}
+	return snapshot
+}
+
+// capBalance 限制最大余额，防止无限囤积 (max <= 0 表示不限)
+func capBalance(balance, max int64) int64 {
+	if max > 0 && balance > max {
+		return max
+	}
+	return balance
+}
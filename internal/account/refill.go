@@ -0,0 +1,62 @@
+package account
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RefillPolicy 账户充值策略 (与 cmd/client3 的代币生成器一致)
+// Dist: "poisson" (泊松), "fixed" (固定), "uniform" (均匀随机)
+type RefillPolicy struct {
+	Dist     string        // 分布类型
+	Interval time.Duration // 平均充值间隔
+	Step     int64         // 每次充值的基准数量
+}
+
+// run 按分布产生充值事件，每次事件调用 credit(amount)
+func (p RefillPolicy) run(ctx context.Context, credit func(amount int64)) {
+	if p.Dist == "poisson" {
+		// --- 模式 A: 泊松过程 ---
+		// 到达间隔服从指数分布，均值为 Interval
+		timer := time.NewTimer(p.nextPoissonInterval())
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+				credit(p.Step)
+				timer.Reset(p.nextPoissonInterval())
+			}
+		}
+	}
+
+	// --- 模式 B & C: 基于 Ticker 的定期生成 ---
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			amount := p.Step
+			if p.Dist == "uniform" {
+				// 在 0 ~ 2*step 之间波动，平均值依然是 step
+				amount = rand.Int63n(p.Step*2 + 1)
+			}
+			if amount > 0 {
+				credit(amount)
+			}
+		}
+	}
+}
+
+// nextPoissonInterval 指数分布的下一次间隔，至少 1ms
+func (p RefillPolicy) nextPoissonInterval() time.Duration {
+	interval := time.Duration(rand.ExpFloat64() * float64(p.Interval))
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	return interval
+}
@@ -0,0 +1,30 @@
+package account
+
+import "errors"
+
+var (
+	// ErrInsufficientBalance 余额不足，扣费失败 (没有账户的客户端余额视为 0)
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrNoAccount 账户不存在
+	ErrNoAccount = errors.New("account not found")
+)
+
+// Store 账户余额存储 (按客户端身份索引)
+// 所有方法都必须是并发安全的，Debit 必须是原子的 "检查 + 扣减"
+// 账户只能通过 Open 显式创建，其余方法都不会隐式开户
+type Store interface {
+	// Open 创建账户并设置初始余额；账户已存在时不做任何修改
+	Open(id string, initial int64) error
+	// Delete 删除账户，账户不存在时不做任何操作
+	Delete(id string) error
+	// Balance 查询余额，账户不存在时 ok=false
+	Balance(id string) (balance int64, ok bool, err error)
+	// Debit 原子扣费，余额不足 (或账户不存在) 时返回 ErrInsufficientBalance 且不扣减
+	Debit(id string, amount int64) (int64, error)
+	// Credit 入账 (退款)；max > 0 时余额不超过 max，账户不存在时返回 ErrNoAccount
+	Credit(id string, amount, max int64) (int64, error)
+	// CreditAll 给所有账户入账 (定期充值)，一次批量更新，返回入账后的余额
+	CreditAll(amount, max int64) (map[string]int64, error)
+	// Accounts 返回所有账户的余额快照
+	Accounts() (map[string]int64, error)
+}
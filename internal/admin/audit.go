@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"rajomon-gateway/internal/metrics"
+	"sync"
+	"time"
+)
+
+// 审计结果
+const (
+	ResultOK     = "ok"
+	ResultError  = "error"
+	ResultDenied = "denied" // 鉴权失败
+)
+
+// recentAudit 内存中保留的最近审计记录条数 (GET /admin/audit)
+const recentAudit = 256
+
+// AuditEntry 一次变更操作的审计记录
+type AuditEntry struct {
+	Time   time.Time `json:"ts"`
+	Actor  string    `json:"actor"`  // 操作人 (admin.tokens 的键)，鉴权失败时为空
+	Remote string    `json:"remote"` // 来源地址
+	Action string    `json:"action"` // override / clear_override / reset / params ...
+	Key    string    `json:"key,omitempty"`
+	Detail any       `json:"detail,omitempty"` // 请求参数
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// AuditLog 审计日志：写入 JSONL 文件 (可选)、打印日志、更新指标，并在内存中保留最近的记录
+type AuditLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	recent []AuditEntry
+}
+
+// NewAuditLog w 为空时只打印日志，不落盘
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+// OpenAuditLog 以追加方式打开审计日志文件
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	a := NewAuditLog(f)
+	a.closer = f
+	return a, nil
+}
+
+// Record 记录一次操作
+func (a *AuditLog) Record(e AuditEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	metrics.AdminActions.WithLabelValues(e.Action, e.Result).Inc()
+
+	actor := e.Actor
+	if actor == "" {
+		actor = "匿名"
+	}
+	if e.Result == ResultOK {
+		fmt.Printf("📝 [Audit] %s(%s) %s %s -> ok\n", actor, e.Remote, e.Action, e.Key)
+	} else {
+		fmt.Printf("📝 [Audit] %s(%s) %s %s -> %s: %s\n", actor, e.Remote, e.Action, e.Key, e.Result, e.Error)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.recent) == recentAudit {
+		a.recent = append(a.recent[:0], a.recent[1:]...)
+	}
+	a.recent = append(a.recent, e)
+	if a.w != nil {
+		line, err := json.Marshal(e)
+		if err == nil {
+			_, err = a.w.Write(append(line, '\n'))
+		}
+		if err != nil {
+			fmt.Printf("⚠️ [Audit] 写入审计日志失败: %v\n", err)
+		}
+	}
+}
+
+// Recent 最近 n 条审计记录 (新的在后)
+func (a *AuditLog) Recent(n int) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n <= 0 || n > len(a.recent) {
+		n = len(a.recent)
+	}
+	return append([]AuditEntry(nil), a.recent[len(a.recent)-n:]...)
+}
+
+// Close 关闭审计日志文件
+func (a *AuditLog) Close() error {
+	if a.closer != nil {
+		return a.closer.Close()
+	}
+	return nil
+}
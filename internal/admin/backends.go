@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"rajomon-gateway/internal/balancer"
+	"sort"
+)
+
+// BackendInfo 一个后端的状态 (同一个后端出现在多个池里时每个池各一条)
+type BackendInfo struct {
+	Pool        string  `json:"pool"`
+	URL         string  `json:"url"`
+	State       string  `json:"state"` // healthy / unhealthy / ejected / half_open
+	Draining    bool    `json:"draining"`
+	Breaker     string  `json:"breaker,omitempty"` // closed / open / half_open，未启用熔断时为空
+	InFlight    int64   `json:"in_flight"`
+	Price       int64   `json:"price"` // 后端广播的价格
+	EWMALatency float64 `json:"ewma_latency_ms"`
+}
+
+// backendRequest 人工摘除 / 恢复后端
+type backendRequest struct {
+	Backend string `json:"backend"` // host:port 或完整 URL
+	Pool    string `json:"pool"`    // 为空时作用于所有包含该后端的池
+}
+
+// listBackends GET /admin/backends
+func (s *Server) listBackends(w http.ResponseWriter, r *request) {
+	var infos []BackendInfo
+	for name, lb := range s.gw.Pools() {
+		for _, b := range lb.Backends() {
+			infos = append(infos, BackendInfo{
+				Pool:        name,
+				URL:         b.URL.String(),
+				State:       b.State(),
+				Draining:    b.Draining(),
+				Breaker:     b.BreakerState(),
+				InFlight:    b.InFlight(),
+				Price:       b.Price(),
+				EWMALatency: b.EWMALatency(),
+			})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Pool != infos[j].Pool {
+			return infos[i].Pool < infos[j].Pool
+		}
+		return infos[i].URL < infos[j].URL
+	})
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// drainBackend POST /admin/backends/drain
+func (s *Server) drainBackend(w http.ResponseWriter, r *request) {
+	s.setDraining(w, r, "drain", (*balancer.Backend).Drain)
+}
+
+// enableBackend POST /admin/backends/enable
+func (s *Server) enableBackend(w http.ResponseWriter, r *request) {
+	s.setDraining(w, r, "enable", (*balancer.Backend).Enable)
+}
+
+// setDraining 在匹配的后端上执行 apply
+// 注意：后端池因配置变化重建时会沿用人工摘除，但后端从配置里删掉后再加回来就不会了
+func (s *Server) setDraining(w http.ResponseWriter, r *request, action string, apply func(*balancer.Backend)) {
+	var req backendRequest
+	err := decode(r, &req)
+	if err == nil && req.Backend == "" {
+		err = errors.New("backend 不能为空")
+	}
+	status := http.StatusBadRequest
+	var matched []BackendInfo
+	if err == nil {
+		host := req.Backend
+		if u, perr := url.Parse(req.Backend); perr == nil && u.Host != "" {
+			host = u.Host
+		}
+		for name, lb := range s.gw.Pools() {
+			if req.Pool != "" && req.Pool != name {
+				continue
+			}
+			for _, b := range lb.Backends() {
+				if b.URL.Host == host {
+					apply(b)
+					matched = append(matched, BackendInfo{Pool: name, URL: b.URL.String(), State: b.State(), Draining: b.Draining()})
+				}
+			}
+		}
+		if len(matched) == 0 {
+			err, status = fmt.Errorf("没有找到后端 %q", req.Backend), http.StatusNotFound
+		}
+	}
+	s.record(r, action, req.Backend, req, err)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, matched)
+}
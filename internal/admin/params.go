@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"rajomon-gateway/internal/config"
+)
+
+// paramsView 定价参数
+type paramsView struct {
+	Key         string                   `json:"key,omitempty"` // 查询单个 Key 时返回它实际使用的策略
+	Policy      config.PolicyConfig      `json:"policy"`
+	Aggregation string                   `json:"aggregation"`
+	UpdateLoop  *config.UpdateLoopConfig `json:"update_loop"`
+
+	Routes map[string]config.PolicyConfig `json:"routes,omitempty"` // 按路由覆盖的策略
+	Keys   map[string]config.PolicyConfig `json:"keys,omitempty"`   // 按 Key 覆盖的策略
+}
+
+// paramsRequest 运行时调参，只需要写要改的字段
+type paramsRequest struct {
+	Key         string          `json:"key"`         // 为空表示默认策略
+	Policy      json.RawMessage `json:"policy"`      // 叠加到该 Key 当前的策略参数上
+	UpdateLoop  json.RawMessage `json:"update_loop"` // 叠加到当前周期调价参数上；null 表示关闭周期调价
+	Aggregation *string         `json:"aggregation"`
+}
+
+func newParamsView(cfg *config.Config, key string) paramsView {
+	v := paramsView{
+		Key:         key,
+		Policy:      cfg.PolicyFor(key),
+		Aggregation: cfg.Pricing.Aggregation,
+		UpdateLoop:  cfg.Pricing.UpdateLoop,
+	}
+	if key != "" {
+		return v
+	}
+	for _, r := range cfg.Routes {
+		if r.Pricing != nil {
+			if v.Routes == nil {
+				v.Routes = make(map[string]config.PolicyConfig)
+			}
+			v.Routes[r.Path] = *r.Pricing
+		}
+	}
+	v.Keys = cfg.Pricing.Keys
+	return v
+}
+
+// getParams GET /admin/params[?key=]
+func (s *Server) getParams(w http.ResponseWriter, r *request) {
+	writeJSON(w, http.StatusOK, newParamsView(s.gw.Config(), r.URL.Query().Get("key")))
+}
+
+// updateParams PUT /admin/params
+// 修改在当前配置的副本上进行，校验通过后走和热加载相同的路径生效 (已有 Key 的价格和 EWMA 保留)
+func (s *Server) updateParams(w http.ResponseWriter, r *request) {
+	var req paramsRequest
+	err := decode(r, &req)
+	if err == nil {
+		err = s.gw.Update("admin:"+r.actor, func(cfg *config.Config) error {
+			if len(req.Policy) > 0 {
+				policy, err := cfg.PolicyFor(req.Key).Patch(req.Policy)
+				if err != nil {
+					return err
+				}
+				cfg.SetPolicy(req.Key, policy)
+			}
+			if string(req.UpdateLoop) == "null" {
+				cfg.Pricing.UpdateLoop = nil
+			} else if len(req.UpdateLoop) > 0 {
+				if err := cfg.PatchUpdateLoop(req.UpdateLoop); err != nil {
+					return err
+				}
+			}
+			if req.Aggregation != nil {
+				cfg.Pricing.Aggregation = *req.Aggregation
+			}
+			return nil
+		})
+	}
+	s.record(r, "params", req.Key, req, err)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newParamsView(s.gw.Config(), req.Key))
+}
+
+// reloadConfig POST /admin/config/reload 和 SIGHUP 一样重新读取配置文件
+func (s *Server) reloadConfig(w http.ResponseWriter, r *request) {
+	if s.load == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("网关没有配置加载方式"))
+		return
+	}
+	err := s.gw.Reload("admin:"+r.actor, s.load)
+	s.record(r, "reload", "", nil, err)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cfg := s.gw.Config()
+	writeJSON(w, http.StatusOK, map[string]int{"routes": len(cfg.Routes), "pools": len(cfg.Pools)})
+}
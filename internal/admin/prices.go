@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"rajomon-gateway/internal/config"
+	"rajomon-gateway/internal/controller"
+	"strconv"
+	"time"
+)
+
+// overrideRequest 价格干预请求
+type overrideRequest struct {
+	Key    string          `json:"key"`
+	Kind   string          `json:"kind"`  // pin / floor / ceil
+	Price  *int            `json:"price"` // 必填 (0 也是合法价格)
+	For    config.Duration `json:"for"`   // 有效期，不写表示一直生效直到撤销
+	Reason string          `json:"reason"`
+}
+
+// listKeys GET /admin/keys
+func (s *Server) listKeys(w http.ResponseWriter, r *request) {
+	writeJSON(w, http.StatusOK, s.gw.Controller().Snapshot())
+}
+
+// setOverride POST /admin/overrides
+func (s *Server) setOverride(w http.ResponseWriter, r *request) {
+	var req overrideRequest
+	err := decode(r, &req)
+	if err == nil {
+		switch {
+		case req.Key == "":
+			err = errors.New("key 不能为空")
+		case req.Price == nil:
+			err = errors.New("price 不能为空")
+		case req.For < 0:
+			err = errors.New("for 不能为负数")
+		}
+	}
+	var o controller.Override
+	if err == nil {
+		o = controller.Override{Kind: req.Kind, Price: *req.Price}
+		if req.For > 0 {
+			o.Expires = time.Now().Add(time.Duration(req.For))
+		}
+		err = s.gw.Controller().SetOverride(req.Key, o)
+	}
+	s.record(r, "override", req.Key, req, err)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// clearOverride DELETE /admin/overrides?key=&kind= (kind 为空撤销全部)
+func (s *Server) clearOverride(w http.ResponseWriter, r *request) {
+	key, kind := r.URL.Query().Get("key"), r.URL.Query().Get("kind")
+	detail := map[string]string{"kind": kind}
+	if key == "" {
+		err := errors.New("key 不能为空")
+		s.record(r, "clear_override", key, detail, err)
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !s.gw.Controller().ClearOverride(key, kind) {
+		err := fmt.Errorf("%s 没有生效的价格干预", key)
+		s.record(r, "clear_override", key, detail, err)
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.record(r, "clear_override", key, detail, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resetKey POST /admin/keys/reset
+func (s *Server) resetKey(w http.ResponseWriter, r *request) {
+	var req struct {
+		Key string `json:"key"`
+	}
+	err := decode(r, &req)
+	if err == nil && req.Key == "" {
+		err = errors.New("key 不能为空")
+	}
+	status := http.StatusBadRequest
+	if err == nil && !s.gw.Controller().Reset(req.Key) {
+		err, status = fmt.Errorf("未知的 Key %q", req.Key), http.StatusNotFound
+	}
+	s.record(r, "reset", req.Key, nil, err)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listAudit GET /admin/audit?limit=
+func (s *Server) listAudit(w http.ResponseWriter, r *request) {
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("limit 不是整数: %q", v))
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, s.audit.Recent(limit))
+}
@@ -0,0 +1,179 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"rajomon-gateway/internal/config"
+	"rajomon-gateway/internal/gateway"
+	"strings"
+)
+
+// Server 管理接口：查看定价状态、人工干预价格、运行时调参
+// 独立端口 (admin_listen)，所有请求都要带 Authorization: Bearer <token>，所有变更都记审计
+//
+//	GET    /admin/keys              所有 Key 的价格、EWMA 延迟/Token、成本、干预
+//	POST   /admin/overrides         {"key","kind":"pin|floor|ceil","price","for":"10m","reason"}
+//	DELETE /admin/overrides?key=&kind=
+//	POST   /admin/keys/reset        {"key"}
+//	GET    /admin/params[?key=]     定价策略参数
+//	PUT    /admin/params            {"key","policy":{...},"update_loop":{...},"aggregation"} 只写要改的字段
+//	GET    /admin/audit[?limit=]    最近的审计记录
+//	GET    /admin/backends          所有后端池里的后端状态
+//	POST   /admin/backends/drain    {"backend":"host:port","pool"} 人工摘除 (pool 为空作用于所有池)
+//	POST   /admin/backends/enable   {"backend":"host:port","pool"} 撤销人工摘除
+//	POST   /admin/config/reload     重新读取配置文件 (同 SIGHUP)
+type Server struct {
+	gw     *gateway.Gateway
+	tokens map[string]string // Token -> 操作人
+	audit  *AuditLog
+	load   func() (*config.Config, error)
+	mux    *http.ServeMux
+}
+
+// Option 管理接口的可选配置
+type Option func(*Server)
+
+// WithConfigLoader 设置 POST /admin/config/reload 使用的配置加载方式 (与 SIGHUP 相同)
+func WithConfigLoader(load func() (*config.Config, error)) Option {
+	return func(s *Server) {
+		s.load = load
+	}
+}
+
+// NewServer 创建管理接口
+func NewServer(gw *gateway.Gateway, cfg *config.AdminConfig, opts ...Option) (*Server, error) {
+	if cfg == nil || len(cfg.Tokens) == 0 {
+		return nil, errors.New("管理接口至少需要一个 Token")
+	}
+	s := &Server{gw: gw, tokens: make(map[string]string, len(cfg.Tokens)), mux: http.NewServeMux()}
+	for actor, token := range cfg.Tokens {
+		s.tokens[token] = actor
+	}
+	if cfg.AuditFile != "" {
+		audit, err := OpenAuditLog(cfg.AuditFile)
+		if err != nil {
+			return nil, fmt.Errorf("admin.audit_file: %w", err)
+		}
+		s.audit = audit
+	} else {
+		s.audit = NewAuditLog(nil)
+	}
+
+	s.handle("GET /admin/keys", s.listKeys)
+	s.handle("POST /admin/overrides", s.setOverride)
+	s.handle("DELETE /admin/overrides", s.clearOverride)
+	s.handle("POST /admin/keys/reset", s.resetKey)
+	s.handle("GET /admin/params", s.getParams)
+	s.handle("PUT /admin/params", s.updateParams)
+	s.handle("GET /admin/audit", s.listAudit)
+	s.handle("GET /admin/backends", s.listBackends)
+	s.handle("POST /admin/backends/drain", s.drainBackend)
+	s.handle("POST /admin/backends/enable", s.enableBackend)
+	s.handle("POST /admin/config/reload", s.reloadConfig)
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close 关闭审计日志
+func (s *Server) Close() error {
+	return s.audit.Close()
+}
+
+// request 一次已通过鉴权的管理请求
+type request struct {
+	*http.Request
+	actor string
+}
+
+// handle 注册路由并套上鉴权：Token 不对直接 401，变更类请求 (非 GET) 的失败尝试也记审计
+func (s *Server) handle(pattern string, h func(w http.ResponseWriter, r *request)) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := s.authenticate(r)
+		if !ok {
+			if r.Method != http.MethodGet {
+				s.audit.Record(AuditEntry{Remote: r.RemoteAddr, Action: actionName(r), Result: ResultDenied, Error: "invalid token"})
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rajomon-admin"`)
+			writeError(w, http.StatusUnauthorized, errors.New("未授权"))
+			return
+		}
+		h(w, &request{Request: r, actor: actor})
+	})
+}
+
+// authenticate 校验 Bearer Token，返回操作人
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	// 逐个做常数时间比较，避免通过响应时间猜 Token
+	for t, actor := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return actor, true
+		}
+	}
+	return "", false
+}
+
+// record 记录一次变更操作的审计
+func (s *Server) record(r *request, action, key string, detail any, err error) {
+	e := AuditEntry{Actor: r.actor, Remote: r.RemoteAddr, Action: action, Key: key, Detail: detail, Result: ResultOK}
+	if err != nil {
+		e.Result, e.Error = ResultError, err.Error()
+	}
+	s.audit.Record(e)
+}
+
+// actionName 鉴权失败时用路径推断操作名
+func actionName(r *http.Request) string {
+	switch {
+	case r.URL.Path == "/admin/overrides" && r.Method == http.MethodDelete:
+		return "clear_override"
+	case r.URL.Path == "/admin/overrides":
+		return "override"
+	case r.URL.Path == "/admin/keys/reset":
+		return "reset"
+	case r.URL.Path == "/admin/params":
+		return "params"
+	case r.URL.Path == "/admin/backends/drain":
+		return "drain"
+	case r.URL.Path == "/admin/backends/enable":
+		return "enable"
+	case r.URL.Path == "/admin/config/reload":
+		return "reload"
+	}
+	return "unknown"
+}
+
+// decode 严格解析 JSON 请求体
+func decode(r *request, v any) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("请求体无效: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
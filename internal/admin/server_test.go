@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/config"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/gateway"
+	"rajomon-gateway/internal/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const token = "secret"
+
+func newServer(t *testing.T) (*Server, *gateway.Gateway) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Routes = []config.RouteConfig{{Path: "/context", Handler: "context", Key: "path"}}
+	gw, err := gateway.New(cfg)
+	if err != nil {
+		t.Fatalf("gateway.New: %v", err)
+	}
+	s, err := NewServer(gw, &config.AdminConfig{Tokens: map[string]string{"alice": token}})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return s, gw
+}
+
+func do(t *testing.T, s *Server, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRejectsMissingOrWrongToken(t *testing.T) {
+	s, _ := newServer(t)
+	denied := testutil.ToFloat64(metrics.AdminActions.WithLabelValues("override", ResultDenied))
+
+	for _, auth := range []string{"", "Bearer wrong", token} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/overrides", strings.NewReader(`{"key":"/context","kind":"pin","price":1}`))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status = %d, want 401", auth, rec.Code)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.AdminActions.WithLabelValues("override", ResultDenied)) - denied; got != 3 {
+		t.Errorf("denied override actions = %v, want 3", got)
+	}
+	if got := s.audit.Recent(0); len(got) != 3 || got[0].Result != ResultDenied {
+		t.Errorf("audit = %+v, want 3 denied entries", got)
+	}
+}
+
+func TestPinOverridesPriceUntilExpiry(t *testing.T) {
+	s, gw := newServer(t)
+	ctrl := gw.Controller()
+	base := ctrl.GetPrice("/context")
+
+	rec := do(t, s, http.MethodPost, "/admin/overrides", `{"key":"/context","kind":"pin","price":42,"for":"50ms","reason":"incident"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/overrides = %d %s", rec.Code, rec.Body)
+	}
+	if got := ctrl.GetPrice("/context"); got != 42 {
+		t.Errorf("price while pinned = %d, want 42", got)
+	}
+	if got := testutil.ToFloat64(metrics.PriceOverride.WithLabelValues("/context", controller.OverridePin)); got != 42 {
+		t.Errorf("override gauge = %v, want 42", got)
+	}
+
+	var keys []controller.KeyInfo
+	rec = do(t, s, http.MethodGet, "/admin/keys", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &keys); err != nil {
+		t.Fatalf("GET /admin/keys: %v (%s)", err, rec.Body)
+	}
+	if len(keys) != 1 || keys[0].Price != 42 || keys[0].OwnPrice != base || len(keys[0].Overrides) != 1 {
+		t.Errorf("keys = %+v, want /context at 42 with own price %d and one override", keys, base)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := ctrl.GetPrice("/context"); got != base {
+		t.Errorf("price after expiry = %d, want %d", got, base)
+	}
+
+	entries := s.audit.Recent(0)
+	if len(entries) != 1 || entries[0].Actor != "alice" || entries[0].Action != "override" || entries[0].Result != ResultOK {
+		t.Errorf("audit = %+v, want one ok override by alice", entries)
+	}
+}
+
+func TestFloorAndCeilAreConsistent(t *testing.T) {
+	s, gw := newServer(t)
+
+	if rec := do(t, s, http.MethodPost, "/admin/overrides", `{"key":"/context","kind":"floor","price":20}`); rec.Code != http.StatusOK {
+		t.Fatalf("floor = %d %s", rec.Code, rec.Body)
+	}
+	if got := gw.Controller().GetPrice("/context"); got != 20 {
+		t.Errorf("price with floor 20 = %d, want 20", got)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/overrides", `{"key":"/context","kind":"ceil","price":10}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("ceil below floor = %d, want 400", rec.Code)
+	}
+	if rec := do(t, s, http.MethodDelete, "/admin/overrides?key=/context", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE /admin/overrides = %d, want 204", rec.Code)
+	}
+	if rec := do(t, s, http.MethodDelete, "/admin/overrides?key=/context", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second DELETE /admin/overrides = %d, want 404", rec.Code)
+	}
+}
+
+func TestResetKey(t *testing.T) {
+	s, gw := newServer(t)
+	ctrl := gw.Controller()
+	ctrl.GetPrice("/context")
+	ctrl.RecordLatency("/context", 2*time.Second, 1000)
+
+	if rec := do(t, s, http.MethodPost, "/admin/keys/reset", `{"key":"/context"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("reset = %d %s", rec.Code, rec.Body)
+	}
+	if _, ok := ctrl.State("/context"); ok {
+		t.Error("state still exists after reset")
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/keys/reset", `{"key":"/nope"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("reset unknown key = %d, want 404", rec.Code)
+	}
+}
+
+func TestUpdateParamsPatchesCurrentPolicy(t *testing.T) {
+	s, gw := newServer(t)
+
+	rec := do(t, s, http.MethodPut, "/admin/params", `{"key":"/context","policy":{"composite":{"base_threshold":999}}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /admin/params = %d %s", rec.Code, rec.Body)
+	}
+	p, ok := gw.Controller().Policy("/context").(*controller.CompositeCostPolicy)
+	if !ok {
+		t.Fatalf("policy = %T, want composite", gw.Controller().Policy("/context"))
+	}
+	want := controller.DefaultCompositeCostConfig()
+	want.BaseThreshold = 999
+	if p.Config() != want {
+		t.Errorf("policy config = %+v, want %+v", p.Config(), want)
+	}
+
+	// 非法参数不生效
+	if rec := do(t, s, http.MethodPut, "/admin/params", `{"policy":{"composite":{"alpha":7}}}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid alpha = %d, want 400", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPut, "/admin/params", `{"policy":{"bogus":1}}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown field = %d, want 400", rec.Code)
+	}
+	if got := gw.Config().Pricing.Policy.Composite.Alpha; got != want.Alpha {
+		t.Errorf("default alpha after rejected update = %v, want %v", got, want.Alpha)
+	}
+}
+
+func TestDrainAndEnableBackend(t *testing.T) {
+	s, gw := newServer(t)
+	b := gw.Pools()["default"].Backends()[0]
+
+	rec := do(t, s, http.MethodPost, "/admin/backends/drain", `{"backend":"localhost:9001"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("drain = %d %s", rec.Code, rec.Body)
+	}
+	if !b.Draining() || b.Available() {
+		t.Errorf("after drain: draining = %v, available = %v, want true, false", b.Draining(), b.Available())
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/backends/drain", `{"backend":"localhost:1"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("drain unknown backend = %d, want 404", rec.Code)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/admin/backends/enable", `{"backend":"http://localhost:9001","pool":"default"}`); rec.Code != http.StatusOK {
+		t.Fatalf("enable = %d %s", rec.Code, rec.Body)
+	}
+	if b.Draining() {
+		t.Error("backend still draining after enable")
+	}
+}
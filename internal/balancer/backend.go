@@ -0,0 +1,167 @@
+package balancer
+
+import (
+	"net/http/httputil"
+	"net/url"
+	"rajomon-gateway/internal/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyAlpha 后端首字节延迟 EWMA 的平滑因子
+const latencyAlpha = 0.2
+
+// 后端健康状态
+const (
+	StateHealthy   = "healthy"   // 正常接流量
+	StateUnhealthy = "unhealthy" // 主动探测连续失败，等待探测恢复
+	StateEjected   = "ejected"   // 代理连续出错被被动摘除，冷却中
+	StateHalfOpen  = "half_open" // 冷却结束，等待一次探测决定是否重新接纳
+)
+
+// Backend 一个后端节点及其健康状态
+type Backend struct {
+	URL   *url.URL
+	proxy *httputil.ReverseProxy
+
+	mu                   sync.Mutex
+	state                string
+	ejectedUntil         time.Time
+	consecutiveFailures  int  // 主动探测连续失败次数
+	consecutiveSuccesses int  // 主动探测连续成功次数
+	consecutiveErrors    int  // 代理转发连续出错次数 (被动)
+	draining             bool // 运维人工摘除，与健康状态独立：健康检查不会自动恢复
+
+	breaker *breaker // 熔断器 (未启用时为 nil)，与健康状态独立
+
+	// --- 负载感知 (供选择策略使用) ---
+	inFlight    atomic.Int64 // 在途请求数
+	price       atomic.Int64 // 后端在 Price 响应头里广播的最新价格
+	ewmaLatency float64      // 首字节延迟 EWMA (ms)，受 mu 保护
+}
+
+// newBackend 创建后端，不写指标：按主机的指标由生效中的后端池维护 (见 SimpleLoadBalancer.PublishMetrics)
+func newBackend(u *url.URL) *Backend {
+	return &Backend{URL: u, state: StateHealthy}
+}
+
+// inherit 沿用旧后端 (同一个 URL) 的健康状态、被动摘除、人工摘除和负载感知数据，不写指标
+func (b *Backend) inherit(old *Backend) {
+	old.mu.Lock()
+	state, ejectedUntil, draining, ewma := old.state, old.ejectedUntil, old.draining, old.ewmaLatency
+	failures, successes, errs := old.consecutiveFailures, old.consecutiveSuccesses, old.consecutiveErrors
+	old.mu.Unlock()
+
+	b.mu.Lock()
+	b.state, b.ejectedUntil, b.draining, b.ewmaLatency = state, ejectedUntil, draining, ewma
+	b.consecutiveFailures, b.consecutiveSuccesses, b.consecutiveErrors = failures, successes, errs
+	b.mu.Unlock()
+	b.price.Store(old.price.Load())
+
+	if b.breaker != nil && old.breaker != nil {
+		b.breaker.inherit(old.breaker)
+	}
+}
+
+// publishMetrics 把当前的健康和熔断状态写入指标
+func (b *Backend) publishMetrics() {
+	b.mu.Lock()
+	b.updateMetricLocked()
+	b.mu.Unlock()
+	if b.breaker != nil {
+		b.breaker.publishMetrics()
+	}
+}
+
+// State 当前健康状态
+func (b *Backend) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Available 是否可以接流量
+func (b *Backend) Available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == StateHealthy && !b.draining
+}
+
+// BreakerState 熔断器状态 (closed / open / half_open)，未启用熔断时为空
+func (b *Backend) BreakerState() string {
+	if b.breaker == nil {
+		return ""
+	}
+	return b.breaker.State()
+}
+
+// Drain 人工摘除：不再分配新请求，在途请求照常完成
+func (b *Backend) Drain() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.draining = true
+	b.updateMetricLocked()
+}
+
+// Enable 撤销人工摘除 (仍然要健康才会接流量)
+func (b *Backend) Enable() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.draining = false
+	b.updateMetricLocked()
+}
+
+// Draining 是否被人工摘除
+func (b *Backend) Draining() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.draining
+}
+
+// setStateLocked 切换状态并更新指标 (调用方需持有 b.mu)
+func (b *Backend) setStateLocked(state string) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	b.updateMetricLocked()
+}
+
+// updateMetricLocked 健康且没有被人工摘除时才算可接流量 (调用方需持有 b.mu)
+func (b *Backend) updateMetricLocked() {
+	healthy := 0.0
+	if b.state == StateHealthy && !b.draining {
+		healthy = 1
+	}
+	metrics.BackendHealthy.WithLabelValues(b.URL.Host).Set(healthy)
+}
+
+// InFlight 在途请求数
+func (b *Backend) InFlight() int64 {
+	return b.inFlight.Load()
+}
+
+// Price 后端广播的最新价格 (从未广播过时为 0)
+func (b *Backend) Price() int64 {
+	return b.price.Load()
+}
+
+// EWMALatency 首字节延迟 EWMA (ms)，没有样本时为 0
+func (b *Backend) EWMALatency() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ewmaLatency
+}
+
+// observeLatency 记录一次首字节延迟
+func (b *Backend) observeLatency(d time.Duration) {
+	ms := float64(d.Microseconds()) / 1000
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ewmaLatency == 0 {
+		b.ewmaLatency = ms
+	} else {
+		b.ewmaLatency = latencyAlpha*ms + (1-latencyAlpha)*b.ewmaLatency
+	}
+}
@@ -0,0 +1,403 @@
+package balancer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/deadline"
+	"rajomon-gateway/internal/metrics"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// SimpleLoadBalancer 基于策略的负载均衡器，跳过不健康的后端
+// 同一组后端 (及其健康状态、负载统计) 可以通过 Route 以不同的策略挂到多个路由上
+type SimpleLoadBalancer struct {
+	backends []*Backend
+	strategy Strategy // 默认策略 (轮询)
+	health   HealthCheckConfig
+	prices   PriceSink // 下游价格上报 (可选)
+	breaker  BreakerConfig
+	source   PriceSource // 快速失败时回传的网关价格 (可选)
+
+	retry       *RetryConfig // 为空时不重试
+	budget      *RetryBudget
+	safeMethods map[string]bool
+}
+
+// PriceSink 接收后端在 Price 响应头中广播的价格 (通常是 RajomonController)
+type PriceSink interface {
+	ObserveDownstreamPrice(key, backend string, price int)
+}
+
+// PriceSource 网关当前对外的价格 (通常是 RajomonController)
+type PriceSource interface {
+	GetPrice(key string) int
+}
+
+// Option SimpleLoadBalancer 的可选配置
+type Option func(*SimpleLoadBalancer)
+
+// WithStrategy 指定默认的后端选择策略 (默认轮询)
+func WithStrategy(strategy Strategy) Option {
+	return func(lb *SimpleLoadBalancer) { lb.strategy = strategy }
+}
+
+// WithPriceSink 把后端广播的价格上报给控制器，用于多跳价格传播
+// 启用后后端的 Price 头不再透传给客户端，客户端看到的是网关合并后的对外价格
+func WithPriceSink(sink PriceSink) Option {
+	return func(lb *SimpleLoadBalancer) { lb.prices = sink }
+}
+
+// WithHealthCheck 自定义健康检查参数 (默认 DefaultHealthCheckConfig)
+func WithHealthCheck(cfg HealthCheckConfig) Option {
+	return func(lb *SimpleLoadBalancer) { lb.health = cfg }
+}
+
+// WithBreaker 为每个后端启用熔断器
+func WithBreaker(cfg BreakerConfig) Option {
+	return func(lb *SimpleLoadBalancer) { lb.breaker = cfg }
+}
+
+// WithPriceSource 所有后端都熔断时，快速失败的响应里带上该路由当前的价格
+func WithPriceSource(source PriceSource) Option {
+	return func(lb *SimpleLoadBalancer) { lb.source = source }
+}
+
+// WithRetry 转发失败且还没有写出任何字节时换一个后端重试，受网关级重试预算 budget 约束
+func WithRetry(cfg RetryConfig, budget *RetryBudget) Option {
+	return func(lb *SimpleLoadBalancer) {
+		lb.retry, lb.budget = &cfg, budget
+		lb.safeMethods = methodSet(cfg.SafeMethods)
+	}
+}
+
+// WithRetryBudget 不重试的后端池也要有重试预算：对冲副本同样从预算中扣除
+func WithRetryBudget(budget *RetryBudget) Option {
+	return func(lb *SimpleLoadBalancer) { lb.budget = budget }
+}
+
+func methodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[m] = true
+	}
+	return set
+}
+
+func NewLoadBalancer(targets []string, opts ...Option) (*SimpleLoadBalancer, error) {
+	// 没有配置重试时，对冲按默认白名单判断 MCP 调用能否发送多次
+	lb := &SimpleLoadBalancer{strategy: &RoundRobin{}, health: DefaultHealthCheckConfig(), safeMethods: methodSet(DefaultRetryConfig().SafeMethods)}
+	for _, opt := range opts {
+		opt(lb)
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("后端地址解析失败: %w", err)
+		}
+		b := newBackend(u)
+		if lb.breaker.enabled() {
+			b.breaker = newBreaker(lb.breaker, u.Host)
+		}
+		b.proxy = lb.newProxy(b)
+		lb.backends = append(lb.backends, b)
+	}
+	return lb, nil
+}
+
+// Inherit 后端池重建时沿用旧池中同一个 URL 的后端状态 (健康、被动摘除、人工摘除、熔断)，
+// 配置热加载不能把故障中的后端立即重新放进来
+func (lb *SimpleLoadBalancer) Inherit(old *SimpleLoadBalancer) {
+	prev := make(map[string]*Backend, len(old.backends))
+	for _, b := range old.backends {
+		prev[b.URL.String()] = b
+	}
+	for _, b := range lb.backends {
+		if o, ok := prev[b.URL.String()]; ok {
+			b.inherit(o)
+		}
+	}
+}
+
+// PublishMetrics 把所有后端的当前状态写入按主机的健康 / 熔断指标
+// 这些指标在多个后端池之间共享，构建时不写：新的后端池生效之前指标仍属于旧池
+func (lb *SimpleLoadBalancer) PublishMetrics() {
+	for _, b := range lb.backends {
+		b.publishMetrics()
+	}
+}
+
+// Backends 所有后端节点
+func (lb *SimpleLoadBalancer) Backends() []*Backend {
+	return lb.backends
+}
+
+// newProxy 为后端创建反向代理 (每个后端复用一个)
+func (lb *SimpleLoadBalancer) newProxy(b *Backend) *httputil.ReverseProxy {
+	target := b.URL
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	// 修改请求头，确保 Host 正确
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalDirector(req)
+		req.Host = target.Host
+		// 可以在这里加一个 Header 标识经过了网关
+		req.Header.Set("X-Forwarded-By", "Rajomon-Gateway")
+		// 请求有截止时间时把剩余预算告诉后端，后端可以提前放弃注定超时的请求
+		if dl, ok := req.Context().Deadline(); ok {
+			deadline.Forward(req.Header, time.Until(dl))
+		}
+	}
+
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		lb.onProxySuccess(b)
+		// 熔断只看后端自身的故障 (5xx)，4xx (包括后端的 429) 不算
+		if b.breaker != nil {
+			b.breaker.record(resp.StatusCode >= http.StatusInternalServerError)
+		}
+		// 收到响应头即视为首字节到达，用于 EWMA 延迟策略
+		if a, ok := resp.Request.Context().Value(attemptKey{}).(*attempt); ok {
+			b.observeLatency(time.Since(a.start))
+			if h, ok := resp.Request.Context().Value(hedgeKey{}).(*hedger); ok {
+				h.observe(time.Since(a.start))
+			}
+		}
+		// 记录后端广播的价格，用于价格感知的选择策略和多跳价格传播
+		if price, err := strconv.ParseInt(resp.Header.Get("Price"), 10, 64); err == nil {
+			b.price.Store(price)
+			if lb.prices != nil {
+				if key, ok := controller.KeyFromContext(resp.Request.Context()); ok {
+					lb.prices.ObserveDownstreamPrice(key, target.Host, int(price))
+				}
+				resp.Header.Del("Price")
+			}
+		}
+		return nil
+	}
+
+	// 自定义错误处理 (比如后端挂了)，同时计入被动健康检查
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		fmt.Printf("❌ [LB] 转发失败 -> %s: %v\n", target.Host, err)
+		if r.Context().Err() != nil {
+			// 客户端先放弃了 (或者对冲输掉被取消)，不是后端的错
+			if b.breaker != nil {
+				b.breaker.cancel()
+			}
+		} else {
+			lb.onProxyError(b)
+			if b.breaker != nil {
+				b.breaker.record(true)
+			}
+		}
+		// 此时还没有向客户端写出任何字节：交给 serve 决定换后端重试还是返回 502
+		if a, ok := r.Context().Value(attemptKey{}).(*attempt); ok {
+			a.err = err
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	return proxy
+}
+
+// attempt 一次转发尝试：开始时间 (首字节延迟) 和转发错误
+type attempt struct {
+	start time.Time
+	err   error
+}
+
+// attemptKey 请求上下文中保存本次转发尝试
+type attemptKey struct{}
+
+// ServeHTTP 使用默认策略转发
+func (lb *SimpleLoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lb.serve(w, r, lb.strategy)
+}
+
+// RouteOption Route 的可选配置
+type RouteOption func(*route)
+
+// route 一条路由在共享后端池上的转发方式
+type route struct {
+	hedge *hedger
+}
+
+// WithHedging 对该路由的请求开启对冲，prices 为路由价格 (超过 cfg.MaxPrice 时不对冲)
+func WithHedging(cfg HedgeConfig, prices PriceSource) RouteOption {
+	return func(rt *route) { rt.hedge = newHedger(cfg, prices) }
+}
+
+// Route 返回使用指定策略的转发入口，与默认入口共享后端、健康状态和负载统计
+// strategy 为空时使用默认策略
+func (lb *SimpleLoadBalancer) Route(strategy Strategy, opts ...RouteOption) http.Handler {
+	if strategy == nil {
+		strategy = lb.strategy
+	}
+	rt := &route{}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rt.hedge != nil && lb.serveHedged(w, r, strategy, rt.hedge) {
+			return
+		}
+		lb.serve(w, r, strategy)
+	})
+}
+
+// serve 实现反向代理转发，转发失败且允许重试时换一个后端
+func (lb *SimpleLoadBalancer) serve(w http.ResponseWriter, r *http.Request, strategy Strategy) {
+	body, retryable := lb.retryBody(r)
+	if lb.budget != nil {
+		lb.budget.request()
+	}
+
+	// 1. 按策略选择后端 (只在健康且未熔断的后端中选)
+	target := lb.pick(strategy, nil)
+	if target == nil {
+		if wait, open := lb.circuitOpen(); open {
+			lb.failFast(w, r, wait)
+			return
+		}
+		http.Error(w, "No backend available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var tried []*Backend
+	for {
+		// 2. 转发
+		if err := lb.forward(w, r, strategy, target, body); err == nil {
+			return
+		}
+		tried = append(tried, target)
+
+		// 3. 失败发生在写出任何字节之前：换一个本次还没试过的后端，重试也计入该 Key 的成本
+		next, reason := lb.nextAttempt(r, strategy, retryable, tried)
+		key := keyOf(r)
+		if next == nil {
+			if reason != "" {
+				metrics.Retries.WithLabelValues(key, reason).Inc()
+				fmt.Printf("🛑 [LB] 放弃重试 (%s) [%s]\n", reason, key)
+			}
+			proxyFailed(w, r)
+			return
+		}
+		metrics.Retries.WithLabelValues(key, "retried").Inc()
+		controller.CountRetry(r.Context())
+		fmt.Printf("🔁 [LB] 换后端重试 (%d/%d) [%s] %s -> %s\n", len(tried), lb.retry.MaxRetries, key, target.URL.Host, next.URL.Host)
+		target = next
+	}
+}
+
+// forward 把请求转发给 target，返回转发错误 (此时还没有写出任何字节)
+func (lb *SimpleLoadBalancer) forward(w http.ResponseWriter, r *http.Request, strategy Strategy, target *Backend, body []byte) error {
+	target.inFlight.Add(1)
+	defer target.inFlight.Add(-1)
+
+	fmt.Printf("🔀 [LB][%s] 转发请求 -> %s\n", strategy.Name(), target.URL.Host)
+	a := &attempt{start: time.Now()}
+	req := r.WithContext(context.WithValue(r.Context(), attemptKey{}, a))
+	if body != nil {
+		// 每次尝试都从头发送缓存的请求体
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	target.proxy.ServeHTTP(w, req)
+	return a.err
+}
+
+// nextAttempt 选出重试用的后端；不重试时返回 nil 和原因 (用作指标标签，本来就不能重试的请求原因为空)
+func (lb *SimpleLoadBalancer) nextAttempt(r *http.Request, strategy Strategy, retryable bool, tried []*Backend) (*Backend, string) {
+	switch {
+	case !retryable || r.Context().Err() != nil:
+		// 不可重试的请求，或者客户端已经放弃
+		return nil, ""
+	case len(tried) > lb.retry.MaxRetries:
+		return nil, "max_retries"
+	}
+	next := lb.pick(strategy, tried)
+	if next == nil {
+		return nil, "no_backend"
+	}
+	if !lb.budget.withdraw() {
+		if next.breaker != nil {
+			next.breaker.cancel()
+		}
+		return nil, "budget_exhausted"
+	}
+	return next, ""
+}
+
+// proxyFailed 所有尝试都失败：超时预算用完时返回 504，否则 502
+func proxyFailed(w http.ResponseWriter, r *http.Request) {
+	if deadline.Reason(r.Context()) == "deadline" {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+// keyOf 请求的定价 Key，没有经过定价中间件时用路径
+func keyOf(r *http.Request) string {
+	if key, ok := controller.KeyFromContext(r.Context()); ok {
+		return key
+	}
+	return r.URL.Path
+}
+
+// pick 过滤出可用、未熔断且不在 exclude 中的后端，交给策略选择
+func (lb *SimpleLoadBalancer) pick(strategy Strategy, exclude []*Backend) *Backend {
+	available := make([]*Backend, 0, len(lb.backends))
+	for _, b := range lb.backends {
+		if b.Available() && !slices.Contains(exclude, b) && (b.breaker == nil || b.breaker.ready()) {
+			available = append(available, b)
+		}
+	}
+	for len(available) > 0 {
+		target := strategy.Pick(available)
+		if target.breaker == nil || target.breaker.allow() {
+			return target
+		}
+		// 半开的试探名额被并发请求抢走了，换一个
+		available = slices.DeleteFunc(available, func(b *Backend) bool { return b == target })
+	}
+	return nil
+}
+
+// circuitOpen 是否因为熔断 (而不是健康检查或人工摘除) 而没有后端可用，返回最早恢复试探的等待时间
+func (lb *SimpleLoadBalancer) circuitOpen() (time.Duration, bool) {
+	wait, open := time.Duration(0), false
+	for _, b := range lb.backends {
+		if b.breaker == nil || !b.Available() {
+			continue
+		}
+		if d := b.breaker.retryAfter(); !open || d < wait {
+			wait = d
+		}
+		open = true
+	}
+	return wait, open
+}
+
+// failFast 所有后端都熔断时直接返回 503，不再打到后端
+// 和准入拒绝一样带上当前价格，客户端可以据此更新价格缓存、按 Retry-After 退避
+func (lb *SimpleLoadBalancer) failFast(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	key := keyOf(r)
+	msg := "All backends circuit open"
+	if lb.source != nil {
+		price := lb.source.GetPrice(key)
+		w.Header().Set("Price", strconv.Itoa(price))
+		msg = fmt.Sprintf("%s (Price %d)", msg, price)
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(wait, time.Second).Seconds()))))
+	metrics.BreakerRejections.WithLabelValues(key).Inc()
+	fmt.Printf("⚡ [LB] 所有后端均已熔断，快速失败 [%s] | %s | %v 后试探\n", key, msg, wait.Round(time.Millisecond))
+	http.Error(w, msg, http.StatusServiceUnavailable)
+}
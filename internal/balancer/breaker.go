@@ -0,0 +1,226 @@
+package balancer
+
+import (
+	"fmt"
+	"rajomon-gateway/internal/metrics"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    // 正常放行，统计滑动窗口内的错误
+	BreakerOpen     = "open"      // 熔断：不再分配请求，冷却结束后进入半开
+	BreakerHalfOpen = "half_open" // 放行少量试探请求，全部成功才闭合，任意一个失败重新熔断
+)
+
+// BreakerConfig 每个后端一个的熔断器参数
+// 与被动摘除 (EjectThreshold) 的区别：熔断同时看错误率和 5xx 响应，并且由真实请求 (而不是健康探测) 试探恢复
+type BreakerConfig struct {
+	Window              time.Duration // 错误率统计的滑动窗口
+	MinRequests         int           // 窗口内请求数达到该值才按错误率判断，避免小样本误判
+	ErrorRate           float64       // 窗口内错误率达到该值熔断 (0 表示不按错误率熔断)
+	ConsecutiveFailures int           // 连续失败达到该值熔断 (0 表示不按连续失败熔断)
+	OpenDuration        time.Duration // 熔断后的冷却时间，之后进入半开
+	HalfOpenRequests    int           // 半开状态放行的试探请求数
+}
+
+// DefaultBreakerConfig 10s 窗口内至少 20 个请求且错误率达到 50%，或连续失败 5 次时熔断 5s；半开放行 3 个试探请求
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Window:              10 * time.Second,
+		MinRequests:         20,
+		ErrorRate:           0.5,
+		ConsecutiveFailures: 5,
+		OpenDuration:        5 * time.Second,
+		HalfOpenRequests:    3,
+	}
+}
+
+// enabled 至少配置了一种熔断条件
+func (c BreakerConfig) enabled() bool {
+	return c.ErrorRate > 0 || c.ConsecutiveFailures > 0
+}
+
+// breaker 单个后端的熔断器 (closed -> open -> half_open -> closed / open)
+type breaker struct {
+	cfg  BreakerConfig
+	host string
+	now  func() time.Time
+
+	mu          sync.Mutex
+	state       string
+	openUntil   time.Time
+	window      slidingWindow // 请求数 / 失败数
+	consecutive int           // 连续失败次数
+	trials      int           // 半开状态已放行的试探请求数
+	successes   int           // 半开状态试探成功数
+}
+
+func newBreaker(cfg BreakerConfig, host string) *breaker {
+	return &breaker{cfg: cfg, host: host, now: time.Now, state: BreakerClosed, window: slidingWindow{size: cfg.Window}}
+}
+
+// inherit 沿用旧熔断器的状态：重建后端池不能让熔断中的后端立即恢复接流量
+// 半开的试探名额重新计数，旧熔断器放出去的试探请求结果记在旧熔断器上
+func (br *breaker) inherit(old *breaker) {
+	old.mu.Lock()
+	state, openUntil, window, consecutive := old.state, old.openUntil, old.window, old.consecutive
+	old.mu.Unlock()
+
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.state, br.openUntil, br.consecutive = state, openUntil, consecutive
+	if window.size == br.window.size {
+		br.window = window
+	}
+	br.trials, br.successes = 0, 0
+}
+
+// publishMetrics 把当前状态写入 rajomon_breaker_state
+func (br *breaker) publishMetrics() {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	metrics.BreakerState.WithLabelValues(br.host).Set(breakerStateValue(br.state))
+}
+
+// breakerStateValue 状态在 rajomon_breaker_state 中的取值
+func breakerStateValue(state string) float64 {
+	switch state {
+	case BreakerOpen:
+		return 2
+	case BreakerHalfOpen:
+		return 1
+	}
+	return 0
+}
+
+// State 当前状态 (冷却已结束的熔断视为半开)
+func (br *breaker) State() string {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.advanceLocked()
+	return br.state
+}
+
+// ready 是否可以分配请求 (不占用半开的试探名额)
+func (br *breaker) ready() bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.advanceLocked()
+	switch br.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		return br.trials < br.cfg.HalfOpenRequests
+	}
+	return true
+}
+
+// allow 分配请求前调用：半开状态下占用一个试探名额，名额用完返回 false
+func (br *breaker) allow() bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.advanceLocked()
+	switch br.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if br.trials >= br.cfg.HalfOpenRequests {
+			return false
+		}
+		br.trials++
+	}
+	return true
+}
+
+// retryAfter 距离进入半开还要多久 (未熔断时为 0)
+func (br *breaker) retryAfter() time.Duration {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.state != BreakerOpen {
+		return 0
+	}
+	return max(br.openUntil.Sub(br.now()), 0)
+}
+
+// record 记录一次请求的结果
+func (br *breaker) record(failed bool) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	now := br.now()
+
+	switch br.state {
+	case BreakerOpen:
+		// 熔断前已经放出去的请求，结果不再影响状态
+		return
+	case BreakerHalfOpen:
+		if failed {
+			br.tripLocked(now, "半开试探失败")
+			return
+		}
+		br.successes++
+		if br.successes >= br.cfg.HalfOpenRequests {
+			reason := fmt.Sprintf("半开试探 %d 次成功", br.successes)
+			br.resetLocked()
+			br.transitionLocked(BreakerClosed, reason)
+		}
+		return
+	}
+
+	if !failed {
+		br.window.add(now, 1, 0)
+		br.consecutive = 0
+		return
+	}
+	br.window.add(now, 1, 1)
+	br.consecutive++
+
+	if br.cfg.ConsecutiveFailures > 0 && br.consecutive >= br.cfg.ConsecutiveFailures {
+		br.tripLocked(now, fmt.Sprintf("连续失败 %d 次", br.consecutive))
+		return
+	}
+	if br.cfg.ErrorRate > 0 {
+		total, failures := br.window.sum(now)
+		if total >= br.cfg.MinRequests && float64(failures) >= br.cfg.ErrorRate*float64(total) {
+			br.tripLocked(now, fmt.Sprintf("错误率 %.0f%% (%d/%d)", 100*float64(failures)/float64(total), failures, total))
+		}
+	}
+}
+
+// cancel 请求没有得到结果 (如客户端主动断开)：归还半开的试探名额
+func (br *breaker) cancel() {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.state == BreakerHalfOpen && br.trials > br.successes {
+		br.trials--
+	}
+}
+
+// advanceLocked 冷却结束时从 open 进入 half_open
+func (br *breaker) advanceLocked() {
+	if br.state == BreakerOpen && !br.now().Before(br.openUntil) {
+		br.trials, br.successes = 0, 0
+		br.transitionLocked(BreakerHalfOpen, "冷却结束")
+	}
+}
+
+func (br *breaker) tripLocked(now time.Time, reason string) {
+	br.openUntil = now.Add(br.cfg.OpenDuration)
+	br.transitionLocked(BreakerOpen, fmt.Sprintf("%s，熔断 %v", reason, br.cfg.OpenDuration))
+}
+
+// resetLocked 闭合时清空窗口，旧的错误不再计入
+func (br *breaker) resetLocked() {
+	br.window.reset()
+	br.consecutive, br.trials, br.successes = 0, 0, 0
+}
+
+func (br *breaker) transitionLocked(to, reason string) {
+	from := br.state
+	br.state = to
+	metrics.BreakerState.WithLabelValues(br.host).Set(breakerStateValue(to))
+	metrics.BreakerTransitions.WithLabelValues(br.host, from, to).Inc()
+	icon := map[string]string{BreakerOpen: "🔌", BreakerHalfOpen: "🔦", BreakerClosed: "🔋"}[to]
+	fmt.Printf("%s [Breaker] %s -> %s (%s) -> %s\n", icon, from, to, reason, br.host)
+}
@@ -0,0 +1,149 @@
+package balancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/controller"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestBreaker(cfg BreakerConfig) (*breaker, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	br := newBreaker(cfg, "test:1")
+	br.now = clock.now
+	return br, clock
+}
+
+func TestBreakerTripsOnConsecutiveFailuresAndRecovers(t *testing.T) {
+	br, clock := newTestBreaker(BreakerConfig{Window: 10 * time.Second, ConsecutiveFailures: 3, OpenDuration: 5 * time.Second, HalfOpenRequests: 2})
+
+	for i := 0; i < 3; i++ {
+		if !br.allow() {
+			t.Fatalf("request %d rejected while closed", i)
+		}
+		br.record(true)
+	}
+	if got := br.State(); got != BreakerOpen {
+		t.Fatalf("state after 3 failures = %s, want open", got)
+	}
+	if br.allow() {
+		t.Error("open breaker allowed a request")
+	}
+	if got := br.retryAfter(); got != 5*time.Second {
+		t.Errorf("retryAfter = %v, want 5s", got)
+	}
+
+	// 冷却结束：半开只放行 2 个试探请求
+	clock.t = clock.t.Add(5 * time.Second)
+	if !br.allow() || !br.allow() {
+		t.Fatal("half-open breaker rejected a trial request")
+	}
+	if br.allow() || br.ready() {
+		t.Error("half-open breaker allowed more than half_open_requests trials")
+	}
+	br.record(false)
+	if got := br.State(); got != BreakerHalfOpen {
+		t.Errorf("state after 1/2 trial successes = %s, want half_open", got)
+	}
+	br.record(false)
+	if got := br.State(); got != BreakerClosed {
+		t.Errorf("state after 2/2 trial successes = %s, want closed", got)
+	}
+}
+
+func TestBreakerHalfOpenFailureReopens(t *testing.T) {
+	br, clock := newTestBreaker(BreakerConfig{Window: 10 * time.Second, ConsecutiveFailures: 1, OpenDuration: time.Second, HalfOpenRequests: 1})
+	br.record(true)
+	clock.t = clock.t.Add(time.Second)
+
+	if !br.allow() {
+		t.Fatal("half-open breaker rejected the trial request")
+	}
+	br.cancel() // 客户端放弃不算失败，名额归还
+	if !br.allow() {
+		t.Fatal("trial slot not returned after cancel")
+	}
+	br.record(true)
+	if got := br.State(); got != BreakerOpen {
+		t.Errorf("state after failed trial = %s, want open", got)
+	}
+}
+
+func TestBreakerErrorRateOverSlidingWindow(t *testing.T) {
+	br, clock := newTestBreaker(BreakerConfig{Window: 10 * time.Second, MinRequests: 10, ErrorRate: 0.5, OpenDuration: time.Second, HalfOpenRequests: 1})
+
+	// 交替成功/失败，连续失败不会超过 1 次；样本不足 10 个时不熔断
+	for i := 0; i < 9; i++ {
+		br.record(i%2 == 0)
+	}
+	if got := br.State(); got != BreakerClosed {
+		t.Fatalf("state with 9 samples = %s, want closed", got)
+	}
+	// 窗口滑过之后旧的错误不再计入
+	clock.t = clock.t.Add(11 * time.Second)
+	br.record(true)
+	if got := br.State(); got != BreakerClosed {
+		t.Fatalf("state after window slid = %s, want closed", got)
+	}
+	for i := 0; i < 9; i++ {
+		br.record(i%2 == 0)
+	}
+	if got := br.State(); got != BreakerOpen {
+		t.Errorf("state at 60%% errors over 10 samples = %s, want open", got)
+	}
+}
+
+type fixedPrice int
+
+func (p fixedPrice) GetPrice(string) int { return int(p) }
+
+func TestAllBreakersOpenFailsFastWithPrice(t *testing.T) {
+	hits := 0
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	lb, err := NewLoadBalancer([]string{backend.URL},
+		WithHealthCheck(HealthCheckConfig{}),
+		WithBreaker(BreakerConfig{Window: 10 * time.Second, ConsecutiveFailures: 2, OpenDuration: time.Minute, HalfOpenRequests: 1}),
+		WithPriceSource(fixedPrice(42)),
+	)
+	if err != nil {
+		t.Fatalf("NewLoadBalancer: %v", err)
+	}
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+		req = req.WithContext(controller.WithKey(req.Context(), "/mcp"))
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, req)
+		return rec
+	}
+	for i := 0; i < 2; i++ {
+		if rec := serve(); rec.Code != http.StatusInternalServerError {
+			t.Fatalf("request %d = %d, want 500 from backend", i, rec.Code)
+		}
+	}
+
+	rec := serve()
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("request with open breaker = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Price"); got != "42" {
+		t.Errorf("Price header = %q, want 42", got)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	if hits != 2 {
+		t.Errorf("backend hits = %d, want 2 (fail fast must not reach the backend)", hits)
+	}
+}
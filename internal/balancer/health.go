@@ -0,0 +1,145 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"rajomon-gateway/internal/metrics"
+	"time"
+)
+
+// HealthCheckConfig 健康检查参数
+type HealthCheckConfig struct {
+	Path               string        // 探测路径 (后端的 /health)
+	Interval           time.Duration // 主动探测周期 (0 表示不做主动探测)
+	Timeout            time.Duration // 单次探测超时
+	HealthyThreshold   int           // 连续成功几次恢复为 healthy
+	UnhealthyThreshold int           // 连续失败几次标记为 unhealthy
+	EjectThreshold     int           // 代理连续出错几次被动摘除 (0 表示不摘除)
+	EjectDuration      time.Duration // 摘除后的冷却时间，之后进入半开状态等待探测
+}
+
+// DefaultHealthCheckConfig 默认每 2s 探测一次，连续 3 次失败下线，连续 2 次成功上线；
+// 代理连续出错 5 次摘除 10s
+func DefaultHealthCheckConfig() HealthCheckConfig {
+	return HealthCheckConfig{
+		Path:               "/health",
+		Interval:           2 * time.Second,
+		Timeout:            time.Second,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+		EjectThreshold:     5,
+		EjectDuration:      10 * time.Second,
+	}
+}
+
+// StartHealthCheck 启动主动健康检查，直到 ctx 被取消
+func (lb *SimpleLoadBalancer) StartHealthCheck(ctx context.Context) {
+	if lb.health.Interval <= 0 {
+		return
+	}
+	client := &http.Client{Timeout: lb.health.Timeout}
+	go func() {
+		ticker := time.NewTicker(lb.health.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, b := range lb.backends {
+					lb.checkBackend(ctx, client, b)
+				}
+			}
+		}
+	}()
+}
+
+// checkBackend 对单个后端做一次主动探测
+func (lb *SimpleLoadBalancer) checkBackend(ctx context.Context, client *http.Client, b *Backend) {
+	b.mu.Lock()
+	if b.state == StateEjected {
+		if time.Now().Before(b.ejectedUntil) {
+			// 冷却中，不探测
+			b.mu.Unlock()
+			return
+		}
+		// 冷却结束：进入半开状态，由这一次探测决定是否重新接纳
+		b.setStateLocked(StateHalfOpen)
+	}
+	b.mu.Unlock()
+
+	ok := probe(ctx, client, b.URL.String()+lb.health.Path)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		if ok {
+			b.consecutiveErrors = 0
+			b.consecutiveFailures = 0
+			b.setStateLocked(StateHealthy)
+			fmt.Printf("💚 [LB] 后端恢复 (半开探测成功) -> %s\n", b.URL.Host)
+		} else {
+			b.ejectedUntil = time.Now().Add(lb.health.EjectDuration)
+			b.setStateLocked(StateEjected)
+			fmt.Printf("💔 [LB] 半开探测失败，继续摘除 %v -> %s\n", lb.health.EjectDuration, b.URL.Host)
+		}
+		return
+	}
+
+	if ok {
+		b.consecutiveFailures = 0
+		b.consecutiveSuccesses++
+		if b.state == StateUnhealthy && b.consecutiveSuccesses >= lb.health.HealthyThreshold {
+			b.setStateLocked(StateHealthy)
+			fmt.Printf("💚 [LB] 后端恢复健康 -> %s\n", b.URL.Host)
+		}
+		return
+	}
+
+	b.consecutiveSuccesses = 0
+	b.consecutiveFailures++
+	if b.state == StateHealthy && b.consecutiveFailures >= lb.health.UnhealthyThreshold {
+		b.setStateLocked(StateUnhealthy)
+		fmt.Printf("💔 [LB] 健康检查连续失败 %d 次，下线 -> %s\n", b.consecutiveFailures, b.URL.Host)
+	}
+}
+
+// probe 请求健康检查接口，2xx 视为健康
+func probe(ctx context.Context, client *http.Client, target string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// onProxyError 被动健康检查：代理连续出错达到阈值时摘除后端
+func (lb *SimpleLoadBalancer) onProxyError(b *Backend) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutiveErrors++
+	if lb.health.EjectThreshold <= 0 || b.consecutiveErrors < lb.health.EjectThreshold {
+		return
+	}
+	if b.state == StateHealthy || b.state == StateHalfOpen {
+		b.ejectedUntil = time.Now().Add(lb.health.EjectDuration)
+		b.setStateLocked(StateEjected)
+		metrics.BackendEjections.WithLabelValues(b.URL.Host).Inc()
+		fmt.Printf("🚫 [LB] 代理连续出错 %d 次，摘除 %v -> %s\n", b.consecutiveErrors, lb.health.EjectDuration, b.URL.Host)
+	}
+}
+
+// onProxySuccess 收到后端响应，清零被动错误计数
+func (lb *SimpleLoadBalancer) onProxySuccess(b *Backend) {
+	b.mu.Lock()
+	b.consecutiveErrors = 0
+	b.mu.Unlock()
+}
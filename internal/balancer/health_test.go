@@ -0,0 +1,165 @@
+package balancer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/metrics"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPassiveEjectionAfterConsecutiveErrors(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	lb, err := NewLoadBalancer([]string{dead.URL}, WithHealthCheck(HealthCheckConfig{EjectThreshold: 3, EjectDuration: time.Hour}))
+	if err != nil {
+		t.Fatalf("NewLoadBalancer: %v", err)
+	}
+	b := lb.Backends()[0]
+	ejections := metrics.BackendEjections.WithLabelValues(b.URL.Host)
+	before := testutil.ToFloat64(ejections)
+
+	for i := 1; i <= 3; i++ {
+		if got := b.State(); got != StateHealthy {
+			t.Fatalf("state after %d errors = %s, want healthy", i-1, got)
+		}
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+		if rec.Code != http.StatusBadGateway {
+			t.Fatalf("request %d to a dead backend = %d, want 502", i, rec.Code)
+		}
+	}
+	if got := b.State(); got != StateEjected || b.Available() {
+		t.Errorf("state after 3 errors = %s, want ejected", got)
+	}
+	if got := testutil.ToFloat64(ejections) - before; got != 1 {
+		t.Errorf("ejections += %v, want 1", got)
+	}
+
+	// 没有可用后端时不再转发
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("request with every backend ejected = %d, want 503", rec.Code)
+	}
+}
+
+func TestProxySuccessResetsErrorCount(t *testing.T) {
+	lb, _ := NewLoadBalancer([]string{"http://127.0.0.1:1"}, WithHealthCheck(HealthCheckConfig{EjectThreshold: 3, EjectDuration: time.Hour}))
+	b := lb.Backends()[0]
+
+	lb.onProxyError(b)
+	lb.onProxyError(b)
+	lb.onProxySuccess(b)
+	lb.onProxyError(b)
+	lb.onProxyError(b)
+	if got := b.State(); got != StateHealthy {
+		t.Errorf("state after non-consecutive errors = %s, want healthy", got)
+	}
+}
+
+// healthServer /health 返回 status 指定的状态码，并统计探测次数
+func healthServer(t *testing.T, status *atomic.Int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var probes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &probes
+}
+
+func TestHalfOpenProbe(t *testing.T) {
+	cases := []struct {
+		name       string
+		cooldown   time.Duration
+		status     int
+		wantState  string
+		wantProbes int32
+	}{
+		{name: "still cooling down", cooldown: time.Hour, status: http.StatusOK, wantState: StateEjected, wantProbes: 0},
+		{name: "probe succeeds", cooldown: 0, status: http.StatusOK, wantState: StateHealthy, wantProbes: 1},
+		{name: "probe fails", cooldown: 0, status: http.StatusInternalServerError, wantState: StateEjected, wantProbes: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var status atomic.Int32
+			status.Store(int32(tc.status))
+			srv, probes := healthServer(t, &status)
+			cfg := DefaultHealthCheckConfig()
+			cfg.EjectThreshold, cfg.EjectDuration = 1, tc.cooldown
+			lb, _ := NewLoadBalancer([]string{srv.URL}, WithHealthCheck(cfg))
+			b := lb.Backends()[0]
+
+			lb.onProxyError(b)
+			if got := b.State(); got != StateEjected {
+				t.Fatalf("state after ejection = %s, want ejected", got)
+			}
+			lb.checkBackend(context.Background(), srv.Client(), b)
+			if got := b.State(); got != tc.wantState {
+				t.Errorf("state after probe = %s, want %s", got, tc.wantState)
+			}
+			if got := probes.Load(); got != tc.wantProbes {
+				t.Errorf("probes = %d, want %d", got, tc.wantProbes)
+			}
+		})
+	}
+}
+
+func TestHalfOpenReadmitsAfterFailedProbe(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	srv, _ := healthServer(t, &status)
+	cfg := DefaultHealthCheckConfig()
+	cfg.EjectThreshold, cfg.EjectDuration = 1, 0
+	lb, _ := NewLoadBalancer([]string{srv.URL}, WithHealthCheck(cfg))
+	b := lb.Backends()[0]
+
+	lb.onProxyError(b)
+	lb.checkBackend(context.Background(), srv.Client(), b)
+	if got := b.State(); got != StateEjected {
+		t.Fatalf("state after failed probe = %s, want ejected", got)
+	}
+
+	// 后端恢复后，下一次半开探测把它重新接纳
+	status.Store(http.StatusOK)
+	lb.checkBackend(context.Background(), srv.Client(), b)
+	if got := b.State(); got != StateHealthy || !b.Available() {
+		t.Errorf("state after recovery = %s, want healthy", got)
+	}
+}
+
+func TestActiveHealthThresholds(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	srv, _ := healthServer(t, &status)
+	cfg := DefaultHealthCheckConfig() // 连续 3 次失败下线，连续 2 次成功上线
+	lb, _ := NewLoadBalancer([]string{srv.URL}, WithHealthCheck(cfg))
+	b := lb.Backends()[0]
+	check := func() { lb.checkBackend(context.Background(), srv.Client(), b) }
+
+	check()
+	check()
+	if got := b.State(); got != StateHealthy {
+		t.Fatalf("state after 2 failed probes = %s, want healthy", got)
+	}
+	check()
+	if got := b.State(); got != StateUnhealthy {
+		t.Fatalf("state after 3 failed probes = %s, want unhealthy", got)
+	}
+
+	status.Store(http.StatusOK)
+	check()
+	if got := b.State(); got != StateUnhealthy {
+		t.Fatalf("state after 1 good probe = %s, want unhealthy", got)
+	}
+	check()
+	if got := b.State(); got != StateHealthy {
+		t.Errorf("state after 2 good probes = %s, want healthy", got)
+	}
+}
@@ -0,0 +1,314 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/metrics"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HedgeConfig 对冲请求：第一个后端迟迟没有返回首字节时，再向另一个后端发一份，先到的响应胜出
+// 只应该对便宜、可以重复执行、对尾延迟敏感的请求开启
+type HedgeConfig struct {
+	Percentile float64       // 对冲延迟取最近首字节延迟的分位数 (如 0.95)
+	MinDelay   time.Duration // 对冲延迟下限，避免后端都很快时每个请求都被对冲
+	MaxPrice   int           // 路由价格高于该值时不对冲，过载时对冲不再加压 (0 表示不限)
+	Keys       []string      // 只对这些定价 Key 对冲，为空时对路由上的所有请求对冲
+}
+
+// DefaultHedgeConfig 超过最近 P95 首字节延迟 (至少 5ms) 时对冲，价格超过 20 时停止对冲
+func DefaultHedgeConfig() HedgeConfig {
+	return HedgeConfig{Percentile: 0.95, MinDelay: 5 * time.Millisecond, MaxPrice: 20}
+}
+
+const (
+	hedgeSamples    = 256 // 保留最近多少个首字节延迟样本
+	hedgeMinSamples = 20  // 样本数不足时不对冲
+)
+
+// hedger 一条路由的对冲状态：最近的首字节延迟样本
+type hedger struct {
+	cfg    HedgeConfig
+	keys   map[string]bool
+	prices PriceSource
+
+	mu      sync.Mutex
+	samples [hedgeSamples]time.Duration
+	n       int // 累计样本数
+}
+
+func newHedger(cfg HedgeConfig, prices PriceSource) *hedger {
+	h := &hedger{cfg: cfg, prices: prices}
+	if len(cfg.Keys) > 0 {
+		h.keys = make(map[string]bool, len(cfg.Keys))
+		for _, key := range cfg.Keys {
+			h.keys[key] = true
+		}
+	}
+	return h
+}
+
+// matches 该 Key 是否开启了对冲
+func (h *hedger) matches(key string) bool {
+	return h.keys == nil || h.keys[key]
+}
+
+// observe 记录一个首字节延迟样本
+func (h *hedger) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples[h.n%hedgeSamples] = d
+	h.n++
+}
+
+// delay 当前的对冲延迟；样本不足时返回 false
+func (h *hedger) delay() (time.Duration, bool) {
+	h.mu.Lock()
+	n := min(h.n, hedgeSamples)
+	if n < hedgeMinSamples {
+		h.mu.Unlock()
+		return 0, false
+	}
+	sorted := slices.Clone(h.samples[:n])
+	h.mu.Unlock()
+
+	slices.Sort(sorted)
+	idx := min(int(h.cfg.Percentile*float64(n)), n-1)
+	return max(sorted[idx], h.cfg.MinDelay), true
+}
+
+// suppressed 价格过高时不对冲
+func (h *hedger) suppressed(key string) (int, bool) {
+	if h.cfg.MaxPrice <= 0 || h.prices == nil {
+		return 0, false
+	}
+	price := h.prices.GetPrice(key)
+	return price, price > h.cfg.MaxPrice
+}
+
+// hedgeKey 请求上下文中的 hedger：没有对冲的请求也要记录首字节延迟
+type hedgeKey struct{}
+
+// serveHedged 对冲转发；不适合对冲的请求返回 false，由调用方正常转发
+func (lb *SimpleLoadBalancer) serveHedged(w http.ResponseWriter, r *http.Request, strategy Strategy, h *hedger) bool {
+	key := keyOf(r)
+	if !h.matches(key) {
+		return false
+	}
+	// 和重试一样，只有可以安全地发送多次的请求才对冲 (有副作用的 tools/call 不能执行两遍)
+	body, safe := lb.replayable(r)
+	delay, ok := h.delay()
+	if ok && safe {
+		if price, high := h.suppressed(key); high {
+			metrics.Hedges.WithLabelValues(key, "suppressed").Inc()
+			fmt.Printf("🚧 [Hedge] 价格 %d > %d，不对冲 [%s]\n", price, h.cfg.MaxPrice, key)
+			ok = false
+		}
+	}
+	if !ok || !safe {
+		// 不对冲：正常转发 (可以重试)，顺便采集首字节延迟
+		lb.serve(w, r.WithContext(context.WithValue(r.Context(), hedgeKey{}, h)), strategy)
+		return true
+	}
+	primary := lb.pick(strategy, nil)
+	if primary == nil {
+		return false
+	}
+	if lb.budget != nil {
+		lb.budget.request()
+	}
+	lb.race(w, r, strategy, h, key, body, primary, delay)
+	return true
+}
+
+// race 先向 primary 转发，delay 之后还没有首字节 (或者 primary 直接失败) 时向另一个后端再发一份
+// 先写出响应头的一方胜出，另一方通过 context 取消
+func (lb *SimpleLoadBalancer) race(w http.ResponseWriter, r *http.Request, strategy Strategy, h *hedger, key string, body []byte, primary *Backend, delay time.Duration) {
+	start := time.Now()
+	rc := &race{w: w, base: w.Header().Clone(), claimed: make(chan struct{})}
+	results := make(chan *hedgeWriter, 2)
+	launch := func(b *Backend, hedge bool) {
+		ctx, cancel := context.WithCancel(r.Context())
+		hw := rc.add(cancel, hedge)
+		go func() {
+			defer cancel()
+			lb.runAttempt(hw, r.WithContext(ctx), strategy, b, body)
+			results <- hw
+		}()
+	}
+
+	launch(primary, false)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	timerC, claimed := timer.C, rc.claimed
+	pending, hedged := 1, false
+	hedge := func(reason string) {
+		hedged, timerC = true, nil
+		if rc.winner.Load() != nil {
+			return
+		}
+		next := lb.pick(strategy, []*Backend{primary})
+		if next == nil {
+			return
+		}
+		// 对冲副本和重试一样是额外的后端负载：从网关级重试预算中扣除，并计入该 Key 的成本
+		if lb.budget != nil && !lb.budget.withdraw() {
+			if next.breaker != nil {
+				next.breaker.cancel()
+			}
+			metrics.Hedges.WithLabelValues(key, "budget_exhausted").Inc()
+			fmt.Printf("🛑 [Hedge] 重试预算用完，不对冲 [%s]\n", key)
+			return
+		}
+		controller.CountRetry(r.Context())
+		metrics.Hedges.WithLabelValues(key, "sent").Inc()
+		fmt.Printf("🪁 [Hedge] %s，对冲 [%s] %s -> %s\n", reason, key, primary.URL.Host, next.URL.Host)
+		launch(next, true)
+		pending++
+	}
+
+	for pending > 0 {
+		select {
+		case <-timerC:
+			hedge(fmt.Sprintf("%v 内没有首字节", delay))
+		case <-claimed:
+			// 已经有响应了，不再对冲
+			claimed, timerC = nil, nil
+		case hw := <-results:
+			pending--
+			if hw.err != nil && !hedged && rc.winner.Load() == nil && r.Context().Err() == nil {
+				hedge("首个后端转发失败")
+			}
+		}
+	}
+
+	winner := rc.winner.Load()
+	if winner == nil {
+		proxyFailed(w, r)
+		return
+	}
+	h.observe(rc.firstByte.Sub(start))
+	if winner.hedge {
+		metrics.Hedges.WithLabelValues(key, "won").Inc()
+	}
+	if winner.aborted {
+		// 与普通转发一致：响应写到一半出错时中止连接
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// runAttempt 在独立的 goroutine 中转发一次；输掉的一方写响应时会触发 ReverseProxy 的 ErrAbortHandler，这里吞掉
+func (lb *SimpleLoadBalancer) runAttempt(hw *hedgeWriter, r *http.Request, strategy Strategy, b *Backend, body []byte) {
+	defer func() {
+		if p := recover(); p != nil {
+			if p != http.ErrAbortHandler {
+				panic(p)
+			}
+			hw.aborted = true
+		}
+	}()
+	hw.err = lb.forward(hw, r, strategy, b, body)
+}
+
+// errHedgeLost 输掉的一方不能再写响应
+var errHedgeLost = errors.New("hedge: another backend already responded")
+
+// race 一次对冲中的所有尝试，共享真正的 ResponseWriter
+type race struct {
+	w         http.ResponseWriter
+	base      http.Header   // 转发前已经设置的响应头，每个尝试各拷一份
+	claimed   chan struct{} // 选出胜者时关闭
+	winner    atomic.Pointer[hedgeWriter]
+	firstByte time.Time
+
+	mu      sync.Mutex
+	writers []*hedgeWriter
+}
+
+func (rc *race) add(cancel context.CancelFunc, hedge bool) *hedgeWriter {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	hw := &hedgeWriter{race: rc, header: rc.base.Clone(), cancel: cancel, hedge: hedge}
+	rc.writers = append(rc.writers, hw)
+	if rc.winner.Load() != nil {
+		// 发出之前已经有响应了
+		cancel()
+	}
+	return hw
+}
+
+// claim 第一个写响应的一方胜出：响应头拷到真正的 ResponseWriter 上，取消其余的尝试
+func (rc *race) claim(hw *hedgeWriter) bool {
+	if rc.winner.Load() == hw {
+		return true
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.winner.Load() != nil {
+		return false
+	}
+	header := rc.w.Header()
+	clear(header)
+	for k, v := range hw.header {
+		header[k] = v
+	}
+	rc.firstByte = time.Now()
+	rc.winner.Store(hw)
+	close(rc.claimed)
+	for _, other := range rc.writers {
+		if other != hw {
+			other.cancel()
+		}
+	}
+	return true
+}
+
+// hedgeWriter 一次尝试看到的 ResponseWriter：胜出前写自己的响应头，胜出后直通真正的 ResponseWriter
+type hedgeWriter struct {
+	race   *race
+	header http.Header
+	cancel context.CancelFunc
+	hedge  bool // 是否为对冲副本
+
+	// 由执行该尝试的 goroutine 写入，race 在所有尝试结束后读取
+	err     error
+	aborted bool
+}
+
+func (hw *hedgeWriter) Header() http.Header {
+	if hw.race.winner.Load() == hw {
+		// 胜出之后 ReverseProxy 写的 Trailer 要落到真正的响应上
+		return hw.race.w.Header()
+	}
+	return hw.header
+}
+
+func (hw *hedgeWriter) WriteHeader(code int) {
+	if code < http.StatusOK {
+		// 1xx 不决定胜负，直接丢弃
+		return
+	}
+	if hw.race.claim(hw) {
+		hw.race.w.WriteHeader(code)
+	}
+}
+
+func (hw *hedgeWriter) Write(b []byte) (int, error) {
+	if !hw.race.claim(hw) {
+		return 0, errHedgeLost
+	}
+	return hw.race.w.Write(b)
+}
+
+// Flush 流式响应 (SSE) 需要逐帧刷出
+func (hw *hedgeWriter) Flush() {
+	if hw.race.winner.Load() == hw {
+		http.NewResponseController(hw.race.w).Flush()
+	}
+}
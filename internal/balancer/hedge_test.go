@@ -0,0 +1,133 @@
+package balancer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/controller"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newHedgePool 一个迟迟不返回的慢后端 + 一个立即返回的快后端，轮询时先打到慢的那个
+// 慢后端被取消时关闭 cancelled
+func newHedgePool(t *testing.T) (*SimpleLoadBalancer, <-chan struct{}) {
+	t.Helper()
+	cancelled := make(chan struct{})
+	var once sync.Once
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 读完请求体，服务端才能感知到连接断开
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			once.Do(func() { close(cancelled) })
+		case <-time.After(5 * time.Second):
+			io.WriteString(w, "slow")
+		}
+	}))
+	t.Cleanup(slow.Close)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "fast")
+	}))
+	t.Cleanup(fast.Close)
+
+	lb, err := NewLoadBalancer([]string{slow.URL, fast.URL},
+		WithHealthCheck(HealthCheckConfig{}),
+		WithStrategy(&RoundRobin{current: ^uint64(0)}),
+	)
+	if err != nil {
+		t.Fatalf("NewLoadBalancer: %v", err)
+	}
+	return lb, cancelled
+}
+
+// warmHedger 填满样本，对冲延迟为 MinDelay
+func warmHedger(cfg HedgeConfig, prices PriceSource) *hedger {
+	h := newHedger(cfg, prices)
+	for i := 0; i < hedgeMinSamples; i++ {
+		h.observe(time.Millisecond)
+	}
+	return h
+}
+
+func TestHedgeWinsAndCancelsSlowBackend(t *testing.T) {
+	lb, cancelled := newHedgePool(t)
+	h := warmHedger(HedgeConfig{Percentile: 0.95, MinDelay: 20 * time.Millisecond}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	if !lb.serveHedged(rec, req, lb.strategy, h) {
+		t.Fatal("serveHedged = false, want the request to be hedged")
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "fast" {
+		t.Fatalf("hedged request = %d %q, want 200 from the fast backend", rec.Code, rec.Body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("hedged request took %v, want it to finish once the hedge responds", elapsed)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("slow backend was not cancelled after the hedge won")
+	}
+}
+
+func TestHedgeSuppressedAbovePrice(t *testing.T) {
+	lb, _ := newHedgePool(t)
+	h := warmHedger(HedgeConfig{Percentile: 0.95, MinDelay: 20 * time.Millisecond, MaxPrice: 10}, fixedPrice(42))
+
+	// 价格过高：不对冲，请求只发给慢后端；客户端超时放弃
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	ctx, cancel := context.WithTimeout(req.Context(), 200*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	lb.serveHedged(rec, req.WithContext(ctx), lb.strategy, h)
+	if rec.Body.String() == "fast" {
+		t.Error("request was hedged to the fast backend although the price is above max_price")
+	}
+}
+
+func TestHedgeSkipsUnsafeRequest(t *testing.T) {
+	lb, _ := newHedgePool(t)
+	h := warmHedger(HedgeConfig{Percentile: 0.95, MinDelay: 20 * time.Millisecond}, nil)
+
+	// 有副作用的 tools/call 不能执行两遍：只发给慢后端，客户端超时放弃
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"send_email"}}`))
+	ctx, cancel := context.WithTimeout(req.Context(), 200*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	lb.serveHedged(rec, req.WithContext(ctx), lb.strategy, h)
+	if rec.Body.String() == "fast" {
+		t.Error("tools/call was hedged to a second backend")
+	}
+}
+
+func TestHedgeChargesRetryBudget(t *testing.T) {
+	lb, _ := newHedgePool(t)
+	lb.budget = NewRetryBudget(0, 0) // 一次都不允许
+	h := warmHedger(HedgeConfig{Percentile: 0.95, MinDelay: 20 * time.Millisecond}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	ctx, cancel := context.WithTimeout(req.Context(), 200*time.Millisecond)
+	defer cancel()
+	ctx, retries := controller.WithRetryCounter(ctx)
+	rec := httptest.NewRecorder()
+	lb.serveHedged(rec, req.WithContext(ctx), lb.strategy, h)
+	if rec.Body.String() == "fast" || retries.Load() != 0 {
+		t.Errorf("hedge with exhausted budget = %q with %d retries counted, want no hedge", rec.Body, retries.Load())
+	}
+
+	// 预算充足时对冲副本计入重试次数
+	lb.budget = NewRetryBudget(0, 10)
+	req = httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	ctx, retries = controller.WithRetryCounter(req.Context())
+	rec = httptest.NewRecorder()
+	lb.serveHedged(rec, req.WithContext(ctx), lb.strategy, h)
+	if rec.Body.String() != "fast" || retries.Load() != 1 {
+		t.Errorf("hedge with budget = %q with %d retries counted, want \"fast\" with 1", rec.Body, retries.Load())
+	}
+}
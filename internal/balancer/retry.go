@@ -0,0 +1,157 @@
+package balancer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"rajomon-gateway/internal/model"
+	"sync"
+	"time"
+)
+
+// RetryConfig 失败重试：只在还没有向客户端写出任何字节 (连接失败、后端没有返回响应头) 时，
+// 换一个本次请求还没试过的后端重试
+type RetryConfig struct {
+	MaxRetries  int      // 每个请求最多重试几次
+	SafeMethods []string // 可以重试的 MCP (JSON-RPC) 方法；GET / PUT / DELETE 等幂等方法总是可以重试
+}
+
+// DefaultRetryConfig 最多重试 1 次；MCP 只重试只读、没有副作用的方法
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries:  1,
+		SafeMethods: []string{"ping", "tools/list", "resources/list", "resources/templates/list", "resources/read", "prompts/list", "prompts/get"},
+	}
+}
+
+// 默认重试预算：重试数不超过请求数的 10%，每个窗口保底 10 次
+const (
+	DefaultRetryBudgetRatio = 0.1
+	DefaultMinRetries       = 10
+)
+
+// retryBudgetWindow 重试预算的统计窗口
+const retryBudgetWindow = 10 * time.Second
+
+// RetryBudget 网关级的重试预算：窗口内的重试数不超过请求数的 ratio 倍 (外加 minRetries 次保底)
+// 所有后端池共用一份，后端整体过载时重试不会把流量放大
+type RetryBudget struct {
+	ratio      float64
+	minRetries int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	window slidingWindow // 请求数 / 重试数
+}
+
+// NewRetryBudget ratio: 重试数占请求数的比例上限；minRetries: 每个窗口 (10s) 保底允许的重试次数，低流量时也能重试
+func NewRetryBudget(ratio float64, minRetries int) *RetryBudget {
+	return &RetryBudget{ratio: ratio, minRetries: minRetries, now: time.Now, window: slidingWindow{size: retryBudgetWindow}}
+}
+
+// request 每个请求 (不含重试) 调用一次
+func (b *RetryBudget) request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.window.add(b.now(), 1, 0)
+}
+
+// withdraw 占用一次重试，预算用完返回 false
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	requests, retries := b.window.sum(now)
+	if float64(retries) >= b.ratio*float64(requests)+float64(b.minRetries) {
+		return false
+	}
+	b.window.add(now, 0, 1)
+	return true
+}
+
+// idempotentMethods RFC 9110 中的幂等方法
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// maxRetryBody 最多缓存多少字节的请求体用于重试和对冲，更大的请求体不重试、不对冲
+const maxRetryBody = 1 << 20
+
+// retryBody 判断请求能否重试；可以重试时返回缓存下来的请求体 (每次尝试重新发送)
+// 读过的部分总会拼回 r.Body，不能重试的请求照常转发
+func (lb *SimpleLoadBalancer) retryBody(r *http.Request) ([]byte, bool) {
+	if lb.retry == nil || lb.retry.MaxRetries <= 0 {
+		return nil, false
+	}
+	return lb.replayable(r)
+}
+
+// replayable 请求能否安全地发送多次 (重试、对冲)：幂等的 HTTP 方法，或者只包含白名单方法的 MCP 调用
+// 可以时返回缓存下来的请求体
+func (lb *SimpleLoadBalancer) replayable(r *http.Request) ([]byte, bool) {
+	idempotent := idempotentMethods[r.Method]
+	if !idempotent && (r.Method != http.MethodPost || len(lb.safeMethods) == 0) {
+		return nil, false
+	}
+	buf, ok := bufferBody(r)
+	if !ok {
+		return nil, false
+	}
+	if buf == nil {
+		return nil, idempotent
+	}
+	return buf, idempotent || lb.safeJSONRPC(buf)
+}
+
+// bufferBody 缓存请求体，以便发送多次 (重试、对冲)；没有请求体时返回 nil
+// 读过的部分总会拼回 r.Body，请求体过大或读取失败时返回 false，请求照常转发
+func bufferBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxRetryBody+1))
+	if err != nil || len(buf) > maxRetryBody {
+		r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+		return nil, false
+	}
+	r.Body = &replayBody{Reader: bytes.NewReader(buf), Closer: r.Body}
+	return buf, true
+}
+
+// safeJSONRPC 请求体中的 JSON-RPC 方法 (批量请求的每一条) 都在白名单里
+func (lb *SimpleLoadBalancer) safeJSONRPC(body []byte) bool {
+	body = bytes.TrimSpace(body)
+	var batch []model.JSONRPCRequest
+	if len(body) > 0 && body[0] == '[' {
+		if json.Unmarshal(body, &batch) != nil {
+			return false
+		}
+	} else {
+		var msg model.JSONRPCRequest
+		if json.Unmarshal(body, &msg) != nil {
+			return false
+		}
+		batch = append(batch, msg)
+	}
+	if len(batch) == 0 {
+		return false
+	}
+	for _, msg := range batch {
+		if !lb.safeMethods[msg.Method] {
+			return false
+		}
+	}
+	return true
+}
+
+// replayBody 已读出的部分 + 剩余部分，Close 时关闭原始请求体
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,79 @@
+package balancer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/controller"
+	"strings"
+	"testing"
+)
+
+// newRetryPool 一个已经关闭的后端 (连接失败) + 一个回显请求体的健康后端，轮询时先打到关闭的那个
+func newRetryPool(t *testing.T, cfg RetryConfig, budget *RetryBudget) *SimpleLoadBalancer {
+	t.Helper()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(alive.Close)
+
+	lb, err := NewLoadBalancer([]string{dead.URL, alive.URL},
+		WithHealthCheck(HealthCheckConfig{}),
+		WithStrategy(&RoundRobin{current: ^uint64(0)}),
+		WithRetry(cfg, budget),
+	)
+	if err != nil {
+		t.Fatalf("NewLoadBalancer: %v", err)
+	}
+	return lb
+}
+
+func serveWithRetries(lb *SimpleLoadBalancer, method, body string) (*httptest.ResponseRecorder, int32) {
+	req := httptest.NewRequest(method, "/mcp", strings.NewReader(body))
+	ctx, retries := controller.WithRetryCounter(controller.WithKey(req.Context(), "/mcp"))
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, req.WithContext(ctx))
+	return rec, retries.Load()
+}
+
+func TestRetriesSafeRequestOnAnotherBackend(t *testing.T) {
+	lb := newRetryPool(t, DefaultRetryConfig(), NewRetryBudget(0.5, 10))
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	rec, retries := serveWithRetries(lb, http.MethodPost, body)
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Fatalf("safe MCP call = %d %q, want 200 with the request body replayed", rec.Code, rec.Body)
+	}
+	if retries != 1 {
+		t.Errorf("retries counted = %d, want 1", retries)
+	}
+}
+
+func TestDoesNotRetryUnsafeRequest(t *testing.T) {
+	lb := newRetryPool(t, DefaultRetryConfig(), NewRetryBudget(0.5, 10))
+
+	rec, retries := serveWithRetries(lb, http.MethodPost, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"send_email"}}`)
+	if rec.Code != http.StatusBadGateway || retries != 0 {
+		t.Errorf("tools/call = %d with %d retries, want 502 without retrying", rec.Code, retries)
+	}
+}
+
+func TestRetryBudgetLimitsRetries(t *testing.T) {
+	// 没有保底，比例 0：一次重试都不允许
+	lb := newRetryPool(t, DefaultRetryConfig(), NewRetryBudget(0, 0))
+
+	rec, retries := serveWithRetries(lb, http.MethodGet, "")
+	if rec.Code != http.StatusBadGateway || retries != 0 {
+		t.Errorf("GET with exhausted budget = %d with %d retries, want 502 without retrying", rec.Code, retries)
+	}
+
+	budget := NewRetryBudget(0.5, 0)
+	for i := 0; i < 4; i++ {
+		budget.request()
+	}
+	if !budget.withdraw() || !budget.withdraw() || budget.withdraw() {
+		t.Error("budget with ratio 0.5 over 4 requests should allow exactly 2 retries")
+	}
+}
@@ -0,0 +1,122 @@
+package balancer
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+)
+
+// Strategy 后端选择策略
+// Pick 只会拿到当前可用 (健康) 的后端，且至少有一个
+type Strategy interface {
+	Name() string
+	Pick(backends []*Backend) *Backend
+}
+
+// StrategyByName 按名称创建策略:
+// round_robin / least_outstanding / p2c / ewma / lowest_price
+func StrategyByName(name string) (Strategy, error) {
+	switch name {
+	case "", "round_robin":
+		return &RoundRobin{}, nil
+	case "least_outstanding":
+		return LeastOutstanding{}, nil
+	case "p2c":
+		return PowerOfTwoChoices{}, nil
+	case "ewma":
+		return EWMALatency{}, nil
+	case "lowest_price":
+		return LowestPrice{}, nil
+	default:
+		return nil, fmt.Errorf("未知的负载均衡策略: %q", name)
+	}
+}
+
+// RoundRobin 轮询
+type RoundRobin struct {
+	current uint64
+}
+
+func (s *RoundRobin) Name() string { return "round_robin" }
+
+func (s *RoundRobin) Pick(backends []*Backend) *Backend {
+	idx := atomic.AddUint64(&s.current, 1) % uint64(len(backends))
+	return backends[idx]
+}
+
+// LeastOutstanding 选择在途请求最少的后端
+type LeastOutstanding struct{}
+
+func (LeastOutstanding) Name() string { return "least_outstanding" }
+
+func (LeastOutstanding) Pick(backends []*Backend) *Backend {
+	// 从随机位置开始扫描，避免并列时永远选第一个
+	offset := rand.Intn(len(backends))
+	best := backends[offset]
+	for i := 1; i < len(backends); i++ {
+		b := backends[(offset+i)%len(backends)]
+		if b.InFlight() < best.InFlight() {
+			best = b
+		}
+	}
+	return best
+}
+
+// PowerOfTwoChoices 随机挑两个，选在途请求少的那个
+// 比全局最少连接开销小，又能避免大家同时涌向同一个 "最空闲" 的后端
+type PowerOfTwoChoices struct{}
+
+func (PowerOfTwoChoices) Name() string { return "p2c" }
+
+func (PowerOfTwoChoices) Pick(backends []*Backend) *Backend {
+	if len(backends) == 1 {
+		return backends[0]
+	}
+	i := rand.Intn(len(backends))
+	j := rand.Intn(len(backends) - 1)
+	if j >= i {
+		j++
+	}
+	a, b := backends[i], backends[j]
+	if b.InFlight() < a.InFlight() {
+		return b
+	}
+	return a
+}
+
+// EWMALatency 按 "EWMA 首字节延迟 × (在途请求 + 1)" 打分，选最低的
+// 还没有延迟样本的后端得分为 0，会被优先探索
+type EWMALatency struct{}
+
+func (EWMALatency) Name() string { return "ewma" }
+
+func (EWMALatency) Pick(backends []*Backend) *Backend {
+	offset := rand.Intn(len(backends))
+	best := backends[offset]
+	bestScore := best.EWMALatency() * float64(best.InFlight()+1)
+	for i := 1; i < len(backends); i++ {
+		b := backends[(offset+i)%len(backends)]
+		if score := b.EWMALatency() * float64(b.InFlight()+1); score < bestScore {
+			best, bestScore = b, score
+		}
+	}
+	return best
+}
+
+// LowestPrice Rajomon 原生策略：选择后端在 Price 响应头里广播的价格最低的那个
+// 价格相同时选在途请求少的；还没广播过价格的后端视为 0，会被优先探索
+type LowestPrice struct{}
+
+func (LowestPrice) Name() string { return "lowest_price" }
+
+func (LowestPrice) Pick(backends []*Backend) *Backend {
+	offset := rand.Intn(len(backends))
+	best := backends[offset]
+	for i := 1; i < len(backends); i++ {
+		b := backends[(offset+i)%len(backends)]
+		if b.Price() < best.Price() || (b.Price() == best.Price() && b.InFlight() < best.InFlight()) {
+			best = b
+		}
+	}
+	return best
+}
@@ -0,0 +1,116 @@
+package balancer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// testBackends 按 (在途请求, 价格, EWMA 延迟) 构造后端，Host 依次为 b0, b1, ...
+func testBackends(specs ...[3]int64) []*Backend {
+	backends := make([]*Backend, len(specs))
+	for i, spec := range specs {
+		b := newBackend(&url.URL{Scheme: "http", Host: fmt.Sprintf("b%d", i)})
+		b.inFlight.Store(spec[0])
+		b.price.Store(spec[1])
+		b.ewmaLatency = float64(spec[2])
+		backends[i] = b
+	}
+	return backends
+}
+
+func TestStrategyPick(t *testing.T) {
+	cases := []struct {
+		strategy Strategy
+		backends [][3]int64 // 在途请求, 价格, EWMA 延迟 (ms)
+		want     string
+	}{
+		{LeastOutstanding{}, [][3]int64{{5, 0, 0}, {1, 0, 0}, {3, 0, 0}}, "b1"},
+		{PowerOfTwoChoices{}, [][3]int64{{5, 0, 0}, {1, 0, 0}}, "b1"},
+		{PowerOfTwoChoices{}, [][3]int64{{5, 0, 0}}, "b0"},
+		{EWMALatency{}, [][3]int64{{0, 0, 100}, {1, 0, 20}, {0, 0, 50}}, "b1"}, // 100×1, 20×2, 50×1
+		{EWMALatency{}, [][3]int64{{3, 0, 10}, {0, 0, 0}}, "b1"},               // 没有样本的后端优先探索
+		{EWMALatency{}, [][3]int64{{0, 0, 20}, {3, 0, 10}}, "b0"},              // 20×1 < 10×4
+		{LowestPrice{}, [][3]int64{{0, 9, 0}, {5, 3, 0}, {0, 7, 0}}, "b1"},     // 最便宜的即使更忙
+		{LowestPrice{}, [][3]int64{{4, 3, 0}, {1, 3, 0}, {0, 7, 0}}, "b1"},     // 同价选在途少的
+		{LowestPrice{}, [][3]int64{{0, 5, 0}, {0, 0, 0}}, "b1"},                // 没广播过价格的后端优先探索
+		{LeastOutstanding{}, [][3]int64{{2, 0, 0}, {2, 0, 0}, {0, 0, 0}, {2, 0, 0}}, "b2"},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%s/%v", tc.strategy.Name(), tc.backends), func(t *testing.T) {
+			backends := testBackends(tc.backends...)
+			// 多选几次：起始位置是随机的，结果不能依赖它
+			for range 20 {
+				if got := tc.strategy.Pick(backends).URL.Host; got != tc.want {
+					t.Fatalf("Pick() = %s, want %s", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundRobinCycles(t *testing.T) {
+	backends := testBackends([3]int64{}, [3]int64{}, [3]int64{})
+	rr := &RoundRobin{}
+	var got []string
+	for range 6 {
+		got = append(got, rr.Pick(backends).URL.Host)
+	}
+	if fmt.Sprint(got) != "[b1 b2 b0 b1 b2 b0]" {
+		t.Errorf("round robin picks = %v, want each backend in turn", got)
+	}
+}
+
+func TestStrategyByName(t *testing.T) {
+	for _, name := range []string{"round_robin", "least_outstanding", "p2c", "ewma", "lowest_price"} {
+		s, err := StrategyByName(name)
+		if err != nil || s.Name() != name {
+			t.Errorf("StrategyByName(%q) = %v, %v", name, s, err)
+		}
+	}
+	if s, err := StrategyByName(""); err != nil || s.Name() != "round_robin" {
+		t.Errorf("StrategyByName(\"\") = %v, %v, want round_robin", s, err)
+	}
+	if _, err := StrategyByName("fastest"); err == nil {
+		t.Error("StrategyByName(\"fastest\") succeeded, want an error")
+	}
+}
+
+func TestLowestPriceReadsPriceHeader(t *testing.T) {
+	var targets []string
+	for _, price := range []int{7, 3, 9} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Price", strconv.Itoa(price))
+			w.Write([]byte(strconv.Itoa(price)))
+		}))
+		t.Cleanup(srv.Close)
+		targets = append(targets, srv.URL)
+	}
+	lb, err := NewLoadBalancer(targets, WithHealthCheck(HealthCheckConfig{}), WithStrategy(LowestPrice{}))
+	if err != nil {
+		t.Fatalf("NewLoadBalancer: %v", err)
+	}
+	serve := func() string {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+		return rec.Body.String()
+	}
+
+	// 前三个请求探索还没广播过价格的后端，之后全部落到最便宜的那个
+	for range 3 {
+		serve()
+	}
+	for _, b := range lb.Backends() {
+		if b.Price() == 0 {
+			t.Fatalf("backend %s has no price after every backend was explored", b.URL.Host)
+		}
+	}
+	for range 10 {
+		if got := serve(); got != "3" {
+			t.Fatalf("request served by the backend priced %s, want the one priced 3", got)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package balancer
+
+import "time"
+
+// windowBuckets 滑动窗口切分的桶数，窗口按桶整体滑动
+const windowBuckets = 10
+
+// windowBucket 滑动窗口中的一个桶
+type windowBucket struct {
+	start time.Time
+	total int
+	hits  int
+}
+
+// slidingWindow 按桶滑动的计数窗口：总数 + 其中命中的次数 (失败数、重试数)
+// 不加锁，由使用方保护
+type slidingWindow struct {
+	size    time.Duration
+	buckets [windowBuckets]windowBucket
+}
+
+// add 在 now 所在的桶里累加，桶过期时先清零
+func (w *slidingWindow) add(now time.Time, total, hits int) {
+	width := max(w.size/windowBuckets, 1)
+	start := now.Truncate(width)
+	b := &w.buckets[(start.UnixNano()/int64(width))%windowBuckets]
+	if !b.start.Equal(start) {
+		*b = windowBucket{start: start}
+	}
+	b.total += total
+	b.hits += hits
+}
+
+// sum 窗口内的总数和命中数
+func (w *slidingWindow) sum(now time.Time) (total, hits int) {
+	for _, b := range w.buckets {
+		if now.Sub(b.start) < w.size {
+			total += b.total
+			hits += b.hits
+		}
+	}
+	return total, hits
+}
+
+// reset 清空窗口
+func (w *slidingWindow) reset() {
+	w.buckets = [windowBuckets]windowBucket{}
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Config 网关配置
+// 优先级 (低 -> 高): 内置默认值 < 配置文件 < 环境变量 < 命令行参数
+type Config struct {
+	Listen        string `yaml:"listen" json:"listen"`                           // 业务端口
+	MetricsListen string `yaml:"metrics_listen,omitempty" json:"metrics_listen"` // 为空时 /metrics 挂在业务端口上
+	AdminListen   string `yaml:"admin_listen,omitempty" json:"admin_listen"`     // 为空时不开启管理接口
+
+	Pools   map[string]PoolConfig `yaml:"pools" json:"pools"`
+	Routes  []RouteConfig         `yaml:"routes" json:"routes"`
+	Pricing PricingConfig         `yaml:"pricing,omitempty" json:"pricing"`
+
+	Account        *AccountConfig `yaml:"account,omitempty" json:"account"`                 // 为空时不启用网关账户
+	AdmissionQueue *QueueConfig   `yaml:"admission_queue,omitempty" json:"admission_queue"` // 为空时不排队，出价不足立即 429
+	Trace          *TraceConfig   `yaml:"trace,omitempty" json:"trace"`                     // 为空时不记录请求追踪
+	Admin          *AdminConfig   `yaml:"admin,omitempty" json:"admin"`                     // 管理接口的鉴权与审计
+	Retry          *RetryConfig   `yaml:"retry,omitempty" json:"retry"`                     // 为空时转发失败直接 502，不重试
+
+	DeadlineAdmission *DeadlineAdmissionConfig `yaml:"deadline_admission,omitempty" json:"deadline_admission"` // 为空时只按价格准入，不看超时预算
+}
+
+// PoolConfig 一组后端
+type PoolConfig struct {
+	Backends    []string          `yaml:"backends" json:"backends"`
+	Strategy    string            `yaml:"strategy,omitempty" json:"strategy"` // round_robin (默认) / least_outstanding / p2c / ewma / lowest_price
+	HealthCheck HealthCheckConfig `yaml:"health_check" json:"health_check"`
+	Breaker     *BreakerConfig    `yaml:"breaker,omitempty" json:"breaker"` // 为空时不启用熔断
+}
+
+// HealthCheckConfig 后端健康检查 (见 balancer.HealthCheckConfig)
+type HealthCheckConfig struct {
+	Path               string   `yaml:"path" json:"path"`
+	Interval           Duration `yaml:"interval" json:"interval"`
+	Timeout            Duration `yaml:"timeout" json:"timeout"`
+	HealthyThreshold   int      `yaml:"healthy_threshold" json:"healthy_threshold"`
+	UnhealthyThreshold int      `yaml:"unhealthy_threshold" json:"unhealthy_threshold"`
+	EjectThreshold     int      `yaml:"eject_threshold" json:"eject_threshold"`
+	EjectDuration      Duration `yaml:"eject_duration" json:"eject_duration"`
+}
+
+// BreakerConfig 每个后端一个的熔断器 (见 balancer.BreakerConfig)
+type BreakerConfig struct {
+	Window              Duration `yaml:"window" json:"window"`
+	MinRequests         int      `yaml:"min_requests" json:"min_requests"`
+	ErrorRate           float64  `yaml:"error_rate" json:"error_rate"`                     // 0 表示不按错误率熔断
+	ConsecutiveFailures int      `yaml:"consecutive_failures" json:"consecutive_failures"` // 0 表示不按连续失败熔断
+	OpenDuration        Duration `yaml:"open_duration" json:"open_duration"`
+	HalfOpenRequests    int      `yaml:"half_open_requests" json:"half_open_requests"`
+}
+
+// RouteConfig 一条路由：转发到后端池，或者交给内置处理器
+type RouteConfig struct {
+	Path     string        `yaml:"path" json:"path"`
+	Pool     string        `yaml:"pool,omitempty" json:"pool"`         // 后端池名称
+	Handler  string        `yaml:"handler,omitempty" json:"handler"`   // 内置处理器 (context)，与 pool 二选一
+	Strategy string        `yaml:"strategy,omitempty" json:"strategy"` // 覆盖后端池的负载均衡策略
+	Key      string        `yaml:"key" json:"key"`                     // 定价 Key: path (默认) / method / tool / model / header:<Name>
+	Pricing  *PolicyConfig `yaml:"pricing,omitempty" json:"pricing"`   // 覆盖默认定价策略
+	Hedge    *HedgeConfig  `yaml:"hedge,omitempty" json:"hedge"`       // 对冲请求，为空时不对冲
+	// AllowKeys 允许单独定价的派生 Key，不在其中的子键归入 "<路由>:other"
+	// pricing.keys 和 hedge.keys 里属于本路由的 Key、MCP 规范中的方法名自动允许
+	AllowKeys []string `yaml:"allow_keys,omitempty" json:"allow_keys"`
+}
+
+// HedgeConfig 对冲请求 (见 balancer.HedgeConfig)
+type HedgeConfig struct {
+	Percentile float64  `yaml:"percentile" json:"percentile"` // 首字节延迟超过最近请求的该分位数时对冲
+	MinDelay   Duration `yaml:"min_delay" json:"min_delay"`
+	MaxPrice   int      `yaml:"max_price" json:"max_price"` // 价格高于该值时不对冲 (0 表示不限)
+	Keys       []string `yaml:"keys,omitempty" json:"keys"` // 只对这些定价 Key 对冲，为空时对整条路由对冲
+}
+
+// PricingConfig 定价
+type PricingConfig struct {
+	Policy      PolicyConfig            `yaml:"policy" json:"policy"`                     // 默认策略
+	Keys        map[string]PolicyConfig `yaml:"keys,omitempty" json:"keys"`               // 按 Key 覆盖 (可以是派生 Key，如 "/mcp:tools/call/web_search")
+	Aggregation string                  `yaml:"aggregation" json:"aggregation"`           // own (默认) / max / sum
+	UpdateLoop  *UpdateLoopConfig       `yaml:"update_loop,omitempty" json:"update_loop"` // 为空时每个请求结束都调价
+}
+
+// UpdateLoopConfig 周期性调价 (见 controller.UpdateLoopConfig)
+type UpdateLoopConfig struct {
+	Interval  Duration `yaml:"interval" json:"interval"`
+	DecayStep int      `yaml:"decay_step" json:"decay_step"`
+	Floor     int      `yaml:"floor" json:"floor"`
+	Ceiling   int      `yaml:"ceiling" json:"ceiling"`
+}
+
+// AccountConfig 网关侧账户 (见 account.LedgerConfig)
+type AccountConfig struct {
+	Store          string       `yaml:"store" json:"store"` // memory / file:<path>
+	InitialBalance int64        `yaml:"initial_balance" json:"initial_balance"`
+	MaxBalance     int64        `yaml:"max_balance" json:"max_balance"`
+	Refill         RefillConfig `yaml:"refill" json:"refill"`
+	FlushInterval  Duration     `yaml:"flush_interval" json:"flush_interval"` // file 存储的落盘周期 (0 表示每次修改同步落盘)
+	// Keys 客户端 -> API Key：只有这些客户端有账户 (启动时开户)，请求用 Authorization: Bearer <key> 或 X-API-Key 表明身份
+	Keys map[string]string `yaml:"keys" json:"keys"`
+}
+
+// RefillConfig 账户充值
+type RefillConfig struct {
+	Dist     string   `yaml:"dist" json:"dist"` // poisson / fixed / uniform
+	Interval Duration `yaml:"interval" json:"interval"`
+	Step     int64    `yaml:"step" json:"step"`
+}
+
+// QueueConfig 准入等待队列 (见 middleware.AdmissionQueueConfig)
+type QueueConfig struct {
+	MaxWait      Duration `yaml:"max_wait" json:"max_wait"`
+	MaxDepth     int      `yaml:"max_depth" json:"max_depth"`
+	MaxInFlight  int      `yaml:"max_inflight" json:"max_inflight"`
+	PollInterval Duration `yaml:"poll_interval" json:"poll_interval"`
+}
+
+// TraceConfig 请求追踪
+type TraceConfig struct {
+	File      string `yaml:"file" json:"file"`
+	BodyLimit int    `yaml:"body_limit" json:"body_limit"`
+}
+
+// RetryConfig 转发失败时换后端重试 (见 balancer.RetryConfig)，所有后端池共用一份重试预算
+type RetryConfig struct {
+	MaxRetries  int      `yaml:"max_retries" json:"max_retries"`
+	Budget      float64  `yaml:"budget" json:"budget"`             // 重试数占请求数的比例上限 (10s 滑动窗口)
+	MinRetries  int      `yaml:"min_retries" json:"min_retries"`   // 每个窗口保底允许的重试次数
+	SafeMethods []string `yaml:"safe_methods" json:"safe_methods"` // 可以重试的 MCP 方法 (幂等 HTTP 方法总是可以重试)
+}
+
+// DeadlineAdmissionConfig 截止时间准入 (见 middleware.DeadlineAdmissionConfig)
+type DeadlineAdmissionConfig struct {
+	Headroom      float64  `yaml:"headroom" json:"headroom"`             // 预测耗时 × headroom 超过剩余预算时拒绝
+	ProbeInterval Duration `yaml:"probe_interval" json:"probe_interval"` // 每个 Key 放行探测请求的间隔 (0 表示不探测)
+}
+
+// AdminConfig 管理接口的鉴权与审计 (admin_listen 非空时生效)
+type AdminConfig struct {
+	Tokens    map[string]string `yaml:"tokens" json:"tokens"`                   // 操作人 -> Bearer Token，审计日志按操作人记录
+	AuditFile string            `yaml:"audit_file,omitempty" json:"audit_file"` // 审计日志 (JSONL)，为空时只打印到标准输出
+}
+
+// Default 内置默认配置：与旧版硬编码行为一致
+// 一个 default 后端池 (localhost:9001)，/mcp/chat 和 /mcp 转发到该池，/context 使用内置处理器
+func Default() *Config {
+	return &Config{
+		Listen: ":8080",
+		Pools: map[string]PoolConfig{
+			"default": {Backends: []string{"http://localhost:9001"}, Strategy: "round_robin", HealthCheck: defaultHealthCheck()},
+		},
+		Routes: []RouteConfig{
+			{Path: "/mcp/chat", Pool: "default", Key: "path"},
+			{Path: "/mcp", Pool: "default", Key: "tool"},
+			{Path: "/context", Handler: "context", Key: "path"},
+		},
+		Pricing: PricingConfig{Policy: DefaultPolicyConfig(), Aggregation: "own"},
+	}
+}
+
+func defaultHealthCheck() HealthCheckConfig {
+	return HealthCheckConfig{
+		Path:               "/health",
+		Interval:           Duration(2 * time.Second),
+		Timeout:            Duration(time.Second),
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+		EjectThreshold:     5,
+		EjectDuration:      Duration(10 * time.Second),
+	}
+}
+
+// UnmarshalYAML 没写的健康检查参数保持默认值
+func (p *PoolConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*p = PoolConfig{Strategy: "round_robin", HealthCheck: defaultHealthCheck()}
+	type plain PoolConfig
+	return unmarshal((*plain)(p))
+}
+
+func (p *PoolConfig) UnmarshalJSON(b []byte) error {
+	*p = PoolConfig{Strategy: "round_robin", HealthCheck: defaultHealthCheck()}
+	type plain PoolConfig
+	return strictJSON(b, (*plain)(p))
+}
+
+// LoadFile 在默认配置之上叠加配置文件 (按扩展名识别 YAML / JSON)
+// 文件里出现的 pools / routes 会整体替换默认值；未知字段直接报错，防止拼写错误被静默忽略
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := Default()
+	// 写了 pools / routes 就以文件为准，而不是和默认路由合并
+	cfg.Pools, cfg.Routes = nil, nil
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, cfg)
+	case ".json":
+		err = strictJSON(data, cfg)
+	default:
+		return nil, fmt.Errorf("不支持的配置文件格式: %s (只支持 .yaml/.yml/.json)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+
+	def := Default()
+	if cfg.Pools == nil {
+		cfg.Pools = def.Pools
+	}
+	if cfg.Routes == nil {
+		cfg.Routes = def.Routes
+	}
+	return cfg, nil
+}
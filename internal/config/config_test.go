@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("Default().Validate() = %v", err)
+	}
+}
+
+func TestLoadYAMLKeepsUnsetParamsAtDefaults(t *testing.T) {
+	path := writeFile(t, "gw.yaml", `
+listen: ":9000"
+pools:
+  llm:
+    backends: [http://a:8080]
+    health_check: {interval: 5s}
+routes:
+  - {path: /mcp, pool: llm, key: tool, pricing: {name: aimd, aimd: {target_latency_ms: 300}}}
+pricing:
+  policy: {composite: {base_threshold: 400}}
+  update_loop: {interval: 50ms}
+`)
+	cfg, err := Load(Flags{ConfigPath: path}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.Pools["llm"].HealthCheck; got.Interval != Duration(5*time.Second) || got.UnhealthyThreshold != 3 {
+		t.Errorf("health check = %+v, want interval 5s and default thresholds", got)
+	}
+	if got := cfg.Pricing.Policy.Composite; got.BaseThreshold != 400 || got.Alpha != 0.2 {
+		t.Errorf("composite params = %+v, want base_threshold 400 and default alpha", got)
+	}
+	if got := cfg.Routes[0].Pricing.AIMD; got.TargetLatencyMs != 300 || got.IncreaseFactor != 1.5 {
+		t.Errorf("route aimd params = %+v", got)
+	}
+	if got := cfg.Pricing.UpdateLoop; got.Interval != Duration(50*time.Millisecond) || got.Floor != 1 {
+		t.Errorf("update loop = %+v", got)
+	}
+	if _, ok := cfg.Pools["default"]; ok {
+		t.Error("pools from file should replace the built-in default pool")
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := writeFile(t, "gw.json", `{"listen": ":9000", "pricing": {"policy": {"name": "aimd"}}}`)
+	cfg, err := Load(Flags{ConfigPath: path}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Pricing.Policy.Name != "aimd" || len(cfg.Routes) != 3 {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+}
+
+func TestUnknownFieldIsRejected(t *testing.T) {
+	path := writeFile(t, "gw.yaml", "listen: \":8080\"\nlisten_addr: \":9000\"\n")
+	if _, err := Load(Flags{ConfigPath: path}, env(nil)); err == nil || !strings.Contains(err.Error(), "listen_addr") {
+		t.Fatalf("Load() error = %v, want it to name the unknown field", err)
+	}
+}
+
+func TestUnknownNestedJSONFieldIsRejected(t *testing.T) {
+	cases := []struct{ name, json, field string }{
+		{"pool", `{"pools": {"llm": {"backends": ["http://a:8080"], "stratgy": "p2c"}}}`, "stratgy"},
+		{"policy", `{"pricing": {"policy": {"name": "composite", "composite": {"alpah": 0.5}}}}`, "alpah"},
+		{"route policy", `{"routes": [{"path": "/mcp", "pool": "default", "pricing": {"nmae": "aimd"}}]}`, "nmae"},
+		{"optional section", `{"pricing": {"update_loop": {"intervl": "1s"}}}`, "intervl"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeFile(t, "gw.json", tc.json)
+			if _, err := Load(Flags{ConfigPath: path}, env(nil)); err == nil || !strings.Contains(err.Error(), tc.field) {
+				t.Fatalf("Load() error = %v, want it to name %q", err, tc.field)
+			}
+		})
+	}
+}
+
+func TestPrecedenceFileEnvFlags(t *testing.T) {
+	path := writeFile(t, "gw.yaml", "listen: \":1000\"\npricing: {policy: {name: aimd}}\n")
+	cfg, err := Load(Flags{ConfigPath: path, Listen: ":3000"}, env(map[string]string{
+		"LISTEN_ADDR":      ":2000",
+		"PRICING_POLICY":   "queuing",
+		"BACKEND_HOSTS":    "http://x:1, http://y:2",
+		"PRICING_POLICIES": "/context=aimd,/mcp:tools/call/echo=aimd",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != ":3000" {
+		t.Errorf("Listen = %q, want flag value", cfg.Listen)
+	}
+	if cfg.Pricing.Policy.Name != "queuing" {
+		t.Errorf("policy = %q, want env value", cfg.Pricing.Policy.Name)
+	}
+	if got := cfg.Pools["default"].Backends; len(got) != 2 || got[1] != "http://y:2" {
+		t.Errorf("backends = %v", got)
+	}
+	if r := cfg.route("/context"); r.Pricing == nil || r.Pricing.Name != "aimd" {
+		t.Errorf("/context pricing = %+v", r.Pricing)
+	}
+	if _, ok := cfg.Pricing.Keys["/mcp:tools/call/echo"]; !ok {
+		t.Error("derived key policy should land in pricing.keys")
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Pools["default"] = PoolConfig{Backends: []string{"not a url"}, Strategy: "fastest", HealthCheck: defaultHealthCheck()}
+	cfg.Routes = append(cfg.Routes, RouteConfig{Path: "/x", Pool: "missing"})
+	cfg.Routes[1].AllowKeys = []string{"/mcp:tools/call/web_search", "/other:tools/call/web_search"}
+	cfg.Pricing.Aggregation = "avg"
+
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate() = %v, want *ValidationError", err)
+	}
+	for _, want := range []string{"pools.default.backends[0]", "pools.default.strategy", "routes[3].pool", "routes[1].allow_keys", "pricing.aggregation"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestBadEnvIsReported(t *testing.T) {
+	_, err := Load(Flags{}, env(map[string]string{"EJECT_THRESHOLD": "lots", "ROUTE_STRATEGIES": "/nope=p2c"}))
+	if err == nil || !strings.Contains(err.Error(), "EJECT_THRESHOLD") || !strings.Contains(err.Error(), "/nope") {
+		t.Fatalf("Load() error = %v", err)
+	}
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	if _, err := Load(Flags{AdminListen: ":9090"}, env(nil)); err == nil || !strings.Contains(err.Error(), "admin.tokens") {
+		t.Fatalf("Load() without token error = %v, want admin.tokens problem", err)
+	}
+
+	cfg, err := Load(Flags{AdminListen: ":9090"}, env(map[string]string{"ADMIN_TOKEN": "s3cret"}))
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if got := cfg.Admin.Tokens["admin"]; got != "s3cret" {
+		t.Errorf("admin token = %q, want %q", got, "s3cret")
+	}
+	if out := cfg.String(); strings.Contains(out, "s3cret") {
+		t.Errorf("String() leaks the admin token:\n%s", out)
+	}
+}
+
+func TestAccountRequiresKeys(t *testing.T) {
+	if _, err := Load(Flags{}, env(map[string]string{"ACCOUNT_STORE": "memory"})); err == nil || !strings.Contains(err.Error(), "account.keys") {
+		t.Fatalf("Load() without keys error = %v, want account.keys problem", err)
+	}
+
+	cfg, err := Load(Flags{}, env(map[string]string{"ACCOUNT_STORE": "memory", "ACCOUNT_KEYS": "alice=sk-1,bob=sk-2"}))
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if got := cfg.Account.Keys; got["alice"] != "sk-1" || got["bob"] != "sk-2" {
+		t.Errorf("account keys = %v, want alice=sk-1 bob=sk-2", got)
+	}
+	if out := cfg.String(); strings.Contains(out, "sk-1") {
+		t.Errorf("String() leaks an API key:\n%s", out)
+	}
+
+	if _, err := Load(Flags{}, env(map[string]string{"ACCOUNT_STORE": "memory", "ACCOUNT_KEYS": "alice=sk-1,bob=sk-1"})); err == nil || !strings.Contains(err.Error(), "同一个 API Key") {
+		t.Errorf("Load() with a shared key error = %v, want duplicate key problem", err)
+	}
+}
+
+func TestPolicyPatchKeepsCurrentValues(t *testing.T) {
+	cfg := Default()
+	cfg.Pricing.Policy.Composite.MaxStep = 3
+	p, err := cfg.PolicyFor("/mcp:tools/list").Patch([]byte(`{"composite":{"alpha":0.5}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Composite.Alpha != 0.5 || p.Composite.MaxStep != 3 {
+		t.Errorf("patched = %+v, want alpha 0.5 and max_step 3", p.Composite)
+	}
+
+	cfg.SetPolicy("/mcp", p)
+	if r := cfg.route("/mcp"); r.Pricing == nil || r.Pricing.Composite.Alpha != 0.5 {
+		t.Errorf("route /mcp pricing = %+v, want patched policy", r.Pricing)
+	}
+	if got := cfg.PolicyFor("/mcp:tools/list").Composite.Alpha; got != 0.5 {
+		t.Errorf("derived key alpha = %v, want 0.5", got)
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"rajomon-gateway/internal/account"
+	"rajomon-gateway/internal/balancer"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/middleware"
+	"rajomon-gateway/internal/trace"
+)
+
+// 可选配置段的默认值取自各自的包，配置文件里只写了部分字段时其余字段保持默认
+
+func defaultAccount() *AccountConfig {
+	l := account.DefaultLedgerConfig()
+	return &AccountConfig{
+		Store:          "memory",
+		InitialBalance: l.InitialBalance,
+		MaxBalance:     l.MaxBalance,
+		Refill:         RefillConfig{Dist: l.Refill.Dist, Interval: Duration(l.Refill.Interval), Step: l.Refill.Step},
+		FlushInterval:  Duration(account.DefaultFlushInterval),
+	}
+}
+
+func defaultQueue() *QueueConfig {
+	q := middleware.DefaultAdmissionQueueConfig()
+	return &QueueConfig{
+		MaxWait:      Duration(q.MaxWait),
+		MaxDepth:     q.MaxDepth,
+		MaxInFlight:  q.MaxInFlight,
+		PollInterval: Duration(q.PollInterval),
+	}
+}
+
+func defaultUpdateLoop() *UpdateLoopConfig {
+	l := controller.DefaultUpdateLoopConfig()
+	return &UpdateLoopConfig{Interval: Duration(l.Interval), DecayStep: l.DecayStep, Floor: l.Floor, Ceiling: l.Ceiling}
+}
+
+func defaultTrace() *TraceConfig {
+	return &TraceConfig{BodyLimit: trace.DefaultBodyLimit}
+}
+
+func defaultBreaker() *BreakerConfig {
+	b := balancer.DefaultBreakerConfig()
+	return &BreakerConfig{
+		Window:              Duration(b.Window),
+		MinRequests:         b.MinRequests,
+		ErrorRate:           b.ErrorRate,
+		ConsecutiveFailures: b.ConsecutiveFailures,
+		OpenDuration:        Duration(b.OpenDuration),
+		HalfOpenRequests:    b.HalfOpenRequests,
+	}
+}
+
+func defaultRetry() *RetryConfig {
+	r := balancer.DefaultRetryConfig()
+	return &RetryConfig{
+		MaxRetries:  r.MaxRetries,
+		Budget:      balancer.DefaultRetryBudgetRatio,
+		MinRetries:  balancer.DefaultMinRetries,
+		SafeMethods: r.SafeMethods,
+	}
+}
+
+func defaultHedge() *HedgeConfig {
+	h := balancer.DefaultHedgeConfig()
+	return &HedgeConfig{
+		Percentile: h.Percentile,
+		MinDelay:   Duration(h.MinDelay),
+		MaxPrice:   h.MaxPrice,
+	}
+}
+
+func defaultDeadlineAdmission() *DeadlineAdmissionConfig {
+	d := middleware.DefaultDeadlineAdmissionConfig()
+	return &DeadlineAdmissionConfig{Headroom: d.Headroom, ProbeInterval: Duration(d.ProbeInterval)}
+}
+
+func (a *AccountConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*a = *defaultAccount()
+	type plain AccountConfig
+	return unmarshal((*plain)(a))
+}
+
+func (a *AccountConfig) UnmarshalJSON(b []byte) error {
+	*a = *defaultAccount()
+	type plain AccountConfig
+	return strictJSON(b, (*plain)(a))
+}
+
+func (q *QueueConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*q = *defaultQueue()
+	type plain QueueConfig
+	return unmarshal((*plain)(q))
+}
+
+func (q *QueueConfig) UnmarshalJSON(b []byte) error {
+	*q = *defaultQueue()
+	type plain QueueConfig
+	return strictJSON(b, (*plain)(q))
+}
+
+func (l *UpdateLoopConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*l = *defaultUpdateLoop()
+	type plain UpdateLoopConfig
+	return unmarshal((*plain)(l))
+}
+
+func (l *UpdateLoopConfig) UnmarshalJSON(b []byte) error {
+	*l = *defaultUpdateLoop()
+	type plain UpdateLoopConfig
+	return strictJSON(b, (*plain)(l))
+}
+
+func (t *TraceConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*t = *defaultTrace()
+	type plain TraceConfig
+	return unmarshal((*plain)(t))
+}
+
+func (t *TraceConfig) UnmarshalJSON(b []byte) error {
+	*t = *defaultTrace()
+	type plain TraceConfig
+	return strictJSON(b, (*plain)(t))
+}
+
+func (b *BreakerConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*b = *defaultBreaker()
+	type plain BreakerConfig
+	return unmarshal((*plain)(b))
+}
+
+func (b *BreakerConfig) UnmarshalJSON(data []byte) error {
+	*b = *defaultBreaker()
+	type plain BreakerConfig
+	return strictJSON(data, (*plain)(b))
+}
+
+func (r *RetryConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*r = *defaultRetry()
+	type plain RetryConfig
+	return unmarshal((*plain)(r))
+}
+
+func (r *RetryConfig) UnmarshalJSON(b []byte) error {
+	*r = *defaultRetry()
+	type plain RetryConfig
+	return strictJSON(b, (*plain)(r))
+}
+
+func (h *HedgeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*h = *defaultHedge()
+	type plain HedgeConfig
+	return unmarshal((*plain)(h))
+}
+
+func (h *HedgeConfig) UnmarshalJSON(b []byte) error {
+	*h = *defaultHedge()
+	type plain HedgeConfig
+	return strictJSON(b, (*plain)(h))
+}
+
+func (d *DeadlineAdmissionConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*d = *defaultDeadlineAdmission()
+	type plain DeadlineAdmissionConfig
+	return unmarshal((*plain)(d))
+}
+
+func (d *DeadlineAdmissionConfig) UnmarshalJSON(b []byte) error {
+	*d = *defaultDeadlineAdmission()
+	type plain DeadlineAdmissionConfig
+	return strictJSON(b, (*plain)(d))
+}
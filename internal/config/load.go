@@ -0,0 +1,324 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flags 命令行参数，非空的参数覆盖配置文件和环境变量
+type Flags struct {
+	ConfigPath    string
+	Listen        string
+	MetricsListen string
+	AdminListen   string
+	Backends      string
+	Policy        string
+	Check         bool          // 只校验配置并打印生效的配置，不启动
+	WatchInterval time.Duration // 轮询配置文件变化的周期，0 表示只在 SIGHUP 时重新加载
+}
+
+// ParseFlags 解析命令行参数
+func ParseFlags(name string, args []string) (Flags, error) {
+	var f Flags
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&f.ConfigPath, "config", "", "配置文件 (.yaml/.yml/.json)，也可以用 GATEWAY_CONFIG 指定")
+	fs.StringVar(&f.Listen, "listen", "", "业务监听地址 (覆盖 listen)")
+	fs.StringVar(&f.MetricsListen, "metrics-listen", "", "指标监听地址 (覆盖 metrics_listen)")
+	fs.StringVar(&f.AdminListen, "admin-listen", "", "管理接口监听地址 (覆盖 admin_listen)")
+	fs.StringVar(&f.Backends, "backends", "", "默认后端池的后端列表，逗号分隔 (覆盖 BACKEND_HOSTS)")
+	fs.StringVar(&f.Policy, "policy", "", "默认定价策略 (覆盖 pricing.policy.name)")
+	fs.BoolVar(&f.Check, "check", false, "只校验配置并打印生效的配置")
+	fs.DurationVar(&f.WatchInterval, "watch-interval", 2*time.Second, "轮询配置文件变化的周期 (0 表示只响应 SIGHUP)")
+	err := fs.Parse(args)
+	return f, err
+}
+
+// Load 按 默认值 < 配置文件 < 环境变量 < 命令行参数 的顺序组装配置并校验
+// 所有错误一次性报告，方便启动失败时一次改完
+func Load(flags Flags, getenv func(string) string) (*Config, error) {
+	path := flags.File(getenv)
+	cfg := Default()
+	if path != "" {
+		var err error
+		if cfg, err = LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs errorList
+	applyEnv(cfg, getenv, &errs)
+	applyFlags(cfg, flags, &errs)
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// File 生效的配置文件路径：-config 优先，其次 GATEWAY_CONFIG；都没有时为空
+func (f Flags) File(getenv func(string) string) string {
+	if f.ConfigPath != "" {
+		return f.ConfigPath
+	}
+	return getenv("GATEWAY_CONFIG")
+}
+
+// applyEnv 兼容旧版的环境变量配置方式 (docker-compose 里仍然在用)
+func applyEnv(cfg *Config, getenv func(string) string, errs *errorList) {
+	envStr := func(name string, dst *string) {
+		if v := getenv(name); v != "" {
+			*dst = v
+		}
+	}
+	envInt := func(name string, dst *int) {
+		if v := getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs.add(name, "不是整数: %q", v)
+				return
+			}
+			*dst = n
+		}
+	}
+	envDuration := func(name string, dst *Duration) {
+		if v := getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs.add(name, "不是时间长度: %q", v)
+				return
+			}
+			*dst = Duration(d)
+		}
+	}
+
+	// 1. 监听地址
+	envStr("LISTEN_ADDR", &cfg.Listen)
+	envStr("METRICS_ADDR", &cfg.MetricsListen)
+	envStr("ADMIN_ADDR", &cfg.AdminListen)
+
+	// 2. 后端池：BACKEND_HOSTS 作用于默认池，健康检查和负载均衡策略作用于所有池
+	if v := getenv("BACKEND_HOSTS"); v != "" {
+		setDefaultPoolBackends(cfg, v, "BACKEND_HOSTS", errs)
+	}
+	// 每个变量只解析一次 (错误只报一次)，再套用到所有池上
+	var interval Duration
+	unhealthy, healthy, eject, strategy := 0, 0, 0, ""
+	envDuration("HEALTH_CHECK_INTERVAL", &interval)
+	envInt("HEALTH_UNHEALTHY_THRESHOLD", &unhealthy)
+	envInt("HEALTH_HEALTHY_THRESHOLD", &healthy)
+	envInt("EJECT_THRESHOLD", &eject)
+	envStr("LB_STRATEGY", &strategy)
+	for name, pool := range cfg.Pools {
+		if interval > 0 {
+			pool.HealthCheck.Interval = interval
+		}
+		if unhealthy != 0 {
+			pool.HealthCheck.UnhealthyThreshold = unhealthy
+		}
+		if healthy != 0 {
+			pool.HealthCheck.HealthyThreshold = healthy
+		}
+		if eject != 0 {
+			pool.HealthCheck.EjectThreshold = eject
+		}
+		if strategy != "" {
+			pool.Strategy = strategy
+		}
+		cfg.Pools[name] = pool
+	}
+
+	// 3. 路由：按路由覆盖负载均衡策略 ("/mcp=lowest_price,/mcp/chat=p2c")，/mcp 的定价 Key
+	forEachPair(getenv("ROUTE_STRATEGIES"), "ROUTE_STRATEGIES", errs, func(path, strategy string) {
+		if r := cfg.route(path); r != nil {
+			r.Strategy = strategy
+		} else {
+			errs.add("ROUTE_STRATEGIES", "未定义的路由 %q", path)
+		}
+	})
+	if v := getenv("PRICING_KEY"); v != "" {
+		if r := cfg.route("/mcp"); r != nil {
+			r.Key = v
+		} else {
+			errs.add("PRICING_KEY", "没有 /mcp 路由")
+		}
+	}
+
+	// 4. 定价
+	envStr("PRICING_POLICY", &cfg.Pricing.Policy.Name)
+	forEachPair(getenv("PRICING_POLICIES"), "PRICING_POLICIES", errs, func(key, name string) {
+		policy := DefaultPolicyConfig()
+		policy.Name = name
+		cfg.SetPolicy(key, policy)
+	})
+	envStr("PRICE_AGGREGATION", &cfg.Pricing.Aggregation)
+	if getenv("PRICE_UPDATE_INTERVAL") != "" {
+		if cfg.Pricing.UpdateLoop == nil {
+			cfg.Pricing.UpdateLoop = defaultUpdateLoop()
+		}
+		loop := cfg.Pricing.UpdateLoop
+		envDuration("PRICE_UPDATE_INTERVAL", &loop.Interval)
+		envInt("PRICE_DECAY_STEP", &loop.DecayStep)
+		envInt("PRICE_FLOOR", &loop.Floor)
+		envInt("PRICE_CEILING", &loop.Ceiling)
+	}
+
+	// 5. 账户
+	if getenv("ACCOUNT_STORE") != "" {
+		if cfg.Account == nil {
+			cfg.Account = defaultAccount()
+		}
+		a := cfg.Account
+		envStr("ACCOUNT_STORE", &a.Store)
+		initial, max := int(a.InitialBalance), int(a.MaxBalance)
+		envInt("ACCOUNT_INITIAL", &initial)
+		envInt("ACCOUNT_MAX", &max)
+		a.InitialBalance, a.MaxBalance = int64(initial), int64(max)
+		envStr("ACCOUNT_REFILL", &a.Refill.Dist)
+		envDuration("ACCOUNT_REFILL_INTERVAL", &a.Refill.Interval)
+		step := int(a.Refill.Step)
+		envInt("ACCOUNT_REFILL_STEP", &step)
+		a.Refill.Step = int64(step)
+		envDuration("ACCOUNT_FLUSH_INTERVAL", &a.FlushInterval)
+	}
+	// API Key 不适合写进配置文件：ACCOUNT_KEYS=alice=sk-1,bob=sk-2
+	if cfg.Account != nil {
+		forEachPair(getenv("ACCOUNT_KEYS"), "ACCOUNT_KEYS", errs, func(client, key string) {
+			if cfg.Account.Keys == nil {
+				cfg.Account.Keys = make(map[string]string)
+			}
+			cfg.Account.Keys[client] = key
+		})
+	}
+
+	// 6. 准入等待队列
+	if getenv("ADMISSION_QUEUE_WAIT") != "" {
+		if cfg.AdmissionQueue == nil {
+			cfg.AdmissionQueue = defaultQueue()
+		}
+		q := cfg.AdmissionQueue
+		envDuration("ADMISSION_QUEUE_WAIT", &q.MaxWait)
+		envInt("ADMISSION_QUEUE_DEPTH", &q.MaxDepth)
+		envInt("ADMISSION_MAX_INFLIGHT", &q.MaxInFlight)
+	}
+
+	// 7. 请求追踪
+	if getenv("TRACE_FILE") != "" {
+		if cfg.Trace == nil {
+			cfg.Trace = defaultTrace()
+		}
+		envStr("TRACE_FILE", &cfg.Trace.File)
+		envInt("TRACE_BODY_LIMIT", &cfg.Trace.BodyLimit)
+	}
+
+	// 8. 管理接口：Token 不适合写进配置文件，ADMIN_TOKEN 以操作人 "admin" 的身份生效
+	if token := getenv("ADMIN_TOKEN"); token != "" {
+		if cfg.Admin == nil {
+			cfg.Admin = &AdminConfig{}
+		}
+		if cfg.Admin.Tokens == nil {
+			cfg.Admin.Tokens = make(map[string]string)
+		}
+		cfg.Admin.Tokens["admin"] = token
+	}
+	if getenv("ADMIN_AUDIT_FILE") != "" {
+		if cfg.Admin == nil {
+			cfg.Admin = &AdminConfig{}
+		}
+		envStr("ADMIN_AUDIT_FILE", &cfg.Admin.AuditFile)
+	}
+}
+
+func applyFlags(cfg *Config, f Flags, errs *errorList) {
+	if f.Listen != "" {
+		cfg.Listen = f.Listen
+	}
+	if f.MetricsListen != "" {
+		cfg.MetricsListen = f.MetricsListen
+	}
+	if f.AdminListen != "" {
+		cfg.AdminListen = f.AdminListen
+	}
+	if f.Backends != "" {
+		setDefaultPoolBackends(cfg, f.Backends, "-backends", errs)
+	}
+	if f.Policy != "" {
+		cfg.Pricing.Policy.Name = f.Policy
+	}
+}
+
+// setDefaultPoolBackends 覆盖默认池 (名为 default，或者唯一的那个池) 的后端列表
+func setDefaultPoolBackends(cfg *Config, list, source string, errs *errorList) {
+	name := "default"
+	if _, ok := cfg.Pools[name]; !ok {
+		if len(cfg.Pools) != 1 {
+			errs.add(source, "有多个后端池且没有名为 default 的池，不知道覆盖哪一个")
+			return
+		}
+		for n := range cfg.Pools {
+			name = n
+		}
+	}
+	pool := cfg.Pools[name]
+	pool.Backends = nil
+	for _, b := range strings.Split(list, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			pool.Backends = append(pool.Backends, b)
+		}
+	}
+	cfg.Pools[name] = pool
+}
+
+// forEachPair 解析 "a=b,c=d" 格式的环境变量
+func forEachPair(v, name string, errs *errorList, fn func(k, v string)) {
+	if v == "" {
+		return
+	}
+	for _, item := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			errs.add(name, "格式错误: %q (应为 key=value)", item)
+			continue
+		}
+		fn(k, val)
+	}
+}
+
+func (c *Config) route(path string) *RouteConfig {
+	for i := range c.Routes {
+		if c.Routes[i].Path == path {
+			return &c.Routes[i]
+		}
+	}
+	return nil
+}
+
+// String 以 YAML 形式打印生效的配置 (-check 时使用)，管理接口的 Token 和账户的 API Key 打码
+func (c *Config) String() string {
+	redacted := *c
+	if c.Admin != nil {
+		admin := *c.Admin
+		admin.Tokens = make(map[string]string, len(c.Admin.Tokens))
+		for actor := range c.Admin.Tokens {
+			admin.Tokens[actor] = "******"
+		}
+		redacted.Admin = &admin
+	}
+	if c.Account != nil {
+		acct := *c.Account
+		acct.Keys = make(map[string]string, len(c.Account.Keys))
+		for client := range c.Account.Keys {
+			acct.Keys[client] = "******"
+		}
+		redacted.Account = &acct
+	}
+	out, err := yamlMarshal(&redacted)
+	if err != nil {
+		return fmt.Sprintf("%+v", redacted)
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"rajomon-gateway/internal/controller"
+	"time"
+)
+
+// PolicyConfig 定价策略及其参数
+// 只有 Name 对应的那组参数生效；没写的参数使用该策略的内置默认值
+type PolicyConfig struct {
+	Name      string          `yaml:"name" json:"name"` // composite (默认) / aimd / queuing
+	Composite CompositeParams `yaml:"composite" json:"composite"`
+	AIMD      AIMDParams      `yaml:"aimd" json:"aimd"`
+	Queuing   QueuingParams   `yaml:"queuing" json:"queuing"`
+}
+
+// CompositeParams 综合成本策略参数 (见 controller.CompositeCostConfig)
+type CompositeParams struct {
+	InitialPrice  int     `yaml:"initial_price" json:"initial_price"`
+	Alpha         float64 `yaml:"alpha" json:"alpha"`
+	LatencyWeight float64 `yaml:"latency_weight" json:"latency_weight"`
+	TokenWeight   float64 `yaml:"token_weight" json:"token_weight"`
+	BaseThreshold float64 `yaml:"base_threshold" json:"base_threshold"`
+	PriceStepUnit float64 `yaml:"price_step_unit" json:"price_step_unit"`
+	MaxStep       int     `yaml:"max_step" json:"max_step"`
+}
+
+// AIMDParams AIMD 策略参数 (见 controller.AIMDConfig)
+type AIMDParams struct {
+	InitialPrice    int     `yaml:"initial_price" json:"initial_price"`
+	Alpha           float64 `yaml:"alpha" json:"alpha"`
+	TargetLatencyMs float64 `yaml:"target_latency_ms" json:"target_latency_ms"`
+	IncreaseFactor  float64 `yaml:"increase_factor" json:"increase_factor"`
+	DecreaseStep    int     `yaml:"decrease_step" json:"decrease_step"`
+	MinPrice        int     `yaml:"min_price" json:"min_price"`
+}
+
+// QueuingParams 排队延迟策略参数 (见 controller.QueuingDelayConfig)
+type QueuingParams struct {
+	InitialPrice int      `yaml:"initial_price" json:"initial_price"`
+	Alpha        float64  `yaml:"alpha" json:"alpha"`
+	SLO          Duration `yaml:"slo" json:"slo"`
+	Percentile   float64  `yaml:"percentile" json:"percentile"`
+	Interval     Duration `yaml:"interval" json:"interval"`
+	PriceStep    int      `yaml:"price_step" json:"price_step"`
+	MaxStep      int      `yaml:"max_step" json:"max_step"`
+	MinPrice     int      `yaml:"min_price" json:"min_price"`
+}
+
+// DefaultPolicyConfig 综合成本策略 + 三种策略的内置默认参数
+func DefaultPolicyConfig() PolicyConfig {
+	c := controller.DefaultCompositeCostConfig()
+	a := controller.DefaultAIMDConfig()
+	q := controller.DefaultQueuingDelayConfig()
+	return PolicyConfig{
+		Name: "composite",
+		Composite: CompositeParams{
+			InitialPrice:  c.InitialPrice,
+			Alpha:         c.Alpha,
+			LatencyWeight: c.LatencyWeight,
+			TokenWeight:   c.TokenWeight,
+			BaseThreshold: c.BaseThreshold,
+			PriceStepUnit: c.PriceStepUnit,
+			MaxStep:       c.MaxStep,
+		},
+		AIMD: AIMDParams{
+			InitialPrice:    a.InitialPrice,
+			Alpha:           a.Alpha,
+			TargetLatencyMs: a.TargetLatencyMs,
+			IncreaseFactor:  a.IncreaseFactor,
+			DecreaseStep:    a.DecreaseStep,
+			MinPrice:        a.MinPrice,
+		},
+		Queuing: QueuingParams{
+			InitialPrice: q.InitialPrice,
+			Alpha:        q.Alpha,
+			SLO:          Duration(q.SLO),
+			Percentile:   q.Percentile,
+			Interval:     Duration(q.Interval),
+			PriceStep:    q.PriceStep,
+			MaxStep:      q.MaxStep,
+			MinPrice:     q.MinPrice,
+		},
+	}
+}
+
+// UnmarshalYAML 先填默认值再覆盖，没写的参数保持内置默认
+func (p *PolicyConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*p = DefaultPolicyConfig()
+	type plain PolicyConfig
+	return unmarshal((*plain)(p))
+}
+
+func (p *PolicyConfig) UnmarshalJSON(b []byte) error {
+	*p = DefaultPolicyConfig()
+	type plain PolicyConfig
+	return strictJSON(b, (*plain)(p))
+}
+
+// Build 创建定价策略实例
+func (p PolicyConfig) Build() (controller.PricingPolicy, error) {
+	switch p.Name {
+	case "", "composite":
+		c := p.Composite
+		return controller.NewCompositeCostPolicy(controller.CompositeCostConfig{
+			InitialPrice:  c.InitialPrice,
+			Alpha:         c.Alpha,
+			LatencyWeight: c.LatencyWeight,
+			TokenWeight:   c.TokenWeight,
+			BaseThreshold: c.BaseThreshold,
+			PriceStepUnit: c.PriceStepUnit,
+			MaxStep:       c.MaxStep,
+		}), nil
+	case "aimd":
+		a := p.AIMD
+		return controller.NewAIMDPolicy(controller.AIMDConfig{
+			InitialPrice:    a.InitialPrice,
+			Alpha:           a.Alpha,
+			TargetLatencyMs: a.TargetLatencyMs,
+			IncreaseFactor:  a.IncreaseFactor,
+			DecreaseStep:    a.DecreaseStep,
+			MinPrice:        a.MinPrice,
+		}), nil
+	case "queuing":
+		q := p.Queuing
+		return controller.NewQueuingDelayPolicy(controller.QueuingDelayConfig{
+			InitialPrice: q.InitialPrice,
+			Alpha:        q.Alpha,
+			SLO:          time.Duration(q.SLO),
+			Percentile:   q.Percentile,
+			Interval:     time.Duration(q.Interval),
+			PriceStep:    q.PriceStep,
+			MaxStep:      q.MaxStep,
+			MinPrice:     q.MinPrice,
+		}), nil
+	default:
+		return nil, fmt.Errorf("未知的定价策略: %q (composite / aimd / queuing)", p.Name)
+	}
+}
+
+// validate 检查当前策略的参数
+func (p PolicyConfig) validate(path string, errs *errorList) {
+	switch p.Name {
+	case "", "composite":
+		c := p.Composite
+		if c.Alpha <= 0 || c.Alpha > 1 {
+			errs.add(path+".composite.alpha", "必须在 (0, 1] 之间，当前为 %v", c.Alpha)
+		}
+		if c.BaseThreshold <= 0 {
+			errs.add(path+".composite.base_threshold", "必须大于 0，当前为 %v", c.BaseThreshold)
+		}
+		if c.PriceStepUnit <= 0 {
+			errs.add(path+".composite.price_step_unit", "必须大于 0，当前为 %v", c.PriceStepUnit)
+		}
+		if c.InitialPrice < 0 || c.MaxStep < 0 {
+			errs.add(path+".composite", "initial_price / max_step 不能为负数")
+		}
+	case "aimd":
+		a := p.AIMD
+		if a.Alpha <= 0 || a.Alpha > 1 {
+			errs.add(path+".aimd.alpha", "必须在 (0, 1] 之间，当前为 %v", a.Alpha)
+		}
+		if a.TargetLatencyMs <= 0 {
+			errs.add(path+".aimd.target_latency_ms", "必须大于 0，当前为 %v", a.TargetLatencyMs)
+		}
+		if a.IncreaseFactor <= 1 {
+			errs.add(path+".aimd.increase_factor", "必须大于 1，当前为 %v", a.IncreaseFactor)
+		}
+	case "queuing":
+		q := p.Queuing
+		if q.SLO <= 0 || q.Interval <= 0 {
+			errs.add(path+".queuing", "slo 和 interval 必须大于 0")
+		}
+		if q.Percentile <= 0 || q.Percentile > 1 {
+			errs.add(path+".queuing.percentile", "必须在 (0, 1] 之间，当前为 %v", q.Percentile)
+		}
+	default:
+		errs.add(path+".name", "未知的定价策略 %q (composite / aimd / queuing)", p.Name)
+	}
+}
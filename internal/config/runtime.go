@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Clone 深拷贝配置：运行时调参在副本上修改，校验通过后再整体替换
+func (c *Config) Clone() *Config {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic("config: 序列化失败: " + err.Error())
+	}
+	clone := new(Config)
+	if err := json.Unmarshal(b, clone); err != nil {
+		panic("config: 反序列化失败: " + err.Error())
+	}
+	return clone
+}
+
+// PolicyFor 返回 Key 使用的定价策略配置 ("" 为默认策略)
+// 查找顺序与控制器一致: pricing.keys > 路由 > 派生 Key 所属的路由 > 默认策略
+func (c *Config) PolicyFor(key string) PolicyConfig {
+	if key == "" {
+		return c.Pricing.Policy
+	}
+	if p, ok := c.Pricing.Keys[key]; ok {
+		return p
+	}
+	if r := c.route(key); r != nil && r.Pricing != nil {
+		return *r.Pricing
+	}
+	if route, _, ok := strings.Cut(key, ":"); ok {
+		if p, ok := c.Pricing.Keys[route]; ok {
+			return p
+		}
+		if r := c.route(route); r != nil && r.Pricing != nil {
+			return *r.Pricing
+		}
+	}
+	return c.Pricing.Policy
+}
+
+// SetPolicy 为 Key 设置定价策略：key 为空时替换默认策略，是路由路径时挂在路由上，否则写入 pricing.keys
+func (c *Config) SetPolicy(key string, p PolicyConfig) {
+	if key == "" {
+		c.Pricing.Policy = p
+		return
+	}
+	if _, ok := c.Pricing.Keys[key]; !ok {
+		if r := c.route(key); r != nil {
+			r.Pricing = &p
+			return
+		}
+	}
+	if c.Pricing.Keys == nil {
+		c.Pricing.Keys = make(map[string]PolicyConfig)
+	}
+	c.Pricing.Keys[key] = p
+}
+
+// Patch 把 JSON 片段叠加到当前参数上，没写的字段保持当前值 (而不是内置默认值)
+func (p PolicyConfig) Patch(b []byte) (PolicyConfig, error) {
+	type plain PolicyConfig
+	err := strictJSON(b, (*plain)(&p))
+	return p, err
+}
+
+// PatchUpdateLoop 同 Patch；当前没有开启周期调价时以内置默认值为基础
+func (c *Config) PatchUpdateLoop(b []byte) error {
+	loop := defaultUpdateLoop()
+	if c.Pricing.UpdateLoop != nil {
+		*loop = *c.Pricing.UpdateLoop
+	}
+	type plain UpdateLoopConfig
+	if err := strictJSON(b, (*plain)(loop)); err != nil {
+		return err
+	}
+	c.Pricing.UpdateLoop = loop
+	return nil
+}
+
+// strictJSON 解码 JSON 并拒绝未知字段，与 YAML 的 UnmarshalStrict 行为一致
+func strictJSON(b []byte, dst any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(dst)
+}
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"rajomon-gateway/internal/balancer"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/middleware"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// ValidationError 配置校验失败，列出所有问题
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("配置无效 (%d 处错误):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// errorList 收集校验错误，每条都带上出错字段的路径
+type errorList []string
+
+func (e *errorList) add(field, format string, args ...any) {
+	*e = append(*e, field+": "+fmt.Sprintf(format, args...))
+}
+
+func (e errorList) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: e}
+}
+
+// builtinHandlers 不需要后端池的内置处理器
+var builtinHandlers = map[string]bool{"context": true}
+
+// Validate 校验整份配置，返回的错误包含所有问题而不是第一个
+func (c *Config) Validate() error {
+	var errs errorList
+
+	if c.Listen == "" {
+		errs.add("listen", "不能为空")
+	}
+	if c.MetricsListen != "" && c.MetricsListen == c.Listen {
+		errs.add("metrics_listen", "与 listen 相同；想挂在业务端口上请留空")
+	}
+	if c.AdminListen != "" && (c.AdminListen == c.Listen || c.AdminListen == c.MetricsListen) {
+		errs.add("admin_listen", "管理接口必须使用独立端口")
+	}
+
+	// 1. 后端池
+	if len(c.Pools) == 0 {
+		errs.add("pools", "至少需要一个后端池")
+	}
+	names := make([]string, 0, len(c.Pools))
+	for name := range c.Pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pool := c.Pools[name]
+		path := "pools." + name
+		if len(pool.Backends) == 0 {
+			errs.add(path+".backends", "至少需要一个后端")
+		}
+		for i, b := range pool.Backends {
+			u, err := url.Parse(b)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs.add(fmt.Sprintf("%s.backends[%d]", path, i), "不是合法的 http(s) 地址: %q", b)
+			}
+		}
+		if _, err := balancer.StrategyByName(pool.Strategy); err != nil {
+			errs.add(path+".strategy", "%v", err)
+		}
+		hc := pool.HealthCheck
+		if hc.Interval <= 0 || hc.Timeout <= 0 {
+			errs.add(path+".health_check", "interval 和 timeout 必须大于 0")
+		}
+		if hc.HealthyThreshold <= 0 || hc.UnhealthyThreshold <= 0 {
+			errs.add(path+".health_check", "healthy_threshold 和 unhealthy_threshold 必须大于 0")
+		}
+		if br := pool.Breaker; br != nil {
+			if br.Window <= 0 || br.OpenDuration <= 0 {
+				errs.add(path+".breaker", "window 和 open_duration 必须大于 0")
+			}
+			if br.ErrorRate < 0 || br.ErrorRate > 1 {
+				errs.add(path+".breaker.error_rate", "必须在 [0, 1] 之间: %v", br.ErrorRate)
+			}
+			if br.ErrorRate == 0 && br.ConsecutiveFailures <= 0 {
+				errs.add(path+".breaker", "error_rate 和 consecutive_failures 至少配置一个")
+			}
+			if br.MinRequests < 0 || br.ConsecutiveFailures < 0 {
+				errs.add(path+".breaker", "min_requests 和 consecutive_failures 不能为负数")
+			}
+			if br.HalfOpenRequests <= 0 {
+				errs.add(path+".breaker.half_open_requests", "必须大于 0")
+			}
+		}
+	}
+
+	// 2. 路由
+	if len(c.Routes) == 0 {
+		errs.add("routes", "至少需要一条路由")
+	}
+	seen := make(map[string]bool)
+	for i, r := range c.Routes {
+		path := fmt.Sprintf("routes[%d]", i)
+		if !strings.HasPrefix(r.Path, "/") {
+			errs.add(path+".path", "必须以 / 开头: %q", r.Path)
+		} else if seen[r.Path] {
+			errs.add(path+".path", "重复的路由 %q", r.Path)
+		}
+		seen[r.Path] = true
+
+		switch {
+		case r.Pool != "" && r.Handler != "":
+			errs.add(path, "pool 和 handler 只能二选一")
+		case r.Handler != "":
+			if !builtinHandlers[r.Handler] {
+				errs.add(path+".handler", "未知的内置处理器 %q (context)", r.Handler)
+			}
+		case r.Pool == "":
+			errs.add(path, "需要 pool 或 handler")
+		default:
+			if _, ok := c.Pools[r.Pool]; !ok {
+				errs.add(path+".pool", "未定义的后端池 %q", r.Pool)
+			}
+		}
+		if r.Strategy != "" {
+			if r.Handler != "" {
+				errs.add(path+".strategy", "内置处理器不经过负载均衡")
+			} else if _, err := balancer.StrategyByName(r.Strategy); err != nil {
+				errs.add(path+".strategy", "%v", err)
+			}
+		}
+		if h := r.Hedge; h != nil {
+			if r.Handler != "" {
+				errs.add(path+".hedge", "内置处理器不经过负载均衡，不能对冲")
+			}
+			if h.Percentile <= 0 || h.Percentile >= 1 {
+				errs.add(path+".hedge.percentile", "必须在 (0, 1) 之间: %v", h.Percentile)
+			}
+			if h.MinDelay < 0 {
+				errs.add(path+".hedge.min_delay", "不能为负数")
+			}
+			if h.MaxPrice < 0 {
+				errs.add(path+".hedge.max_price", "不能为负数")
+			}
+		}
+		if _, err := middleware.KeyFuncByName(r.Key); err != nil {
+			errs.add(path+".key", "%v", err)
+		}
+		for _, key := range r.AllowKeys {
+			if r.Key == "" || r.Key == "path" {
+				errs.add(path+".allow_keys", "按路径定价时没有派生 Key")
+				break
+			}
+			if !strings.HasPrefix(key, r.Path+":") {
+				errs.add(path+".allow_keys", "%q 不是本路由的派生 Key (应为 %s:<子键>)", key, r.Path)
+			}
+		}
+		if r.Pricing != nil {
+			r.Pricing.validate(path+".pricing", &errs)
+		}
+	}
+
+	// 3. 定价
+	c.Pricing.Policy.validate("pricing.policy", &errs)
+	for key, p := range c.Pricing.Keys {
+		p.validate(fmt.Sprintf("pricing.keys[%q]", key), &errs)
+	}
+	switch c.Pricing.Aggregation {
+	case "", controller.AggregateOwn, controller.AggregateMax, controller.AggregateSum:
+	default:
+		errs.add("pricing.aggregation", "未知的聚合方式 %q (own / max / sum)", c.Pricing.Aggregation)
+	}
+	if l := c.Pricing.UpdateLoop; l != nil {
+		if l.Interval <= 0 {
+			errs.add("pricing.update_loop.interval", "必须大于 0")
+		}
+		if l.Ceiling > 0 && l.Ceiling < l.Floor {
+			errs.add("pricing.update_loop", "ceiling (%d) 小于 floor (%d)", l.Ceiling, l.Floor)
+		}
+	}
+
+	// 4. 账户 / 队列 / 追踪
+	if a := c.Account; a != nil {
+		if path, ok := strings.CutPrefix(a.Store, "file:"); ok {
+			if path == "" {
+				errs.add("account.store", "file: 后面需要文件路径")
+			}
+		} else if a.Store != "memory" {
+			errs.add("account.store", "未知的存储类型 %q (memory / file:<path>)", a.Store)
+		}
+		if a.MaxBalance < a.InitialBalance {
+			errs.add("account", "max_balance (%d) 小于 initial_balance (%d)", a.MaxBalance, a.InitialBalance)
+		}
+		switch a.Refill.Dist {
+		case "", "poisson", "fixed", "uniform":
+		default:
+			errs.add("account.refill.dist", "未知的分布 %q (poisson / fixed / uniform)", a.Refill.Dist)
+		}
+		if a.Refill.Dist != "" && a.Refill.Interval <= 0 {
+			errs.add("account.refill.interval", "必须大于 0")
+		}
+		if a.FlushInterval < 0 {
+			errs.add("account.flush_interval", "不能为负数")
+		}
+		// 账户必须绑定网关能验证的身份，否则谁都可以冒用别人的余额
+		if len(a.Keys) == 0 {
+			errs.add("account.keys", "启用账户时至少需要一个客户端的 API Key (也可以用 ACCOUNT_KEYS)")
+		}
+		seenKeys := make(map[string]string, len(a.Keys))
+		for _, client := range sortedKeys(a.Keys) {
+			key := a.Keys[client]
+			if key == "" {
+				errs.add(fmt.Sprintf("account.keys[%q]", client), "不能为空")
+			} else if other, dup := seenKeys[key]; dup {
+				errs.add(fmt.Sprintf("account.keys[%q]", client), "与 %q 使用了同一个 API Key", other)
+			}
+			seenKeys[key] = client
+		}
+	}
+	if q := c.AdmissionQueue; q != nil {
+		if q.MaxWait <= 0 || q.PollInterval <= 0 {
+			errs.add("admission_queue", "max_wait 和 poll_interval 必须大于 0")
+		}
+		if q.MaxDepth <= 0 {
+			errs.add("admission_queue.max_depth", "必须大于 0")
+		}
+	}
+	if t := c.Trace; t != nil && t.File == "" {
+		errs.add("trace.file", "不能为空")
+	}
+	if r := c.Retry; r != nil {
+		if r.MaxRetries <= 0 {
+			errs.add("retry.max_retries", "必须大于 0")
+		}
+		if r.Budget < 0 || r.Budget > 1 {
+			errs.add("retry.budget", "必须在 [0, 1] 之间: %v", r.Budget)
+		}
+		if r.MinRetries < 0 {
+			errs.add("retry.min_retries", "不能为负数")
+		}
+		for i, m := range r.SafeMethods {
+			if m == "" {
+				errs.add(fmt.Sprintf("retry.safe_methods[%d]", i), "不能为空")
+			}
+		}
+	}
+	if d := c.DeadlineAdmission; d != nil {
+		if d.Headroom <= 0 {
+			errs.add("deadline_admission.headroom", "必须大于 0")
+		}
+		if d.ProbeInterval < 0 {
+			errs.add("deadline_admission.probe_interval", "不能为负数")
+		}
+	}
+
+	// 5. 管理接口：能改价格的接口不允许裸奔
+	if c.AdminListen != "" && (c.Admin == nil || len(c.Admin.Tokens) == 0) {
+		errs.add("admin.tokens", "开启管理接口 (admin_listen) 时至少需要一个 Token (也可以用 ADMIN_TOKEN)")
+	}
+	if c.Admin != nil {
+		for actor, token := range c.Admin.Tokens {
+			if token == "" {
+				errs.add(fmt.Sprintf("admin.tokens[%q]", actor), "不能为空")
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+func yamlMarshal(v any) (string, error) {
+	out, err := yaml.Marshal(v)
+	return string(out), err
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
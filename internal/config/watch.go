@@ -0,0 +1,37 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Watch 按周期轮询配置文件，修改时间或大小变化时调用 onChange，直到 ctx 被取消
+// 用轮询而不是 inotify：挂载进容器的 ConfigMap 是通过替换符号链接更新的，轮询对这种情况同样有效
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	if path == "" || interval <= 0 {
+		return
+	}
+	last, _ := os.Stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			// 文件暂时不存在 (编辑器先删后写)，等下一个周期
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+		onChange()
+	}
+}
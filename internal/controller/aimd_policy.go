@@ -0,0 +1,71 @@
+package controller
+
+import "math"
+
+// AIMDConfig 加性减/乘性增策略的参数
+type AIMDConfig struct {
+	InitialPrice    int     // 初始价格
+	Alpha           float64 // EWMA 平滑因子
+	TargetLatencyMs float64 // 目标延迟 (ms)，EWMA 延迟超过它就涨价
+	IncreaseFactor  float64 // 乘性涨价系数 (例如 1.5 表示一次涨 50%)
+	DecreaseStep    int     // 加性降价步长
+	MinPrice        int     // 降价下限
+}
+
+// DefaultAIMDConfig 默认参数：目标延迟 500ms，超标涨 50%，达标每次降 1
+func DefaultAIMDConfig() AIMDConfig {
+	return AIMDConfig{
+		InitialPrice:    5,
+		Alpha:           0.2,
+		TargetLatencyMs: 500,
+		IncreaseFactor:  1.5,
+		DecreaseStep:    1,
+		MinPrice:        1,
+	}
+}
+
+// AIMDPolicy 只看延迟的 AIMD 定价 (类似 TCP 拥塞控制)
+// 过载时价格指数级上涨以尽快止血，恢复后线性回落，不考虑 Token 消耗
+type AIMDPolicy struct {
+	cfg AIMDConfig
+}
+
+func NewAIMDPolicy(cfg AIMDConfig) *AIMDPolicy {
+	return &AIMDPolicy{cfg: cfg}
+}
+
+func (p *AIMDPolicy) Name() string { return "aimd" }
+
+func (p *AIMDPolicy) InitialPrice() int { return p.cfg.InitialPrice }
+
+// Config 返回当前参数 (只读副本)
+func (p *AIMDPolicy) Config() AIMDConfig { return p.cfg }
+
+func (p *AIMDPolicy) Observe(state *KeyState, obs Observation) {
+	state.EWMALatency = ewma(p.cfg.Alpha, state.EWMALatency, float64(obs.Latency.Milliseconds()))
+	state.EWMATokens = ewma(p.cfg.Alpha, state.EWMATokens, float64(obs.Tokens))
+	state.EWMARetries = retryEWMA(p.cfg.Alpha, state.EWMARetries, obs.Retries)
+	// 成本 = 延迟相对目标的倍数 (1.0 表示刚好达标)，重试按比例放大
+	state.Cost = state.EWMALatency / p.cfg.TargetLatencyMs * (1 + state.EWMARetries)
+}
+
+func (p *AIMDPolicy) Adjust(key string, state *KeyState) int {
+	price := state.Price
+	if state.Cost > 1 {
+		next := int(math.Ceil(float64(price) * p.cfg.IncreaseFactor))
+		if next <= price {
+			next = price + 1
+		}
+		logf("📈 [Controller][%s] 延迟超标(%.0fms > %.0fms, 重试 %.2f) -> 乘性涨价 %d -> %d\n",
+			key, state.EWMALatency, p.cfg.TargetLatencyMs, state.EWMARetries, price, next)
+		return next
+	}
+	if price > p.cfg.MinPrice {
+		price -= p.cfg.DecreaseStep
+		if price < p.cfg.MinPrice {
+			price = p.cfg.MinPrice
+		}
+		logf("📉 [Controller][%s] 延迟达标(%.0fms) -> 降价至 %d\n", key, state.EWMALatency, price)
+	}
+	return price
+}
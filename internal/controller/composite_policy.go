@@ -0,0 +1,87 @@
+package controller
+
+import "math"
+
+// CompositeCostConfig 综合成本策略的参数
+type CompositeCostConfig struct {
+	InitialPrice  int     // 初始价格
+	Alpha         float64 // 平滑因子
+	LatencyWeight float64 // 延迟在定价中的权重（比如0.5）
+	TokenWeight   float64 // Token 消耗在定价中的权重（比如0.5）
+	BaseThreshold float64 // 综合成本阈值
+
+	// 价格敏感度：每超出阈值多少分，价格 +1
+	// 例如：阈值 200，敏感度 50。如果 Cost=350 `(超150)，则价格涨 int(150/50) = 3`
+	PriceStepUnit float64
+	MaxStep       int // 单次最大涨幅，防止震荡
+}
+
+// DefaultCompositeCostConfig 网关默认使用的参数
+func DefaultCompositeCostConfig() CompositeCostConfig {
+	return CompositeCostConfig{
+		InitialPrice:  5,    // 默认初始价格
+		Alpha:         0.2,  // 权重：新数据占 20%，历史数据占 80%
+		LatencyWeight: 0.5,  // 延迟权重 50%
+		TokenWeight:   0.5,  // Token 权重 50%
+		BaseThreshold: 200,  // 综合分超过 200 就涨价
+		PriceStepUnit: 50.0, // 灵敏度：每超 50 分涨 1 块钱
+		MaxStep:       10,
+	}
+}
+
+// CompositeCostPolicy 综合成本定价 (EWMA 延迟 + EWMA Token 加权)
+// 超过阈值时按比例涨价 (有上限)，回落到阈值一半以下时线性降价
+type CompositeCostPolicy struct {
+	cfg CompositeCostConfig
+}
+
+func NewCompositeCostPolicy(cfg CompositeCostConfig) *CompositeCostPolicy {
+	return &CompositeCostPolicy{cfg: cfg}
+}
+
+func (p *CompositeCostPolicy) Name() string { return "composite" }
+
+func (p *CompositeCostPolicy) InitialPrice() int { return p.cfg.InitialPrice }
+
+// Config 返回当前参数 (只读副本)
+func (p *CompositeCostPolicy) Config() CompositeCostConfig { return p.cfg }
+
+func (p *CompositeCostPolicy) Observe(state *KeyState, obs Observation) {
+	// 1. EWMA 更新 (针对特定 Key 更新对应的平均值)
+	state.EWMALatency = ewma(p.cfg.Alpha, state.EWMALatency, float64(obs.Latency.Milliseconds()))
+	state.EWMATokens = ewma(p.cfg.Alpha, state.EWMATokens, float64(obs.Tokens))
+	state.EWMARetries = retryEWMA(p.cfg.Alpha, state.EWMARetries, obs.Retries)
+
+	// 2. 计算综合成本：每次重试都让后端多做一份工作
+	state.Cost = ((p.cfg.LatencyWeight * state.EWMALatency) + (p.cfg.TokenWeight * state.EWMATokens)) * (1 + state.EWMARetries)
+}
+
+func (p *CompositeCostPolicy) Adjust(key string, state *KeyState) int {
+	compositeCost := state.Cost
+	price := state.Price
+
+	if compositeCost > p.cfg.BaseThreshold {
+		// 计算超出的部分
+		excess := compositeCost - p.cfg.BaseThreshold
+		// 计算涨价步长：步长 = (超出量 / 单位量) + 基础步长
+		// 必须保证至少涨 1 块
+		step := int(math.Floor(excess / p.cfg.PriceStepUnit))
+		if step < 1 {
+			step = 1
+		}
+
+		// 安全限制：防止单次涨幅过大导致震荡
+		if p.cfg.MaxStep > 0 && step > p.cfg.MaxStep {
+			step = p.cfg.MaxStep
+		}
+		price += step
+		logf("📈 [Controller][%s] 成本过高(Cost:%.0f, Excess:%.0f) -> 猛涨 %d (现价:%d)\n",
+			key, compositeCost, excess, step, price)
+	} else if compositeCost < p.cfg.BaseThreshold/2 && price > 1 {
+		// 降价逻辑通常保持平缓（线性回落），避免系统震荡
+		// 也可以按比例降价，但为了系统稳定性，推荐线性降价
+		price--
+		logf("📉 [Controller][%s] 成本回落(Cost:%.0f) -> 降价至 %d\n", key, compositeCost, price)
+	}
+	return price
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// keyContextKey 请求上下文中保存定价 Key
+type keyContextKey struct{}
+
+// WithKey 把定价 Key 放进请求上下文，供下游组件 (如负载均衡器) 关联价格
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// KeyFromContext 取出请求的定价 Key
+func KeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(string)
+	return key, ok
+}
+
+// retriesContextKey 请求上下文中的重试计数器
+type retriesContextKey struct{}
+
+// WithRetryCounter 在请求上下文中挂一个重试计数器：负载均衡器每换一个后端重试就加 1，
+// 请求结束后由中间件连同延迟一起计入该 Key 的成本
+func WithRetryCounter(ctx context.Context) (context.Context, *atomic.Int32) {
+	n := new(atomic.Int32)
+	return context.WithValue(ctx, retriesContextKey{}, n), n
+}
+
+// CountRetry 记录一次重试 (上下文中没有计数器时忽略)
+func CountRetry(ctx context.Context) {
+	if n, ok := ctx.Value(retriesContextKey{}).(*atomic.Int32); ok {
+		n.Add(1)
+	}
+}
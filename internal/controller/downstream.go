@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"fmt"
+	"rajomon-gateway/internal/metrics"
+)
+
+// 价格聚合方式：服务的对外价格 = f(自身价格, 下游价格)
+const (
+	AggregateOwn = "own" // 只看自身价格 (忽略下游)
+	AggregateMax = "max" // max(自身, 下游)：任何一跳过载都会体现在入口价格上
+	AggregateSum = "sum" // 自身 + 下游：整条调用链的总成本
+)
+
+// SetAggregation 设置价格聚合方式
+func (c *RajomonController) SetAggregation(mode string) error {
+	switch mode {
+	case AggregateOwn, AggregateMax, AggregateSum:
+	default:
+		return fmt.Errorf("未知的价格聚合方式: %q", mode)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.aggregation = mode
+	return nil
+}
+
+// ObserveDownstreamPrice 记录下游后端在响应中广播的价格 (Price 头)
+func (c *RajomonController) ObserveDownstreamPrice(key, backend string, price int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table, ok := c.downstream[key]
+	if !ok {
+		table = make(map[string]int)
+		c.downstream[key] = table
+	}
+	if old, ok := table[backend]; ok && old == price {
+		return
+	}
+	table[backend] = price
+	metrics.DownstreamPrice.WithLabelValues(key, backend).Set(float64(price))
+}
+
+// DownstreamPrices 返回某个 Key 的下游价格表快照 (backend -> price)
+func (c *RajomonController) DownstreamPrices(key string) map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshot := make(map[string]int, len(c.downstream[key]))
+	for backend, price := range c.downstream[key] {
+		snapshot[backend] = price
+	}
+	return snapshot
+}
+
+// ForgetBackend 后端下线后清理它在价格表中的记录
+func (c *RajomonController) ForgetBackend(backend string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, table := range c.downstream {
+		if _, ok := table[backend]; ok {
+			delete(table, backend)
+			metrics.DownstreamPrice.DeleteLabelValues(key, backend)
+		}
+	}
+}
+
+// effectivePriceLocked 按聚合方式合并自身价格和下游价格 (调用方需持有锁)
+// 同一个 Key 的多个后端是互为副本的关系，请求最终只会落到其中一个上，
+// 所以下游价格取各副本中最便宜的那个 (配合 lowest_price 负载均衡策略正好能选中它)
+func (c *RajomonController) effectivePriceLocked(key string, own int) int {
+	if c.aggregation == AggregateOwn {
+		return own
+	}
+	table := c.downstream[key]
+	if len(table) == 0 {
+		return own
+	}
+	downstream := -1
+	for _, price := range table {
+		if downstream < 0 || price < downstream {
+			downstream = price
+		}
+	}
+
+	if c.aggregation == AggregateSum {
+		return own + downstream
+	}
+	return max(own, downstream)
+}
@@ -0,0 +1,73 @@
+package controller
+
+import "testing"
+
+func TestDownstreamAggregation(t *testing.T) {
+	cases := []struct {
+		mode       string
+		downstream map[string]int // 后端 -> 广播的价格
+		want       int            // 自身价格为 5
+	}{
+		{mode: AggregateOwn, downstream: map[string]int{"a": 20}, want: 5},
+		{mode: AggregateMax, downstream: map[string]int{"a": 20}, want: 20},
+		{mode: AggregateMax, downstream: map[string]int{"a": 2}, want: 5},
+		{mode: AggregateSum, downstream: map[string]int{"a": 20}, want: 25},
+		// 多个后端互为副本，下游价格取最便宜的那个
+		{mode: AggregateMax, downstream: map[string]int{"a": 20, "b": 8, "c": 30}, want: 8},
+		{mode: AggregateSum, downstream: map[string]int{"a": 20, "b": 8}, want: 13},
+		{mode: AggregateMax, downstream: nil, want: 5},
+		{mode: AggregateSum, downstream: nil, want: 5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.mode, func(t *testing.T) {
+			c := NewController() // 初始价格 5
+			if err := c.SetAggregation(tc.mode); err != nil {
+				t.Fatalf("SetAggregation(%q): %v", tc.mode, err)
+			}
+			for backend, price := range tc.downstream {
+				c.ObserveDownstreamPrice("/mcp", backend, price)
+			}
+			if got := c.GetPrice("/mcp"); got != tc.want {
+				t.Errorf("GetPrice() with %v = %d, want %d", tc.downstream, got, tc.want)
+			}
+			if got := c.OwnPrice("/mcp"); got != 5 {
+				t.Errorf("OwnPrice() = %d, want 5 (downstream prices never leak into the own price)", got)
+			}
+		})
+	}
+}
+
+func TestDownstreamPriceIsPerKey(t *testing.T) {
+	c := NewController()
+	c.SetAggregation(AggregateMax)
+	c.ObserveDownstreamPrice("/chat", "a", 20)
+	if got := c.GetPrice("/mcp"); got != 5 {
+		t.Errorf("GetPrice(/mcp) = %d, want 5 (only /chat saw a downstream price)", got)
+	}
+}
+
+func TestForgetBackend(t *testing.T) {
+	c := NewController()
+	c.SetAggregation(AggregateMax)
+	c.ObserveDownstreamPrice("/mcp", "a", 20)
+	c.ObserveDownstreamPrice("/mcp", "b", 30)
+	c.ObserveDownstreamPrice("/chat", "a", 40)
+
+	c.ForgetBackend("a")
+	if got := c.DownstreamPrices("/mcp"); len(got) != 1 || got["b"] != 30 {
+		t.Errorf("DownstreamPrices(/mcp) = %v, want only b", got)
+	}
+	if got := c.GetPrice("/mcp"); got != 30 {
+		t.Errorf("GetPrice(/mcp) = %d, want 30", got)
+	}
+	if got := c.GetPrice("/chat"); got != 5 {
+		t.Errorf("GetPrice(/chat) = %d, want 5 (its only backend is gone)", got)
+	}
+}
+
+func TestSetAggregationRejectsUnknownMode(t *testing.T) {
+	c := NewController()
+	if err := c.SetAggregation("avg"); err == nil {
+		t.Error("SetAggregation(\"avg\") succeeded, want an error")
+	}
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// logOutput 控制器和定价策略的日志输出位置
+// 仿真一次会做成千上万次调价，用 SetLogOutput(io.Discard) 关掉逐次的调价日志
+var logOutput atomic.Pointer[io.Writer]
+
+// SetLogOutput 设置控制器日志的输出位置 (默认 os.Stdout)
+func SetLogOutput(w io.Writer) {
+	logOutput.Store(&w)
+}
+
+func logf(format string, args ...any) {
+	w := io.Writer(os.Stdout)
+	if p := logOutput.Load(); p != nil {
+		w = *p
+	}
+	fmt.Fprintf(w, format, args...)
+}
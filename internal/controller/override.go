@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"fmt"
+	"rajomon-gateway/internal/metrics"
+	"sort"
+	"time"
+)
+
+// 价格干预方式：事故期间由运维人员手动固定或限制某个 Key 的对外价格
+const (
+	OverridePin   = "pin"   // 固定价格
+	OverrideFloor = "floor" // 价格下限
+	OverrideCeil  = "ceil"  // 价格上限
+)
+
+// Override 一条价格干预
+// 干预只作用于对外价格 (GetPrice)，策略照常演化自身价格，干预到期或撤销后无缝接回
+type Override struct {
+	Kind    string    `json:"kind"`
+	Price   int       `json:"price"`
+	Expires time.Time `json:"expires"` // 零值 (0001-01-01T00:00:00Z) 表示不过期
+}
+
+func (o Override) expired(now time.Time) bool {
+	return !o.Expires.IsZero() && !now.Before(o.Expires)
+}
+
+// SetOverride 为 Key 设置价格干预，同一个 Key 的同类干预会被替换
+func (c *RajomonController) SetOverride(key string, o Override) error {
+	switch o.Kind {
+	case OverridePin, OverrideFloor, OverrideCeil:
+	default:
+		return fmt.Errorf("未知的价格干预方式: %q (pin / floor / ceil)", o.Kind)
+	}
+	if o.Price < 0 {
+		return fmt.Errorf("价格不能为负数: %d", o.Price)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table := c.overridesLocked(key, time.Now())
+	if o.Kind == OverrideFloor {
+		if ceil, ok := table[OverrideCeil]; ok && o.Price > ceil.Price {
+			return fmt.Errorf("下限 %d 高于已有的上限 %d", o.Price, ceil.Price)
+		}
+	}
+	if o.Kind == OverrideCeil {
+		if floor, ok := table[OverrideFloor]; ok && o.Price < floor.Price {
+			return fmt.Errorf("上限 %d 低于已有的下限 %d", o.Price, floor.Price)
+		}
+	}
+
+	if table == nil {
+		table = make(map[string]Override)
+		c.overrides[key] = table
+	}
+	table[o.Kind] = o
+	metrics.PriceOverride.WithLabelValues(key, o.Kind).Set(float64(o.Price))
+	return nil
+}
+
+// ClearOverride 撤销 Key 的价格干预，kind 为空表示撤销全部；返回是否有干预被撤销
+func (c *RajomonController) ClearOverride(key, kind string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table := c.overrides[key]
+	cleared := false
+	for k := range table {
+		if kind == "" || k == kind {
+			c.dropOverrideLocked(key, k)
+			cleared = true
+		}
+	}
+	return cleared
+}
+
+// Overrides 返回 Key 当前生效的价格干预 (按 pin / floor / ceil 排序)
+func (c *RajomonController) Overrides(key string) []Override {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table := c.overridesLocked(key, time.Now())
+	list := make([]Override, 0, len(table))
+	for _, o := range table {
+		list = append(list, o)
+	}
+	sort.Slice(list, func(i, j int) bool { return overrideOrder[list[i].Kind] < overrideOrder[list[j].Kind] })
+	return list
+}
+
+var overrideOrder = map[string]int{OverridePin: 0, OverrideFloor: 1, OverrideCeil: 2}
+
+// applyOverrideLocked 按干预调整对外价格：pin 优先，其次 floor / ceil (调用方需持有写锁)
+func (c *RajomonController) applyOverrideLocked(key string, price int) int {
+	table := c.overridesLocked(key, time.Now())
+	if len(table) == 0 {
+		return price
+	}
+	if pin, ok := table[OverridePin]; ok {
+		return pin.Price
+	}
+	if floor, ok := table[OverrideFloor]; ok && price < floor.Price {
+		price = floor.Price
+	}
+	if ceil, ok := table[OverrideCeil]; ok && price > ceil.Price {
+		price = ceil.Price
+	}
+	return price
+}
+
+// overridesLocked 返回 Key 的干预表，顺带清理已到期的干预 (调用方需持有写锁)
+func (c *RajomonController) overridesLocked(key string, now time.Time) map[string]Override {
+	table := c.overrides[key]
+	for kind, o := range table {
+		if o.expired(now) {
+			c.dropOverrideLocked(key, kind)
+			logf("⌛ [Override][%s] %s %d 已到期，恢复由策略定价\n", key, kind, o.Price)
+		}
+	}
+	return c.overrides[key]
+}
+
+// dropOverrideLocked 删除一条干预及其指标 (调用方需持有写锁)
+func (c *RajomonController) dropOverrideLocked(key, kind string) {
+	delete(c.overrides[key], kind)
+	if len(c.overrides[key]) == 0 {
+		delete(c.overrides, key)
+	}
+	metrics.PriceOverride.DeleteLabelValues(key, kind)
+}
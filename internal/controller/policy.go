@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+)
+
+// Observation 一次请求结束后采集到的观测数据
+type Observation struct {
+	Latency time.Duration // 请求耗时
+	Tokens  int           // Token 消耗
+	Retries float64       // 负载均衡器换后端重试的次数 (周期调价时为窗口内的平均值)
+}
+
+// KeyState 单个接口 (Key) 的定价状态
+// 由控制器持有，交给 PricingPolicy 读写；所有访问都在控制器的锁内完成
+type KeyState struct {
+	Price       int     // 当前价格
+	EWMALatency float64 // 平均延迟 (ms)
+	EWMATokens  float64 // 平均 Token 消耗 (个)
+	EWMARetries float64 // 平均每个请求的重试次数，重试也是后端的负载，计入成本
+	Cost        float64 // 最近一次计算出的成本 (含义由策略决定)
+}
+
+// PricingPolicy 定价策略
+// 控制器只负责按 Key 管理状态、加锁和埋点，"如何根据观测数据定价" 全部委托给策略。
+// 不同路由可以挂不同的策略 (见 RajomonController.SetPolicy)，方便做 A/B 实验。
+type PricingPolicy interface {
+	// Name 策略名称，同时作为 Prometheus 指标的 policy 标签
+	Name() string
+	// InitialPrice 新 Key 第一次出现时的初始价格
+	InitialPrice() int
+	// Observe 吸收一次观测数据 (更新 EWMA、成本等)
+	Observe(state *KeyState, obs Observation)
+	// Adjust 根据当前状态做一次价格决策，返回新价格
+	Adjust(key string, state *KeyState) int
+}
+
+// ewma 指数加权移动平均；历史值为 0 时直接采用新值，防止冷启动被拉低
+func ewma(alpha, prev, sample float64) float64 {
+	if prev == 0 {
+		return sample
+	}
+	return alpha*sample + (1-alpha)*prev
+}
+
+// retryEWMA 重试次数的 EWMA：0 是常态，不像 ewma 那样在历史值为 0 时直接采用新值
+func retryEWMA(alpha, prev, sample float64) float64 {
+	return alpha*sample + (1-alpha)*prev
+}
+
+// PolicyByName 按名称创建使用默认参数的策略 (用于环境变量/命令行配置)
+func PolicyByName(name string) (PricingPolicy, error) {
+	switch name {
+	case "", "composite":
+		return NewCompositeCostPolicy(DefaultCompositeCostConfig()), nil
+	case "aimd":
+		return NewAIMDPolicy(DefaultAIMDConfig()), nil
+	case "queuing":
+		return NewQueuingDelayPolicy(DefaultQueuingDelayConfig()), nil
+	default:
+		return nil, fmt.Errorf("未知的定价策略: %q", name)
+	}
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyAdjustDirection(t *testing.T) {
+	composite := NewCompositeCostPolicy(DefaultCompositeCostConfig()) // 阈值 200，每超 50 涨 1，最多涨 10
+	aimd := NewAIMDPolicy(DefaultAIMDConfig())                        // 目标 500ms，超标涨 50%
+
+	cases := []struct {
+		name   string
+		policy PricingPolicy
+		obs    Observation
+		price  int
+		want   int
+	}{
+		{name: "composite overload", policy: composite, obs: Observation{Latency: 500 * time.Millisecond, Tokens: 100}, price: 10, want: 12},
+		{name: "composite overload capped at MaxStep", policy: composite, obs: Observation{Latency: 10 * time.Second, Tokens: 100}, price: 10, want: 20},
+		{name: "composite retries raise the cost", policy: composite, obs: Observation{Latency: 200 * time.Millisecond, Tokens: 200, Retries: 1}, price: 10, want: 11},
+		{name: "composite steady", policy: composite, obs: Observation{Latency: 300 * time.Millisecond, Tokens: 0}, price: 10, want: 10},
+		{name: "composite underload", policy: composite, obs: Observation{Latency: 50 * time.Millisecond, Tokens: 50}, price: 10, want: 9},
+		{name: "composite underload stops at 1", policy: composite, obs: Observation{Latency: time.Millisecond}, price: 1, want: 1},
+		{name: "aimd overload", policy: aimd, obs: Observation{Latency: time.Second}, price: 10, want: 15},
+		{name: "aimd overload from 1", policy: aimd, obs: Observation{Latency: time.Second}, price: 1, want: 2},
+		{name: "aimd retries push over target", policy: aimd, obs: Observation{Latency: 450 * time.Millisecond, Retries: 1}, price: 10, want: 15},
+		{name: "aimd underload", policy: aimd, obs: Observation{Latency: 100 * time.Millisecond}, price: 10, want: 9},
+		{name: "aimd underload stops at MinPrice", policy: aimd, obs: Observation{Latency: 100 * time.Millisecond}, price: 1, want: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := &KeyState{Price: tc.price}
+			tc.policy.Observe(state, tc.obs)
+			if got := tc.policy.Adjust("/mcp", state); got != tc.want {
+				t.Errorf("Adjust() = %d (cost %.2f), want %d", got, state.Cost, tc.want)
+			}
+		})
+	}
+}
+
+func TestPolicyByName(t *testing.T) {
+	for name, want := range map[string]string{"": "composite", "composite": "composite", "aimd": "aimd", "queuing": "queuing"} {
+		p, err := PolicyByName(name)
+		if err != nil {
+			t.Errorf("PolicyByName(%q) error = %v", name, err)
+			continue
+		}
+		if p.Name() != want {
+			t.Errorf("PolicyByName(%q).Name() = %q, want %q", name, p.Name(), want)
+		}
+	}
+
+	if p, err := PolicyByName("lottery"); err == nil {
+		t.Errorf("PolicyByName(\"lottery\") = %v, want an error", p.Name())
+	}
+}
+
+func TestPerRequestPricing(t *testing.T) {
+	c := NewControllerWithPolicy(NewAIMDPolicy(DefaultAIMDConfig()))
+	if got := c.GetPrice("/mcp"); got != 5 {
+		t.Fatalf("initial price = %d, want 5", got)
+	}
+
+	// 没有周期调价时每个请求结束都调一次价
+	c.RecordLatency("/mcp", time.Second, 0)
+	c.RecordLatency("/mcp", time.Second, 0)
+	if got := c.GetPrice("/mcp"); got != 12 {
+		t.Errorf("price after two slow requests = %d, want 12 (5 -> 8 -> 12)", got)
+	}
+}
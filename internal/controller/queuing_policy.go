@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"context"
+	"math"
+	rtmetrics "runtime/metrics"
+	"sync"
+	"time"
+)
+
+// schedLatencyMetric Go 调度器延迟直方图：goroutine 从可运行到真正被调度执行之间的等待时间
+// 这就是 Rajomon 论文里使用的 "排队延迟" 过载信号
+const schedLatencyMetric = "/sched/latencies:seconds"
+
+// SchedLatencySampler 周期性读取 runtime/metrics 中的调度延迟直方图，
+// 计算 "距离上次采样以来" 的尾部排队延迟
+type SchedLatencySampler struct {
+	mu         sync.Mutex
+	percentile float64  // 取哪个分位数作为尾延迟 (如 0.99)
+	prevCounts []uint64 // 上一次采样时的累计计数
+	delay      time.Duration
+	sample     []rtmetrics.Sample
+}
+
+func NewSchedLatencySampler(percentile float64) *SchedLatencySampler {
+	return &SchedLatencySampler{
+		percentile: percentile,
+		sample:     []rtmetrics.Sample{{Name: schedLatencyMetric}},
+	}
+}
+
+// Sample 读取一次直方图，与上次的快照求差得到本周期内的分布，返回其尾部延迟
+func (s *SchedLatencySampler) Sample() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rtmetrics.Read(s.sample)
+	if s.sample[0].Value.Kind() != rtmetrics.KindFloat64Histogram {
+		// 运行时不支持该指标 (理论上 Go 1.17+ 都有)
+		return 0
+	}
+	hist := s.sample[0].Value.Float64Histogram()
+
+	// 1. 与上次快照求差 (直方图计数是单调累加的)
+	diff := make([]uint64, len(hist.Counts))
+	var total uint64
+	for i, cnt := range hist.Counts {
+		if i < len(s.prevCounts) {
+			cnt -= s.prevCounts[i]
+		}
+		diff[i] = cnt
+		total += cnt
+	}
+	s.prevCounts = append(s.prevCounts[:0], hist.Counts...)
+
+	// 2. 本周期没有任何调度事件，说明完全空闲
+	if total == 0 {
+		s.delay = 0
+		return s.delay
+	}
+
+	// 3. 找到分位数落在哪个桶，用桶的上界作为延迟估计 (偏保守)
+	target := uint64(math.Ceil(float64(total) * s.percentile))
+	var cumulative uint64
+	for i, cnt := range diff {
+		cumulative += cnt
+		if cumulative >= target {
+			upper := hist.Buckets[i+1]
+			if math.IsInf(upper, 1) {
+				upper = hist.Buckets[i]
+			}
+			s.delay = time.Duration(upper * float64(time.Second))
+			break
+		}
+	}
+	return s.delay
+}
+
+// Delay 最近一次采样得到的尾部排队延迟
+func (s *SchedLatencySampler) Delay() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delay
+}
+
+// delaySampler 排队延迟信号的来源 (默认是 SchedLatencySampler，测试时可以替换成固定信号)
+type delaySampler interface {
+	Sample() time.Duration
+	Delay() time.Duration
+}
+
+// SampledPolicy 由后台采样信号驱动的定价策略
+// 这类策略的价格只在采样时更新 (见 RajomonController.RunSampler)，
+// 请求结束时只吸收观测数据，因此流式请求再长也不会推高价格
+type SampledPolicy interface {
+	PricingPolicy
+	// Sample 采集一次过载信号
+	Sample()
+	// Interval 采样周期
+	Interval() time.Duration
+}
+
+// QueuingDelayConfig 排队延迟策略的参数
+type QueuingDelayConfig struct {
+	InitialPrice int           // 初始价格
+	Alpha        float64       // EWMA 平滑因子 (仅用于观测展示，不参与定价)
+	SLO          time.Duration // 排队延迟目标，超过即涨价
+	Percentile   float64       // 尾延迟分位数
+	Interval     time.Duration // 采样周期
+	PriceStep    int           // 每超出一个 SLO 涨多少
+	MaxStep      int           // 单次最大涨幅
+	MinPrice     int           // 降价下限
+}
+
+// DefaultQueuingDelayConfig 默认参数：每 100ms 采样一次 P99 调度延迟，SLO 1ms
+func DefaultQueuingDelayConfig() QueuingDelayConfig {
+	return QueuingDelayConfig{
+		InitialPrice: 5,
+		Alpha:        0.2,
+		SLO:          time.Millisecond,
+		Percentile:   0.99,
+		Interval:     100 * time.Millisecond,
+		PriceStep:    1,
+		MaxStep:      10,
+		MinPrice:     1,
+	}
+}
+
+// QueuingDelayPolicy 基于 Go 调度器排队延迟的定价 (Rajomon 原论文的过载信号)
+type QueuingDelayPolicy struct {
+	cfg     QueuingDelayConfig
+	sampler delaySampler
+}
+
+// NewQueuingDelayPolicy 创建排队延迟策略
+// SLO 和采样周期为零时使用默认值：SLO 是涨价步数的除数，采样周期用来创建 Ticker，都不能为零
+func NewQueuingDelayPolicy(cfg QueuingDelayConfig) *QueuingDelayPolicy {
+	def := DefaultQueuingDelayConfig()
+	if cfg.SLO <= 0 {
+		cfg.SLO = def.SLO
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = def.Interval
+	}
+	return &QueuingDelayPolicy{
+		cfg:     cfg,
+		sampler: NewSchedLatencySampler(cfg.Percentile),
+	}
+}
+
+func (p *QueuingDelayPolicy) Name() string { return "queuing" }
+
+func (p *QueuingDelayPolicy) InitialPrice() int { return p.cfg.InitialPrice }
+
+// Config 返回当前参数 (只读副本)
+func (p *QueuingDelayPolicy) Config() QueuingDelayConfig { return p.cfg }
+
+func (p *QueuingDelayPolicy) Interval() time.Duration { return p.cfg.Interval }
+
+func (p *QueuingDelayPolicy) Sample() { p.sampler.Sample() }
+
+func (p *QueuingDelayPolicy) Observe(state *KeyState, obs Observation) {
+	// 延迟和 Token 只做展示，定价完全由排队延迟决定
+	state.EWMALatency = ewma(p.cfg.Alpha, state.EWMALatency, float64(obs.Latency.Milliseconds()))
+	state.EWMATokens = ewma(p.cfg.Alpha, state.EWMATokens, float64(obs.Tokens))
+	state.EWMARetries = retryEWMA(p.cfg.Alpha, state.EWMARetries, obs.Retries)
+}
+
+func (p *QueuingDelayPolicy) Adjust(key string, state *KeyState) int {
+	delay := p.sampler.Delay()
+	// 成本 = 排队延迟 (ms)
+	state.Cost = float64(delay) / float64(time.Millisecond)
+	price := state.Price
+
+	if delay > p.cfg.SLO {
+		// 超出 SLO 几倍就涨几个步长
+		step := p.cfg.PriceStep * int(delay/p.cfg.SLO)
+		if step < p.cfg.PriceStep {
+			step = p.cfg.PriceStep
+		}
+		if p.cfg.MaxStep > 0 && step > p.cfg.MaxStep {
+			step = p.cfg.MaxStep
+		}
+		price += step
+		logf("📈 [Controller][%s] 排队延迟超标(%v > SLO %v) -> 涨价 %d (现价:%d)\n",
+			key, delay, p.cfg.SLO, step, price)
+	} else if delay < p.cfg.SLO/2 && price > p.cfg.MinPrice {
+		price--
+		logf("📉 [Controller][%s] 排队延迟回落(%v) -> 降价至 %d\n", key, delay, price)
+	}
+	return price
+}
+
+// RunSampler 按策略的采样周期驱动定价，直到 ctx 被取消
+// 每个周期先采样一次过载信号，再对所有使用该策略的 Key 做一次价格决策
+// 注意：周期性调价模式 (Run) 下由 Tick 负责采样，不需要再单独启动 RunSampler
+func (c *RajomonController) RunSampler(ctx context.Context, policy SampledPolicy) {
+	ticker := time.NewTicker(policy.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			policy.Sample()
+			c.reprice(policy)
+		}
+	}
+}
+
+// reprice 对所有挂载了指定策略的 Key 做一次价格决策
+func (c *RajomonController) reprice(policy PricingPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, state := range c.states {
+		if c.policyFor(key) != policy {
+			continue
+		}
+		c.adjustLocked(key, policy, state)
+	}
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSampler 每次 Sample 依次取出预设的排队延迟，取完后保持最后一个
+type fakeSampler struct {
+	mu     sync.Mutex
+	delays []time.Duration
+	delay  time.Duration
+}
+
+func (s *fakeSampler) Sample() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.delays) > 0 {
+		s.delay, s.delays = s.delays[0], s.delays[1:]
+	}
+	return s.delay
+}
+
+func (s *fakeSampler) Delay() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delay
+}
+
+func newFakeQueuingPolicy(cfg QueuingDelayConfig, delays ...time.Duration) *QueuingDelayPolicy {
+	p := NewQueuingDelayPolicy(cfg)
+	p.sampler = &fakeSampler{delays: delays}
+	return p
+}
+
+func TestQueuingDelayAdjustDirection(t *testing.T) {
+	cases := []struct {
+		name  string
+		delay time.Duration
+		price int
+		want  int
+	}{
+		{name: "over SLO raises by multiples of SLO", delay: 3 * time.Millisecond, price: 10, want: 13},
+		{name: "far over SLO capped at MaxStep", delay: time.Second, price: 10, want: 20},
+		{name: "between SLO/2 and SLO holds", delay: 700 * time.Microsecond, price: 10, want: 10},
+		{name: "under SLO/2 lowers", delay: 100 * time.Microsecond, price: 10, want: 9},
+		{name: "idle stops at MinPrice", delay: 0, price: 1, want: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newFakeQueuingPolicy(DefaultQueuingDelayConfig(), tc.delay) // SLO 1ms，最多涨 10
+			p.Sample()
+			state := &KeyState{Price: tc.price}
+			if got := p.Adjust("/mcp", state); got != tc.want {
+				t.Errorf("Adjust() = %d, want %d", got, tc.want)
+			}
+			if want := float64(tc.delay) / float64(time.Millisecond); state.Cost != want {
+				t.Errorf("Cost = %v, want %v (queuing delay in ms)", state.Cost, want)
+			}
+		})
+	}
+}
+
+func TestSampledPolicyRepricesOnlyItsKeys(t *testing.T) {
+	queuing := newFakeQueuingPolicy(DefaultQueuingDelayConfig(), 5*time.Millisecond)
+	c := NewController()
+	c.SetPolicy("/chat", queuing)
+	for _, key := range []string{"/chat", "/chat:tools/call", "/other"} {
+		c.GetPrice(key)
+	}
+
+	// 请求结束时只吸收观测数据，不调价
+	c.RecordLatency("/chat", 10*time.Second, 1000)
+	if got := c.GetPrice("/chat"); got != 5 {
+		t.Fatalf("price after a slow request = %d, want 5 (sampled policies only reprice on samples)", got)
+	}
+
+	queuing.Sample()
+	c.reprice(queuing)
+	want := map[string]int{"/chat": 10, "/chat:tools/call": 10, "/other": 5}
+	for key, price := range want {
+		if got := c.GetPrice(key); got != price {
+			t.Errorf("GetPrice(%q) after reprice = %d, want %d", key, got, price)
+		}
+	}
+}
+
+func TestRunSamplerDrivesPrice(t *testing.T) {
+	cfg := DefaultQueuingDelayConfig()
+	cfg.Interval = time.Millisecond
+	queuing := newFakeQueuingPolicy(cfg, 2*time.Millisecond)
+	c := NewControllerWithPolicy(queuing)
+	c.GetPrice("/mcp")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.RunSampler(ctx, queuing)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for c.GetPrice("/mcp") < 10 {
+		if time.Now().After(deadline) {
+			t.Fatalf("price = %d after 2s of overload samples, want it to keep rising", c.GetPrice("/mcp"))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueuingDelayZeroConfigUsesDefaults(t *testing.T) {
+	cfg := DefaultQueuingDelayConfig()
+	cfg.SLO, cfg.Interval = 0, 0
+	p := newFakeQueuingPolicy(cfg, 3*time.Millisecond)
+	if got := p.Config(); got.SLO != time.Millisecond || got.Interval != 100*time.Millisecond {
+		t.Fatalf("Config() = %+v, want default SLO and interval", got)
+	}
+
+	p.Sample()
+	if got := p.Adjust("/mcp", &KeyState{Price: 10}); got != 13 {
+		t.Errorf("Adjust() with zero SLO = %d, want 13 (default 1ms SLO)", got)
+	}
+}
@@ -1,130 +1,332 @@
 package controller
 
 import (
-	"fmt"
 	"rajomon-gateway/internal/metrics"
-	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 type RajomonController struct {
-	mu           sync.RWMutex
+	mu sync.RWMutex
 
 	// --- 1. 接口粒度控制 (Interface Granularity) ---
 	// 使用 Map 存储每个接口/模型的状态
-	// Key 通常是 URL Path (e.g., "/mcp/chat") 或 模型名称
-	Prices 	map[string]int		// 各接口当前价格
-	ewmaLatency map[string]float64 // 各接口平均延迟 (ms)
-	ewmaTokens  map[string]float64 // 各接口平均 Token 消耗 (个)
+	// Key 通常是 URL Path (e.g., "/mcp/chat")，或者派生的 "<路由>:<子键>" (e.g., "/mcp:tools/call/web_search")
+	states map[string]*KeyState
 
-	// --- 权重与阈值配置 ---
-	alpha         float64 // 平滑因子
-	latencyWeight float64 // 延迟在定价中的权重（比如0.5）
-	tokenWeight   float64 // Token 消耗在定价中的权重（比如0.5）
-	baseThreshold float64 // 综合成本阈值
+	// --- 2. 定价策略 ---
+	// 默认策略作用于所有 Key；policies 中可以为单个 Key 覆盖策略 (A/B 实验)
+	defaultPolicy PricingPolicy
+	policies      map[string]PricingPolicy
 
-	// --- 2. 比例价格更新参数 ---
-	// 价格敏感度：每超出阈值多少分，价格 +1
-	// 例如：阈值 200，敏感度 50。如果 Cost=350 `(超150)，则价格涨 int(150/50) = 3`
-	priceStepUnit float64
+	// --- 3. 周期性调价 ---
+	// loop 为 nil 时每个请求结束都会调价；Run 启动后改为按周期聚合 (见 update_loop.go)
+	loop    *UpdateLoopConfig
+	pending map[string]*window
+
+	// --- 4. 多跳价格传播 ---
+	// downstream: Key -> 后端 -> 后端广播的价格；对外价格按 aggregation 合并 (见 downstream.go)
+	downstream  map[string]map[string]int
+	aggregation string
+
+	// --- 5. 人工干预 ---
+	// overrides: Key -> 干预方式 -> 干预 (见 override.go)
+	overrides map[string]map[string]Override
 }
 
+// NewController 使用默认的综合成本策略创建控制器
 func NewController() *RajomonController {
+	return NewControllerWithPolicy(NewCompositeCostPolicy(DefaultCompositeCostConfig()))
+}
+
+// NewControllerWithPolicy 使用指定的默认定价策略创建控制器
+func NewControllerWithPolicy(policy PricingPolicy) *RajomonController {
 	return &RajomonController{
-		Prices:		make(map[string]int),
-		ewmaLatency: make(map[string]float64),
-		ewmaTokens: make(map[string]float64),
+		states:        make(map[string]*KeyState),
+		defaultPolicy: policy,
+		policies:      make(map[string]PricingPolicy),
+		pending:       make(map[string]*window),
+		downstream:    make(map[string]map[string]int),
+		aggregation:   AggregateOwn,
+		overrides:     make(map[string]map[string]Override),
+	}
+}
+
+// SetPolicy 为指定接口挂载独立的定价策略
+// 已有的价格和 EWMA 状态会保留，由新策略接着演化
+func (c *RajomonController) SetPolicy(key string, policy PricingPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.replacePolicyLocked(key, func() { c.policies[key] = policy })
+}
+
+// SetDefaultPolicy 替换默认定价策略 (配置热加载)，已有 Key 的状态保留
+func (c *RajomonController) SetDefaultPolicy(policy PricingPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.replacePolicyLocked("", func() { c.defaultPolicy = policy })
+}
+
+// ClearPolicy 移除指定接口的独立策略，回退到所属路由或默认策略
+func (c *RajomonController) ClearPolicy(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.replacePolicyLocked(key, func() { delete(c.policies, key) })
+}
 
-		alpha:         0.2, // 权重：新数据占 20%，历史数据占 80%
-		latencyWeight: 0.5, // 延迟权重 50%
-		tokenWeight:   0.5, // Token 权重 50%
-		baseThreshold: 200, // 综合分超过 200 就涨价
-		priceStepUnit: 50.0, // 灵敏度：每超 50 分涨 1 块钱
+// replacePolicyLocked 修改策略表，并清理受影响 Key 的旧策略标签 (调用方需持有写锁)
+// key 为空表示影响所有 Key (默认策略)；否则只影响 key 本身和它派生出的 "<key>:<子键>"
+func (c *RajomonController) replacePolicyLocked(key string, update func()) {
+	affected := func(k string) bool {
+		return key == "" || k == key || strings.HasPrefix(k, key+":")
+	}
+	before := map[string]string{key: c.policyFor(key).Name()}
+	for k := range c.states {
+		if affected(k) {
+			before[k] = c.policyFor(k).Name()
+		}
+	}
+
+	update()
+
+	for k, name := range before {
+		if k != "" && c.policyFor(k).Name() != name {
+			// 旧策略的标签不再更新，删掉避免 Grafana 上出现 "僵尸" 曲线
+			metrics.CurrentPrice.DeleteLabelValues(k, name)
+			metrics.CompositeCost.DeleteLabelValues(k, name)
+		}
 	}
 }
 
-// GetPrice 获取指定接口的当前价格 (支持惰性初始化)
-func (c *RajomonController) GetPrice(key string) int {
-	c.mu.Lock() // 使用写锁，因为可能需要初始化 Map
+// Retain 只保留 keep 返回 true 的 Key (配置热加载后路由被删除的场景)
+// 其余 Key 的价格、EWMA、待结算数据和下游价格一并清理，返回被清理的 Key
+func (c *RajomonController) Retain(keep func(key string) bool) []string {
+	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// 如果该接口是第一次访问，初始化默认价格
-	if _, exists := c.Prices[key]; !exists {
-		c.Prices[key] = 5 // 默认初始价格
-		// 初始化 EWMA 状态，防止计算时取到 0 导致波动
-		c.ewmaLatency[key] = 0
-		c.ewmaTokens[key] = 0
+	var removed []string
+	for key := range c.states {
+		if keep(key) {
+			continue
+		}
+		name := c.policyFor(key).Name()
+		metrics.CurrentPrice.DeleteLabelValues(key, name)
+		metrics.CompositeCost.DeleteLabelValues(key, name)
+		delete(c.states, key)
+		delete(c.pending, key)
+		removed = append(removed, key)
+	}
+	for key, table := range c.overrides {
+		if keep(key) {
+			continue
+		}
+		for kind := range table {
+			c.dropOverrideLocked(key, kind)
+		}
+	}
+	for key, table := range c.downstream {
+		if keep(key) {
+			continue
+		}
+		for backend := range table {
+			metrics.DownstreamPrice.DeleteLabelValues(key, backend)
+		}
+		delete(c.downstream, key)
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// Policy 返回指定接口当前生效的定价策略
+func (c *RajomonController) Policy(key string) PricingPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.policyFor(key)
+}
+
+// policyFor 调用方需持有锁
+// 派生 Key ("<路由>:<子键>"，如 "/mcp:tools/call/web_search") 没有单独配置时，沿用所属路由的策略
+func (c *RajomonController) policyFor(key string) PricingPolicy {
+	if p, ok := c.policies[key]; ok {
+		return p
 	}
-	return c.Prices[key]
+	if route, _, ok := strings.Cut(key, ":"); ok {
+		if p, ok := c.policies[route]; ok {
+			return p
+		}
+	}
+	return c.defaultPolicy
 }
 
+// stateFor 获取 Key 的状态，不存在则按策略初始化 (调用方需持有写锁)
+func (c *RajomonController) stateFor(key string) *KeyState {
+	state, exists := c.states[key]
+	if !exists {
+		// 如果该接口是第一次访问，初始化默认价格
+		// EWMA 状态为 0，第一次观测时直接采用观测值，防止计算时取到 0 导致波动
+		state = &KeyState{Price: c.policyFor(key).InitialPrice()}
+		c.states[key] = state
+	}
+	return state
+}
 
+// GetPrice 获取指定接口的当前对外价格 (支持惰性初始化)
+// 对外价格 = 自身价格与下游价格按聚合方式合并的结果 (再叠加人工干预)，准入检查用的就是它
+func (c *RajomonController) GetPrice(key string) int {
+	c.mu.Lock() // 使用写锁，因为可能需要初始化 Map
+	defer c.mu.Unlock()
 
+	return c.applyOverrideLocked(key, c.effectivePriceLocked(key, c.stateFor(key).Price))
+}
 
-// RecordLatency 同时接收延迟和Token消耗
-func (c *RajomonController) RecordLatency(key string, latency time.Duration, tokenCount int) {
+// OwnPrice 获取指定接口自身的价格 (不含下游)
+func (c *RajomonController) OwnPrice(key string) int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// 1. 数据准备
-	latencyMs := float64(latency.Milliseconds())
-	tokens := float64(tokenCount)
+	return c.stateFor(key).Price
+}
 
-	// 2. EWMA 更新 (针对特定 Key 更新对应的平均值)
-	if val, exists := c.ewmaLatency[key]; !exists || val == 0 {
-		c.ewmaLatency[key] = latencyMs
-	} else {
-		c.ewmaLatency[key] = c.alpha*latencyMs + (1-c.alpha)*val
+// State 返回指定 Key 的定价状态快照 (不会惰性初始化)
+func (c *RajomonController) State(key string) (KeyState, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	state, ok := c.states[key]
+	if !ok {
+		return KeyState{}, false
 	}
+	return *state, true
+}
+
+// PredictLatency 按延迟 EWMA 预测 Key 的请求耗时 (不会惰性初始化)；还没有观测数据时返回 false
+func (c *RajomonController) PredictLatency(key string) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	if val, exists := c.ewmaTokens[key]; !exists || val == 0 {
-		c.ewmaTokens[key] = tokens
-	} else {
-		c.ewmaTokens[key] = c.alpha*tokens + (1-c.alpha)*val
+	state, ok := c.states[key]
+	if !ok || state.EWMALatency <= 0 {
+		return 0, false
 	}
+	return time.Duration(state.EWMALatency * float64(time.Millisecond)), true
+}
+
+// Reset 清空 Key 的价格、EWMA 和待结算数据，下次访问时按策略重新初始化
+// 下游价格和人工干预不受影响；返回 Key 是否存在
+func (c *RajomonController) Reset(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
+	if _, ok := c.states[key]; !ok {
+		return false
+	}
+	name := c.policyFor(key).Name()
+	metrics.CurrentPrice.DeleteLabelValues(key, name)
+	metrics.CompositeCost.DeleteLabelValues(key, name)
+	delete(c.states, key)
+	delete(c.pending, key)
+	return true
+}
 
-	// 3. 计算综合成本
-	currentLat := c.ewmaLatency[key]
-	currentTok := c.ewmaTokens[key]
-	compositeCost := (c.latencyWeight * currentLat) + (c.tokenWeight * currentTok)
+// KeyInfo 一个 Key 的完整定价视图 (管理接口使用)
+type KeyInfo struct {
+	Key         string         `json:"key"`
+	Policy      string         `json:"policy"`
+	Price       int            `json:"price"`     // 对外价格 (含下游和人工干预)
+	OwnPrice    int            `json:"own_price"` // 策略算出的自身价格
+	EWMALatency float64        `json:"ewma_latency_ms"`
+	EWMATokens  float64        `json:"ewma_tokens"`
+	EWMARetries float64        `json:"ewma_retries"`
+	Cost        float64        `json:"cost"`
+	Downstream  map[string]int `json:"downstream,omitempty"`
+	Overrides   []Override     `json:"overrides,omitempty"`
+}
 
-	// [埋点] 记录该接口的成本 (Label=key)
-	metrics.CompositeCost.WithLabelValues(key).Set(compositeCost)
+// Snapshot 返回所有 Key 的定价视图 (按 Key 排序)，只有干预没有流量的 Key 也会列出
+func (c *RajomonController) Snapshot() []KeyInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// --- 4. 比例价格更新 (Proportional Price Updates) ---
-	currentPrice := c.Prices[key]
+	keys := make(map[string]bool, len(c.states))
+	for key := range c.states {
+		keys[key] = true
+	}
+	for key := range c.overrides {
+		keys[key] = true
+	}
 
-	// 5. 动态定价
-	if compositeCost > c.baseThreshold {
-		// 计算超出的部分
-		excess := compositeCost - c.baseThreshold
-		// 计算涨价步长：步长 = (超出量 / 单位量) + 基础步长
-		// 必须保证至少涨 1 块
-		step := int(math.Floor(excess / c.priceStepUnit))
-		if step < 1 {
-			step = 1
+	infos := make([]KeyInfo, 0, len(keys))
+	for key := range keys {
+		info := KeyInfo{Key: key, Policy: c.policyFor(key).Name()}
+		own := c.policyFor(key).InitialPrice()
+		if state, ok := c.states[key]; ok {
+			own = state.Price
+			info.EWMALatency, info.EWMATokens, info.EWMARetries, info.Cost = state.EWMALatency, state.EWMATokens, state.EWMARetries, state.Cost
+		}
+		info.OwnPrice = own
+		info.Price = c.applyOverrideLocked(key, c.effectivePriceLocked(key, own))
+		if table := c.downstream[key]; len(table) > 0 {
+			info.Downstream = make(map[string]int, len(table))
+			for backend, price := range table {
+				info.Downstream[backend] = price
+			}
 		}
-		
-		// 安全限制：防止单次涨幅过大导致震荡 (可选)
-		if step > 10 {
-			step = 10
+		for _, o := range c.overrides[key] {
+			info.Overrides = append(info.Overrides, o)
 		}
-		c.Prices[key] += step
-		fmt.Printf("📈 [Controller][%s] 成本过高(Cost:%.0f, Excess:%.0f) -> 猛涨 %d (现价:%d)\n",
-			key, compositeCost, excess, step, c.Prices[key])
-	} else if compositeCost < c.baseThreshold/2 && currentPrice > 1 {
-		// 降价逻辑通常保持平缓（线性回落），避免系统震荡
-		// 也可以按比例降价，但为了系统稳定性，推荐线性降价
-		c.Prices[key]--
-		fmt.Printf("📉 [Controller][%s] 成本回落(Cost:%.0f) -> 降价至 %d\n", key, compositeCost, c.Prices[key])
+		sort.Slice(info.Overrides, func(i, j int) bool {
+			return overrideOrder[info.Overrides[i].Kind] < overrideOrder[info.Overrides[j].Kind]
+		})
+		infos = append(infos, info)
 	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos
+}
 
-	// [埋点] 记录最新价格
-	metrics.CurrentPrice.WithLabelValues(key).Set(float64(c.Prices[key]))
+// RecordLatency 同时接收延迟和Token消耗
+func (c *RajomonController) RecordLatency(key string, latency time.Duration, tokenCount int) {
+	c.RecordObservation(key, Observation{Latency: latency, Tokens: tokenCount})
 }
 
+// RecordObservation 记录一次请求的完整观测数据 (延迟、Token、重试次数)
+func (c *RajomonController) RecordObservation(key string, obs Observation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	policy := c.policyFor(key)
+	state := c.stateFor(key)
+
+	// 周期性调价模式：只累加，由 Tick 统一结算
+	if c.loop != nil {
+		c.record(key, obs)
+		return
+	}
+
+	// 1. 交给策略吸收观测数据 (EWMA、成本)
+	policy.Observe(state, obs)
+
+	// 采样驱动的策略 (如排队延迟) 只在采样周期内调价，这里不动价格
+	if _, ok := policy.(SampledPolicy); ok {
+		return
+	}
+
+	// 2. 动态定价
+	c.adjustLocked(key, policy, state)
+}
+
+// adjustLocked 执行一次价格决策并更新指标 (调用方需持有写锁)
+func (c *RajomonController) adjustLocked(key string, policy PricingPolicy, state *KeyState) {
+	state.Price = c.clampLocked(policy.Adjust(key, state))
+
+	// [埋点] 记录该接口的成本和最新价格 (Label=key, policy)
+	metrics.CompositeCost.WithLabelValues(key, policy.Name()).Set(state.Cost)
+	metrics.CurrentPrice.WithLabelValues(key, policy.Name()).Set(float64(state.Price))
+}
 
 // Lock()（写锁/互斥锁）：
 // 排他性：一旦某个 Goroutine 持有了写锁，其他任何 Goroutine（无论是想读还是想写）都必须等待，直到该锁被释放。
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"rajomon-gateway/internal/metrics"
+	"time"
+)
+
+// UpdateLoopConfig 周期性调价循环的参数
+type UpdateLoopConfig struct {
+	Interval  time.Duration // 调价周期
+	DecayStep int           // 空闲 Key (本周期无流量) 每个周期回落多少
+	Floor     int           // 价格下限
+	Ceiling   int           // 价格上限 (0 表示不设上限)
+}
+
+// DefaultUpdateLoopConfig 默认每 100ms 调价一次，空闲时每周期降 1，最低 1 块
+func DefaultUpdateLoopConfig() UpdateLoopConfig {
+	return UpdateLoopConfig{
+		Interval:  100 * time.Millisecond,
+		DecayStep: 1,
+		Floor:     1,
+		Ceiling:   0,
+	}
+}
+
+// window 一个调价周期内某个 Key 的观测数据聚合
+type window struct {
+	count      int
+	latencySum time.Duration
+	tokenSum   int
+	retrySum   float64
+}
+
+// Run 启动周期性调价循环，直到 ctx 被取消
+// 开启后 RecordLatency 只负责把观测数据累加进当前周期，
+// 价格由 Tick 统一决策：每个 Key 每个周期最多调价一次，价格变化速度不再随吞吐量放大
+func (c *RajomonController) Run(ctx context.Context, cfg UpdateLoopConfig) {
+	c.UseUpdateLoop(cfg)
+	logf("⏱️ [Controller] 周期性调价已启动 | 周期: %v | 空闲衰减: %d | 区间: [%d, %d]\n",
+		cfg.Interval, cfg.DecayStep, cfg.Floor, cfg.Ceiling)
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// 退出前把最后一个周期的数据结算掉，然后回到逐请求调价模式
+			c.Tick()
+			c.mu.Lock()
+			c.loop = nil
+			c.mu.Unlock()
+			logf("🛑 [Controller] 周期性调价已停止\n")
+			return
+		case <-ticker.C:
+			c.Tick()
+		}
+	}
+}
+
+// UseUpdateLoop 切换到周期性调价模式，但不启动定时器：由调用方自己驱动 Tick
+// (例如仿真器按虚拟时钟调用)
+func (c *RajomonController) UseUpdateLoop(cfg UpdateLoopConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loop = &cfg
+}
+
+// Tick 结算一个调价周期
+// 1. 采样驱动的策略先采样一次过载信号
+// 2. 有流量的 Key：把本周期的平均延迟/Token 交给策略，做一次价格决策
+// 3. 空闲的 Key：价格向下限衰减 (采样驱动的策略除外，它们照常按信号调价)
+func (c *RajomonController) Tick() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 同一个策略实例可能挂在多个 Key 上，只采样一次
+	sampled := make(map[SampledPolicy]bool)
+	for key := range c.states {
+		if sp, ok := c.policyFor(key).(SampledPolicy); ok && !sampled[sp] {
+			sp.Sample()
+			sampled[sp] = true
+		}
+	}
+
+	for key, state := range c.states {
+		policy := c.policyFor(key)
+		_, isSampled := policy.(SampledPolicy)
+		w := c.pending[key]
+		if w == nil || w.count == 0 {
+			// 采样驱动的策略看的是全局信号，和该 Key 有没有流量无关
+			if isSampled {
+				c.adjustLocked(key, policy, state)
+			} else {
+				c.decayLocked(key, policy, state)
+			}
+			continue
+		}
+
+		policy.Observe(state, Observation{
+			Latency: w.latencySum / time.Duration(w.count),
+			Tokens:  w.tokenSum / w.count,
+			Retries: w.retrySum / float64(w.count),
+		})
+		c.adjustLocked(key, policy, state)
+	}
+	clear(c.pending)
+}
+
+// decayLocked 空闲 Key 的价格衰减 (调用方需持有写锁)
+func (c *RajomonController) decayLocked(key string, policy PricingPolicy, state *KeyState) {
+	floor := 1
+	step := 1
+	if c.loop != nil {
+		floor, step = c.loop.Floor, c.loop.DecayStep
+	}
+	if state.Price <= floor || step <= 0 {
+		return
+	}
+	state.Price -= step
+	if state.Price < floor {
+		state.Price = floor
+	}
+	metrics.CurrentPrice.WithLabelValues(key, policy.Name()).Set(float64(state.Price))
+}
+
+// record 把一次观测累加进当前周期 (调用方需持有写锁)
+func (c *RajomonController) record(key string, obs Observation) {
+	w := c.pending[key]
+	if w == nil {
+		w = &window{}
+		c.pending[key] = w
+	}
+	w.count++
+	w.latencySum += obs.Latency
+	w.tokenSum += obs.Tokens
+	w.retrySum += obs.Retries
+}
+
+// clampLocked 把价格限制在周期调价配置的区间内 (调用方需持有写锁)
+func (c *RajomonController) clampLocked(price int) int {
+	if c.loop == nil {
+		return price
+	}
+	if price < c.loop.Floor {
+		price = c.loop.Floor
+	}
+	if c.loop.Ceiling > 0 && price > c.loop.Ceiling {
+		price = c.loop.Ceiling
+	}
+	return price
+}
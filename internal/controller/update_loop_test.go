@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTickAdjustsOncePerPeriod(t *testing.T) {
+	c := NewControllerWithPolicy(NewAIMDPolicy(DefaultAIMDConfig()))
+	c.UseUpdateLoop(DefaultUpdateLoopConfig())
+	c.GetPrice("/mcp")
+
+	// 一个周期内的 100 个慢请求只触发一次调价
+	for range 100 {
+		c.RecordLatency("/mcp", time.Second, 0)
+	}
+	if got := c.GetPrice("/mcp"); got != 5 {
+		t.Fatalf("price before Tick = %d, want 5 (observations are only accumulated)", got)
+	}
+	c.Tick()
+	if got := c.GetPrice("/mcp"); got != 8 {
+		t.Errorf("price after Tick = %d, want 8 (one AIMD step)", got)
+	}
+}
+
+func TestTickIdleDecayAndBounds(t *testing.T) {
+	cases := []struct {
+		name  string
+		loop  UpdateLoopConfig
+		price int
+		obs   time.Duration // 本周期唯一一个请求的耗时 (0 表示空闲；超过 500ms 时 AIMD 涨 50%)
+		want  int
+	}{
+		{name: "idle key decays", loop: UpdateLoopConfig{DecayStep: 2, Floor: 1}, price: 10, want: 8},
+		{name: "idle decay stops at floor", loop: UpdateLoopConfig{DecayStep: 5, Floor: 3}, price: 6, want: 3},
+		{name: "idle below floor is left alone", loop: UpdateLoopConfig{DecayStep: 1, Floor: 3}, price: 2, want: 2},
+		{name: "zero decay step keeps idle price", loop: UpdateLoopConfig{DecayStep: 0, Floor: 1}, price: 10, want: 10},
+		{name: "raise is clamped to ceiling", loop: UpdateLoopConfig{DecayStep: 1, Floor: 1, Ceiling: 12}, price: 10, obs: time.Second, want: 12},
+		{name: "no ceiling", loop: UpdateLoopConfig{DecayStep: 1, Floor: 1}, price: 10, obs: time.Second, want: 15},
+		{name: "policy decrease is clamped to floor", loop: UpdateLoopConfig{DecayStep: 1, Floor: 10}, price: 10, obs: 100 * time.Millisecond, want: 10},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := DefaultAIMDConfig()
+			cfg.InitialPrice = tc.price
+			c := NewControllerWithPolicy(NewAIMDPolicy(cfg))
+			c.UseUpdateLoop(tc.loop)
+			c.GetPrice("/mcp")
+
+			if tc.obs > 0 {
+				c.RecordLatency("/mcp", tc.obs, 0)
+			}
+			c.Tick()
+			if got := c.GetPrice("/mcp"); got != tc.want {
+				t.Errorf("price after Tick = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTickRepricesIdleSampledKeys(t *testing.T) {
+	queuing := newFakeQueuingPolicy(DefaultQueuingDelayConfig(), 3*time.Millisecond, 0)
+	c := NewControllerWithPolicy(queuing)
+	c.UseUpdateLoop(UpdateLoopConfig{DecayStep: 1, Floor: 1})
+	c.GetPrice("/mcp")
+
+	// 没有流量也按排队延迟调价，而不是空闲衰减
+	c.Tick()
+	if got := c.GetPrice("/mcp"); got != 8 {
+		t.Fatalf("price after an overloaded sample = %d, want 8", got)
+	}
+	c.Tick()
+	if got := c.GetPrice("/mcp"); got != 7 {
+		t.Errorf("price after an idle sample = %d, want 7", got)
+	}
+}
@@ -0,0 +1,135 @@
+// Package deadline 请求截止时间：从请求头解析客户端的超时预算，挂到请求上下文上，
+// 转发给后端时换算成剩余预算重新写回请求头
+package deadline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 支持的超时请求头
+const (
+	// Header 超时预算：Go 时长 ("1.5s"、"300ms")，纯数字按毫秒计
+	Header = "X-Request-Timeout"
+	// GRPCHeader gRPC 风格的超时：最多 8 位整数 + 单位 (H/M/S/m/u/n)
+	GRPCHeader = "Grpc-Timeout"
+)
+
+// grpcUnits grpc-timeout 的单位
+var grpcUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// Parse 读取请求头中的超时预算；两个头都有时取较小的一个，都没有时返回 false
+func Parse(h http.Header) (time.Duration, bool, error) {
+	var timeout time.Duration
+	found := false
+	if v := h.Get(Header); v != "" {
+		d, err := parseTimeout(v)
+		if err != nil {
+			return 0, false, fmt.Errorf("%s: %w", Header, err)
+		}
+		timeout, found = d, true
+	}
+	if v := h.Get(GRPCHeader); v != "" {
+		d, err := parseGRPCTimeout(v)
+		if err != nil {
+			return 0, false, fmt.Errorf("%s: %w", GRPCHeader, err)
+		}
+		if !found || d < timeout {
+			timeout = d
+		}
+		found = true
+	}
+	return timeout, found, nil
+}
+
+func parseTimeout(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		ms, convErr := strconv.ParseInt(v, 10, 64)
+		if convErr != nil {
+			return 0, fmt.Errorf("无法解析超时 %q", v)
+		}
+		d = scale(ms, time.Millisecond)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("超时必须大于 0: %q", v)
+	}
+	return d, nil
+}
+
+func parseGRPCTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("无法解析超时 %q", v)
+	}
+	unit, ok := grpcUnits[v[len(v)-1]]
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("无法解析超时 %q", v)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("超时必须大于 0: %q", v)
+	}
+	return scale(n, unit), nil
+}
+
+// scale 返回 n 个 unit，超出 time.Duration 的范围时截断到最大值 (约 292 年，等同于不限时)
+// 例如 grpc-timeout 的 "99999999H" 直接相乘会溢出成负数
+func scale(n int64, unit time.Duration) time.Duration {
+	if n > math.MaxInt64/int64(unit) {
+		return math.MaxInt64
+	}
+	return time.Duration(n) * unit
+}
+
+// Forward 把剩余预算写回 (转发给后端的) 请求头：总是写 X-Request-Timeout，
+// 客户端用的是 grpc-timeout 时同时改写它；剩余预算向上取整到毫秒，不足 1ms 时按 1ms
+func Forward(h http.Header, remaining time.Duration) {
+	ms := max((remaining+time.Millisecond-1)/time.Millisecond, 1)
+	h.Set(Header, strconv.FormatInt(int64(ms), 10)+"ms")
+	if h.Get(GRPCHeader) != "" {
+		h.Set(GRPCHeader, strconv.FormatInt(int64(ms), 10)+"m")
+	}
+}
+
+// Handler 按请求头给请求上下文设置截止时间，超时头格式错误时返回 400
+// 上游已经设置了更早的截止时间时以上游为准 (context 取较早者)
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok, err := Parse(r.Header)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Reason 请求提前结束的原因：deadline (超时预算用完) / client (客户端断开)；没有结束时返回空
+func Reason(ctx context.Context) string {
+	switch err := ctx.Err(); {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline"
+	default:
+		return "client"
+	}
+}
@@ -0,0 +1,97 @@
+package deadline
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		header, value string
+		want          time.Duration
+	}{
+		{Header, "1.5s", 1500 * time.Millisecond},
+		{Header, "250", 250 * time.Millisecond},
+		{GRPCHeader, "100m", 100 * time.Millisecond},
+		{GRPCHeader, "2S", 2 * time.Second},
+		// 超出 time.Duration 的范围时截断到最大值，而不是溢出成负数
+		{GRPCHeader, "99999999H", math.MaxInt64},
+		{Header, "9223372036854775807", math.MaxInt64},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		h.Set(c.header, c.value)
+		got, ok, err := Parse(h)
+		if err != nil || !ok || got != c.want {
+			t.Errorf("Parse(%s: %s) = %v, %v, %v; want %v", c.header, c.value, got, ok, err, c.want)
+		}
+	}
+
+	for _, bad := range []string{"soon", "0", "-1s"} {
+		h := http.Header{}
+		h.Set(Header, bad)
+		if _, _, err := Parse(h); err == nil {
+			t.Errorf("Parse(%s: %s) succeeded, want an error", Header, bad)
+		}
+	}
+	for _, bad := range []string{"100", "1x", "123456789S", "0m"} {
+		h := http.Header{}
+		h.Set(GRPCHeader, bad)
+		if _, _, err := Parse(h); err == nil {
+			t.Errorf("Parse(%s: %s) succeeded, want an error", GRPCHeader, bad)
+		}
+	}
+
+	// 两个头都有时取较小的预算
+	h := http.Header{}
+	h.Set(Header, "2s")
+	h.Set(GRPCHeader, "500m")
+	if got, _, _ := Parse(h); got != 500*time.Millisecond {
+		t.Errorf("Parse with both headers = %v, want 500ms", got)
+	}
+}
+
+func TestForwardRewritesRemainingBudget(t *testing.T) {
+	h := http.Header{}
+	h.Set(GRPCHeader, "1S")
+	Forward(h, 299500*time.Microsecond)
+	if got := h.Get(Header); got != "300ms" {
+		t.Errorf("%s = %q, want 300ms", Header, got)
+	}
+	if got := h.Get(GRPCHeader); got != "300m" {
+		t.Errorf("%s = %q, want 300m", GRPCHeader, got)
+	}
+
+	h = http.Header{}
+	Forward(h, -time.Second)
+	if got, want := h.Get(Header), "1ms"; got != want || h.Get(GRPCHeader) != "" {
+		t.Errorf("Forward with an expired budget = %q (grpc %q), want %s without grpc-timeout", got, h.Get(GRPCHeader), want)
+	}
+}
+
+func TestHandlerSetsContextDeadline(t *testing.T) {
+	var remaining time.Duration
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dl, ok := r.Context().Deadline(); ok {
+			remaining = time.Until(dl)
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set(Header, "200ms")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if remaining <= 0 || remaining > 200*time.Millisecond {
+		t.Errorf("remaining budget = %v, want (0, 200ms]", remaining)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set(Header, "soon")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed timeout = %d, want 400", rec.Code)
+	}
+}
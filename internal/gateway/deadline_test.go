@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/config"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/metrics"
+	"rajomon-gateway/internal/middleware"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeadlineAdmissionRejectsHopelessRequests(t *testing.T) {
+	a := backend(t, "a")
+	cfg := testConfig(a.URL)
+	cfg.DeadlineAdmission = &config.DeadlineAdmissionConfig{Headroom: 1}
+	gw := newGateway(t, cfg)
+	h := gw.Handler()
+
+	// 该路由最近的请求都要 2s
+	gw.Controller().RecordObservation("/keep", controller.Observation{Latency: 2 * time.Second})
+	rejected := metrics.RequestsTotal.WithLabelValues("rejected_deadline", "/keep")
+	before := testutil.ToFloat64(rejected)
+
+	serve := func(timeout string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/keep", nil)
+		req.Header.Set("Token", "100")
+		if timeout != "" {
+			req.Header.Set("X-Request-Timeout", timeout)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("500ms")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get(middleware.RejectHeader) != middleware.RejectDeadline || rec.Header().Get("X-Predicted-Latency") != "2000ms" {
+		t.Errorf("500ms budget = %d (reject %q, predicted %q), want 429 rejected for deadline with predicted latency 2000ms",
+			rec.Code, rec.Header().Get(middleware.RejectHeader), rec.Header().Get("X-Predicted-Latency"))
+	}
+	if got := testutil.ToFloat64(rejected) - before; got != 1 {
+		t.Errorf("rejected_deadline count = %v, want 1", got)
+	}
+
+	for _, timeout := range []string{"5s", ""} {
+		if rec := serve(timeout); rec.Code != http.StatusOK {
+			t.Errorf("budget %q = %d, want 200", timeout, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"rajomon-gateway/internal/account"
+	"rajomon-gateway/internal/balancer"
+	"rajomon-gateway/internal/config"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/deadline"
+	"rajomon-gateway/internal/handler"
+	"rajomon-gateway/internal/middleware"
+	"rajomon-gateway/internal/trace"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Gateway 按配置组装好的网关：控制器 + 后端池 + 路由
+// 链路: Client -> Rajomon Middleware -> LoadBalancer -> Backend
+//
+// 路由和后端池放在一个不可变的 generation 里，热加载时整体原子替换 (见 reload.go)；
+// 控制器、账户、等待队列、请求追踪和重试预算在整个进程生命周期内只有一份
+type Gateway struct {
+	controller *controller.RajomonController
+
+	ledger   *account.Ledger
+	accounts *account.FileStore // file 存储，为空时不需要落盘
+	queue    *middleware.AdmissionQueue
+	trace    *trace.Recorder
+	opts     []middleware.Option   // 所有路由共享的中间件选项
+	retry    *balancer.RetryConfig // 为空时不重试
+	budget   *balancer.RetryBudget // 所有后端池共用的重试预算 (重试和对冲)
+
+	mu      sync.Mutex // 串行化 Start / Reload
+	current atomic.Pointer[generation]
+	ctx     context.Context // Start 之后非空，后台任务都挂在它下面
+	pricing *pricingTask
+
+	handler http.Handler
+}
+
+// generation 一份配置对应的路由和后端池
+type generation struct {
+	cfg      *config.Config
+	pools    map[string]*pool
+	policies map[string]builtPolicy // Key -> 定价策略，"" 为默认策略
+	mux      *http.ServeMux
+}
+
+// pool 一个后端池及其健康检查
+type pool struct {
+	cfg    config.PoolConfig
+	sink   bool // 是否把后端广播的价格上报给控制器
+	lb     *balancer.SimpleLoadBalancer
+	cancel context.CancelFunc // 健康检查，未启动时为 nil
+}
+
+// builtPolicy 定价策略及其配置 (配置不变时热加载沿用同一个实例)
+type builtPolicy struct {
+	cfg    config.PolicyConfig
+	policy controller.PricingPolicy
+}
+
+// New 根据已校验的配置创建网关 (不启动任何后台任务，见 Start)
+func New(cfg *config.Config) (*Gateway, error) {
+	// 1. 控制器 (定价策略在 commit 时挂载)
+	g := &Gateway{controller: controller.NewController()}
+
+	// 2. 中间件的共享组件 (账户、等待队列、请求追踪)
+	opts, err := g.newMiddlewareOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	g.opts = opts
+	// 不重试时也有一份默认的重试预算：对冲副本从中扣除
+	g.budget = balancer.NewRetryBudget(balancer.DefaultRetryBudgetRatio, balancer.DefaultMinRetries)
+	if r := cfg.Retry; r != nil {
+		g.retry = &balancer.RetryConfig{MaxRetries: r.MaxRetries, SafeMethods: r.SafeMethods}
+		g.budget = balancer.NewRetryBudget(r.Budget, r.MinRetries)
+	}
+
+	// 3. 定价策略、后端池、路由
+	gen, err := g.build(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.commit(nil, gen); err != nil {
+		return nil, err
+	}
+	g.current.Store(gen)
+
+	// 入口固定不变，每个请求使用当时生效的路由
+	g.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.current.Load().mux.ServeHTTP(w, r)
+	})
+	return g, nil
+}
+
+// build 按配置构建一个新的 generation，不修改任何正在使用的状态 (包括按主机的指标)
+// prev 非空时，配置没有变化的定价策略和后端池直接沿用 (保留采样器、EWMA、健康状态)；
+// 重建的后端池沿用同一个 URL 的后端的健康、摘除和熔断状态
+func (g *Gateway) build(cfg *config.Config, prev *generation) (*generation, error) {
+	next := &generation{
+		cfg:      cfg,
+		pools:    make(map[string]*pool),
+		policies: make(map[string]builtPolicy),
+		mux:      http.NewServeMux(),
+	}
+	if prev == nil {
+		prev = &generation{}
+	}
+
+	// 1. 定价策略
+	for key, pc := range policyConfigs(cfg) {
+		if old, ok := prev.policies[key]; ok && reflect.DeepEqual(old.cfg, pc) {
+			next.policies[key] = old
+			continue
+		}
+		policy, err := pc.Build()
+		if err != nil {
+			if key == "" {
+				return nil, fmt.Errorf("pricing.policy: %w", err)
+			}
+			return nil, fmt.Errorf("%s 的定价策略: %w", key, err)
+		}
+		next.policies[key] = builtPolicy{cfg: pc, policy: policy}
+	}
+
+	// 2. 后端池
+	agg := cfg.Pricing.Aggregation
+	sink := agg != "" && agg != controller.AggregateOwn
+	for name, pc := range cfg.Pools {
+		if old, ok := prev.pools[name]; ok && old.sink == sink && reflect.DeepEqual(old.cfg, pc) {
+			next.pools[name] = old
+			continue
+		}
+		lb, err := g.newPool(name, pc, sink)
+		if err != nil {
+			forgetHosts(g.controller, next.pools, prev.pools)
+			return nil, fmt.Errorf("后端池 %s: %w", name, err)
+		}
+		if old, ok := prev.pools[name]; ok {
+			lb.Inherit(old.lb)
+		}
+		next.pools[name] = &pool{cfg: pc, sink: sink, lb: lb}
+	}
+
+	// 3. 路由
+	for _, route := range cfg.Routes {
+		if err := g.addRoute(next, route); err != nil {
+			forgetHosts(g.controller, next.pools, prev.pools)
+			return nil, fmt.Errorf("路由 %s: %w", route.Path, err)
+		}
+	}
+	return next, nil
+}
+
+func (g *Gateway) addRoute(gen *generation, route config.RouteConfig) error {
+	next, err := g.routeHandler(gen, route)
+	if err != nil {
+		return err
+	}
+	keyFunc, err := middleware.KeyFuncByName(route.Key)
+	if err != nil {
+		return err
+	}
+	if route.Key != "" && route.Key != "path" {
+		keyFunc = middleware.AllowKeys(keyFunc, allowedKeys(gen.cfg, route))
+	}
+	routeOpts := append([]middleware.Option{middleware.WithKeyFunc(keyFunc)}, g.opts...)
+	// 超时预算在准入之前生效：排队等待也消耗预算
+	gen.mux.Handle(route.Path, deadline.Handler(middleware.RajomonMiddleware(g.controller, next, routeOpts...)))
+	fmt.Printf("🛣️ 路由 %s -> %s | 定价 Key: %s\n", route.Path, routeTarget(route), keyName(route.Key))
+	return nil
+}
+
+// allowedKeys 路由允许单独定价的派生 Key：allow_keys + pricing.keys / hedge.keys 中属于该路由的 Key
+func allowedKeys(cfg *config.Config, route config.RouteConfig) []string {
+	keys := slices.Clone(route.AllowKeys)
+	for key := range cfg.Pricing.Keys {
+		if strings.HasPrefix(key, route.Path+":") {
+			keys = append(keys, key)
+		}
+	}
+	if route.Hedge != nil {
+		keys = append(keys, route.Hedge.Keys...)
+	}
+	return keys
+}
+
+// policyConfigs 配置中的所有定价策略："" 为默认策略，其余按路由 / 按 Key 覆盖 (A/B 实验)
+func policyConfigs(cfg *config.Config) map[string]config.PolicyConfig {
+	policies := map[string]config.PolicyConfig{"": cfg.Pricing.Policy}
+	for _, route := range cfg.Routes {
+		if route.Pricing != nil {
+			policies[route.Path] = *route.Pricing
+		}
+	}
+	for key, p := range cfg.Pricing.Keys {
+		policies[key] = p
+	}
+	return policies
+}
+
+// Controller 网关使用的控制器
+func (g *Gateway) Controller() *controller.RajomonController {
+	return g.controller
+}
+
+// Config 当前生效的配置
+func (g *Gateway) Config() *config.Config {
+	return g.current.Load().cfg
+}
+
+// Pools 当前生效的后端池 (按名称索引)
+func (g *Gateway) Pools() map[string]*balancer.SimpleLoadBalancer {
+	gen := g.current.Load()
+	pools := make(map[string]*balancer.SimpleLoadBalancer, len(gen.pools))
+	for name, p := range gen.pools {
+		pools[name] = p.lb
+	}
+	return pools
+}
+
+// Handler 业务路由 (热加载后自动切换到新路由)
+func (g *Gateway) Handler() http.Handler {
+	return g.handler
+}
+
+// Start 启动后台任务：健康检查、周期调价/策略采样、账户充值、等待队列
+func (g *Gateway) Start(ctx context.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.ctx = ctx
+	gen := g.current.Load()
+	g.startPools(gen)
+	g.startPricing(gen)
+
+	if g.ledger != nil {
+		g.ledger.StartRefill(ctx)
+	}
+	if g.accounts != nil {
+		go g.accounts.Run(ctx)
+	}
+	if g.queue != nil {
+		g.queue.Start(ctx)
+	}
+}
+
+// Close 关闭需要落盘的组件
+func (g *Gateway) Close() error {
+	var errs []error
+	if g.accounts != nil {
+		errs = append(errs, g.accounts.Flush())
+	}
+	if g.trace != nil {
+		errs = append(errs, g.trace.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (g *Gateway) newPool(name string, pool config.PoolConfig, sink bool) (*balancer.SimpleLoadBalancer, error) {
+	strategy, err := balancer.StrategyByName(pool.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	hc := pool.HealthCheck
+	opts := []balancer.Option{
+		balancer.WithStrategy(strategy),
+		balancer.WithHealthCheck(balancer.HealthCheckConfig{
+			Path:               hc.Path,
+			Interval:           time.Duration(hc.Interval),
+			Timeout:            time.Duration(hc.Timeout),
+			HealthyThreshold:   hc.HealthyThreshold,
+			UnhealthyThreshold: hc.UnhealthyThreshold,
+			EjectThreshold:     hc.EjectThreshold,
+			EjectDuration:      time.Duration(hc.EjectDuration),
+		}),
+	}
+	if br := pool.Breaker; br != nil {
+		opts = append(opts, balancer.WithBreaker(balancer.BreakerConfig{
+			Window:              time.Duration(br.Window),
+			MinRequests:         br.MinRequests,
+			ErrorRate:           br.ErrorRate,
+			ConsecutiveFailures: br.ConsecutiveFailures,
+			OpenDuration:        time.Duration(br.OpenDuration),
+			HalfOpenRequests:    br.HalfOpenRequests,
+		}), balancer.WithPriceSource(g.controller))
+	}
+	if g.retry != nil {
+		opts = append(opts, balancer.WithRetry(*g.retry, g.budget))
+	} else {
+		opts = append(opts, balancer.WithRetryBudget(g.budget))
+	}
+	if sink {
+		opts = append(opts, balancer.WithPriceSink(g.controller))
+	}
+	lb, err := balancer.NewLoadBalancer(pool.Backends, opts...)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("⚖️ 后端池 %s 已就绪，后端节点: %v | 策略: %s\n", name, pool.Backends, strategy.Name())
+	return lb, nil
+}
+
+func (g *Gateway) newMiddlewareOptions(cfg *config.Config) ([]middleware.Option, error) {
+	var opts []middleware.Option
+
+	// 网关侧账户 (可选)
+	if a := cfg.Account; a != nil {
+		var store account.Store
+		if path, ok := strings.CutPrefix(a.Store, "file:"); ok {
+			fileStore, err := account.NewFileStore(path, time.Duration(a.FlushInterval))
+			if err != nil {
+				return nil, fmt.Errorf("account.store: %w", err)
+			}
+			store, g.accounts = fileStore, fileStore
+		} else {
+			store = account.NewMemoryStore()
+		}
+		ledgerCfg := account.DefaultLedgerConfig()
+		ledgerCfg.InitialBalance = a.InitialBalance
+		ledgerCfg.MaxBalance = a.MaxBalance
+		ledgerCfg.Refill = account.RefillPolicy{Dist: a.Refill.Dist, Interval: time.Duration(a.Refill.Interval), Step: a.Refill.Step}
+		g.ledger = account.NewLedger(store, ledgerCfg)
+
+		// 只为配置了 API Key 的客户端开户，不在名单里的旧账户 (吊销的 Key) 一并清理
+		clients := make([]string, 0, len(a.Keys))
+		for client := range a.Keys {
+			clients = append(clients, client)
+		}
+		opened, removed, err := g.ledger.Provision(clients)
+		if err != nil {
+			return nil, fmt.Errorf("account: 开户失败: %w", err)
+		}
+		opts = append(opts, middleware.WithLedger(g.ledger, middleware.APIKeyIdentity(a.Keys)))
+		fmt.Printf("💳 [Ledger] 网关账户已启用 | 存储: %s | 客户端: %d (新开 %d, 清理 %d) | 初始余额: %d | 上限: %d\n",
+			a.Store, len(clients), opened, removed, a.InitialBalance, a.MaxBalance)
+	}
+
+	// 准入等待队列 (可选)：出价不足时排队而不是立即 429
+	if q := cfg.AdmissionQueue; q != nil {
+		g.queue = middleware.NewAdmissionQueue(middleware.AdmissionQueueConfig{
+			MaxDepth:     q.MaxDepth,
+			MaxWait:      time.Duration(q.MaxWait),
+			MaxInFlight:  q.MaxInFlight,
+			PollInterval: time.Duration(q.PollInterval),
+		}, g.controller.GetPrice)
+		opts = append(opts, middleware.WithAdmissionQueue(g.queue))
+		fmt.Printf("🚦 准入等待队列已启用 | 深度: %d | 最长等待: %v\n", q.MaxDepth, q.MaxWait)
+	}
+
+	// 截止时间准入 (可选)：超时预算装不下预测耗时的请求直接拒绝
+	if d := cfg.DeadlineAdmission; d != nil {
+		opts = append(opts, middleware.WithDeadlineAdmission(middleware.NewDeadlineAdmission(middleware.DeadlineAdmissionConfig{
+			Headroom:      d.Headroom,
+			ProbeInterval: time.Duration(d.ProbeInterval),
+		}, g.controller.PredictLatency)))
+		fmt.Printf("⏱️ 截止时间准入已启用 | 余量系数: %g | 探测间隔: %v\n", d.Headroom, d.ProbeInterval)
+	}
+
+	// 请求追踪 (可选)：可用 cmd/replay 回放
+	if t := cfg.Trace; t != nil {
+		recorder, err := trace.OpenFile(t.File)
+		if err != nil {
+			return nil, fmt.Errorf("trace.file: %w", err)
+		}
+		recorder.BodyLimit = t.BodyLimit
+		g.trace = recorder
+		opts = append(opts, middleware.WithTrace(recorder))
+		fmt.Printf("📼 请求追踪已启用 | 文件: %s | 请求体上限: %d 字节\n", t.File, t.BodyLimit)
+	}
+	return opts, nil
+}
+
+// routeHandler 路由的下一跳：后端池 (可按路由覆盖负载均衡策略、开启对冲) 或内置处理器
+func (g *Gateway) routeHandler(gen *generation, route config.RouteConfig) (http.Handler, error) {
+	switch route.Handler {
+	case "context":
+		return http.HandlerFunc(handler.ContextHandler), nil
+	case "":
+	default:
+		return nil, fmt.Errorf("未知的内置处理器 %q", route.Handler)
+	}
+
+	p, ok := gen.pools[route.Pool]
+	if !ok {
+		return nil, fmt.Errorf("未定义的后端池 %q", route.Pool)
+	}
+	lb := p.lb
+	if route.Strategy == "" && route.Hedge == nil {
+		return lb, nil
+	}
+	var strategy balancer.Strategy
+	if route.Strategy != "" {
+		s, err := balancer.StrategyByName(route.Strategy)
+		if err != nil {
+			return nil, err
+		}
+		strategy = s
+	}
+	var opts []balancer.RouteOption
+	if h := route.Hedge; h != nil {
+		opts = append(opts, balancer.WithHedging(balancer.HedgeConfig{
+			Percentile: h.Percentile,
+			MinDelay:   time.Duration(h.MinDelay),
+			MaxPrice:   h.MaxPrice,
+			Keys:       h.Keys,
+		}, g.controller))
+	}
+	return lb.Route(strategy, opts...), nil
+}
+
+func routeTarget(r config.RouteConfig) string {
+	if r.Handler != "" {
+		return "内置处理器 " + r.Handler
+	}
+	target := "后端池 " + r.Pool
+	if r.Strategy != "" {
+		target = fmt.Sprintf("%s (%s)", target, r.Strategy)
+	}
+	if h := r.Hedge; h != nil {
+		target = fmt.Sprintf("%s | 对冲 P%g", target, h.Percentile*100)
+	}
+	return target
+}
+
+func keyName(k string) string {
+	if k == "" {
+		return "path"
+	}
+	return k
+}
@@ -0,0 +1,272 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"rajomon-gateway/internal/config"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/metrics"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reload 重新加载配置 (SIGHUP / 配置文件变化 / 管理接口触发)
+// 新配置无效时保留旧配置继续运行；结果记入 rajomon_config_reload_total 并打日志
+func (g *Gateway) Reload(trigger string, load func() (*config.Config, error)) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	start := time.Now()
+	cfg, err := load()
+	if err == nil {
+		err = g.applyLocked(cfg)
+	}
+	if err != nil {
+		metrics.ConfigReloads.WithLabelValues("failure").Inc()
+		fmt.Printf("❌ [Reload][%s] 新配置无效，继续使用旧配置: %v\n", trigger, err)
+		return err
+	}
+	metrics.ConfigReloads.WithLabelValues("success").Inc()
+	fmt.Printf("🔄 [Reload][%s] 新配置已生效 | 路由: %d | 后端池: %d | 耗时: %v\n",
+		trigger, len(cfg.Routes), len(cfg.Pools), time.Since(start).Round(time.Microsecond))
+	return nil
+}
+
+// Update 在当前配置的副本上修改并生效 (管理接口运行时调参)
+// 注意：运行时的修改不会写回配置文件，下次从文件热加载时会被覆盖
+func (g *Gateway) Update(trigger string, edit func(cfg *config.Config) error) error {
+	return g.Reload(trigger, func() (*config.Config, error) {
+		cfg := g.current.Load().cfg.Clone()
+		if err := edit(cfg); err != nil {
+			return nil, err
+		}
+		return cfg, cfg.Validate()
+	})
+}
+
+// applyLocked 用已校验的配置替换当前的路由和后端池 (调用方需持有 g.mu)
+// 1. 先完整构建新的 generation，任何一步失败都不影响正在运行的旧配置
+// 2. 挂载定价策略，原子切换路由
+// 3. 启停后台任务，清理已删除路由 / 后端的状态；仍然存在的 Key 保留价格和 EWMA
+func (g *Gateway) applyLocked(cfg *config.Config) error {
+	prev := g.current.Load()
+	keepStatic(prev.cfg, cfg)
+
+	// 1. 构建
+	next, err := g.build(cfg, prev)
+	if err != nil {
+		return err
+	}
+
+	// 2. 切换
+	if err := g.commit(prev, next); err != nil {
+		forgetHosts(g.controller, next.pools, prev.pools)
+		return err
+	}
+	g.current.Store(next)
+
+	// 3. 后台任务与状态清理
+	g.startPools(next)
+	for name, p := range prev.pools {
+		if next.pools[name] != p && p.cancel != nil {
+			p.cancel()
+		}
+	}
+	forgetHosts(g.controller, prev.pools, next.pools)
+
+	if removed := g.controller.Retain(next.hasKey); len(removed) > 0 {
+		fmt.Printf("🧹 [Reload] 清理已删除路由的定价状态: %v\n", removed)
+	}
+
+	if !reflect.DeepEqual(prev.cfg.Pricing.UpdateLoop, next.cfg.Pricing.UpdateLoop) ||
+		!reflect.DeepEqual(sampledPolicies(prev), sampledPolicies(next)) {
+		g.stopPricing()
+		g.startPricing(next)
+	}
+	return nil
+}
+
+// commit 把新 generation 的定价策略和聚合方式挂到控制器上，并发布新后端池的指标 (prev 为空表示首次启动)
+func (g *Gateway) commit(prev, next *generation) error {
+	agg := next.cfg.Pricing.Aggregation
+	if agg == "" {
+		agg = controller.AggregateOwn
+	}
+	if err := g.controller.SetAggregation(agg); err != nil {
+		return err
+	}
+	if prev == nil || prev.cfg.Pricing.Aggregation != next.cfg.Pricing.Aggregation {
+		if agg != controller.AggregateOwn {
+			fmt.Printf("🔗 [Controller] 下游价格聚合方式: %s\n", agg)
+		}
+	}
+	if prev == nil {
+		prev = &generation{}
+	}
+
+	// 新建的后端池从这里开始接流量，按主机的指标切换到新池
+	for name, p := range next.pools {
+		if prev.pools[name] != p {
+			p.lb.PublishMetrics()
+		}
+	}
+
+	for key, bp := range next.policies {
+		if prev.policies[key].policy == bp.policy {
+			continue
+		}
+		if key == "" {
+			g.controller.SetDefaultPolicy(bp.policy)
+			fmt.Printf("💰 [Controller] 默认定价策略: %s\n", bp.policy.Name())
+		} else {
+			g.controller.SetPolicy(key, bp.policy)
+			fmt.Printf("🧪 [Controller] %s 使用定价策略: %s\n", key, bp.policy.Name())
+		}
+	}
+	for key := range prev.policies {
+		if _, ok := next.policies[key]; !ok && key != "" {
+			g.controller.ClearPolicy(key)
+			fmt.Printf("🧪 [Controller] %s 不再单独定价，回退到默认策略\n", key)
+		}
+	}
+	return nil
+}
+
+// keepStatic 监听地址和有状态的组件 (账户、等待队列、请求追踪、管理接口) 不支持热加载，沿用旧值
+func keepStatic(old, next *config.Config) {
+	warn := func(field string, changed bool) {
+		if changed {
+			fmt.Printf("⚠️ [Reload] %s 不支持热加载，重启后生效\n", field)
+		}
+	}
+	warn("listen", old.Listen != next.Listen)
+	warn("metrics_listen", old.MetricsListen != next.MetricsListen)
+	warn("admin_listen", old.AdminListen != next.AdminListen)
+	warn("account", !reflect.DeepEqual(old.Account, next.Account))
+	warn("admission_queue", !reflect.DeepEqual(old.AdmissionQueue, next.AdmissionQueue))
+	warn("trace", !reflect.DeepEqual(old.Trace, next.Trace))
+	warn("admin", !reflect.DeepEqual(old.Admin, next.Admin))
+	warn("retry", !reflect.DeepEqual(old.Retry, next.Retry))
+	warn("deadline_admission", !reflect.DeepEqual(old.DeadlineAdmission, next.DeadlineAdmission))
+
+	next.Listen, next.MetricsListen, next.AdminListen = old.Listen, old.MetricsListen, old.AdminListen
+	next.Account, next.AdmissionQueue, next.Trace, next.Admin = old.Account, old.AdmissionQueue, old.Trace, old.Admin
+	next.Retry, next.DeadlineAdmission = old.Retry, old.DeadlineAdmission
+}
+
+// hasKey 定价 Key 在新配置下是否仍然有效：单独配置了策略，或者所属路径仍能匹配到路由
+func (gen *generation) hasKey(key string) bool {
+	if _, ok := gen.policies[key]; ok {
+		return true
+	}
+	path, _, _ := strings.Cut(key, ":")
+	_, pattern := gen.mux.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}})
+	return pattern != ""
+}
+
+// startPools 为还没有启动健康检查的后端池启动健康检查 (Start 之前不做任何事)
+func (g *Gateway) startPools(gen *generation) {
+	if g.ctx == nil {
+		return
+	}
+	for _, p := range gen.pools {
+		if p.cancel != nil {
+			continue
+		}
+		ctx, cancel := context.WithCancel(g.ctx)
+		p.lb.StartHealthCheck(ctx)
+		p.cancel = cancel
+	}
+}
+
+// forgetHosts 清理在 old 中出现、在 next 中已经不存在的后端的下游价格和指标
+func forgetHosts(ctrl *controller.RajomonController, old, next map[string]*pool) {
+	remaining := make(map[string]bool)
+	for _, p := range next {
+		for _, b := range p.lb.Backends() {
+			remaining[b.URL.Host] = true
+		}
+	}
+	for _, p := range old {
+		for _, b := range p.lb.Backends() {
+			host := b.URL.Host
+			if remaining[host] {
+				continue
+			}
+			remaining[host] = true // 同一个后端可能出现在多个池里，只清理一次
+			ctrl.ForgetBackend(host)
+			metrics.BackendHealthy.DeleteLabelValues(host)
+			metrics.BackendEjections.DeleteLabelValues(host)
+			metrics.BreakerState.DeleteLabelValues(host)
+			metrics.BreakerTransitions.DeletePartialMatch(prometheus.Labels{"backend": host})
+		}
+	}
+}
+
+// pricingTask 周期调价循环或策略采样器
+type pricingTask struct {
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// startPricing 按配置启动周期调价循环，或为采样驱动的策略启动采样器 (Start 之前不做任何事)
+func (g *Gateway) startPricing(gen *generation) {
+	if g.ctx == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(g.ctx)
+	task := &pricingTask{cancel: cancel}
+	g.pricing = task
+
+	if l := gen.cfg.Pricing.UpdateLoop; l != nil {
+		// 周期调价循环自己会为采样驱动的策略采样
+		task.done.Add(1)
+		go func() {
+			defer task.done.Done()
+			g.controller.Run(ctx, controller.UpdateLoopConfig{
+				Interval:  time.Duration(l.Interval),
+				DecayStep: l.DecayStep,
+				Floor:     l.Floor,
+				Ceiling:   l.Ceiling,
+			})
+		}()
+		return
+	}
+
+	// 采样驱动的策略 (排队延迟) 需要后台定期采样
+	for sampled := range sampledPolicies(gen) {
+		task.done.Add(1)
+		go func() {
+			defer task.done.Done()
+			g.controller.RunSampler(ctx, sampled)
+		}()
+		fmt.Printf("⏱️ [Controller] %s 策略采样已启动，周期 %v\n", sampled.Name(), sampled.Interval())
+	}
+}
+
+// stopPricing 停止当前的调价任务并等待退出，避免新旧调价循环同时改动控制器的模式
+func (g *Gateway) stopPricing() {
+	if g.pricing == nil {
+		return
+	}
+	g.pricing.cancel()
+	g.pricing.done.Wait()
+	g.pricing = nil
+}
+
+// sampledPolicies 需要后台采样的策略 (同一个实例只出现一次)
+func sampledPolicies(gen *generation) map[controller.SampledPolicy]bool {
+	sampled := make(map[controller.SampledPolicy]bool)
+	for _, bp := range gen.policies {
+		if sp, ok := bp.policy.(controller.SampledPolicy); ok {
+			sampled[sp] = true
+		}
+	}
+	return sampled
+}
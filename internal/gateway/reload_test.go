@@ -0,0 +1,193 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/balancer"
+	"rajomon-gateway/internal/config"
+	"rajomon-gateway/internal/metrics"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// backend 返回固定内容的假后端
+func backend(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testConfig(backends ...string) *config.Config {
+	cfg := config.Default()
+	pool := cfg.Pools["default"]
+	pool.Backends = backends
+	pool.HealthCheck.Interval = 0
+	cfg.Pools["default"] = pool
+	cfg.Routes = []config.RouteConfig{
+		{Path: "/keep", Pool: "default", Key: "path"},
+		{Path: "/drop", Pool: "default", Key: "path"},
+	}
+	return cfg
+}
+
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Token", "100")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func newGateway(t *testing.T, cfg *config.Config) *Gateway {
+	t.Helper()
+	gw, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	gw.Start(ctx)
+	return gw
+}
+
+func TestReloadSwapsRoutesAndKeepsState(t *testing.T) {
+	a, b := backend(t, "a"), backend(t, "b")
+	gw := newGateway(t, testConfig(a.URL))
+	h := gw.Handler()
+
+	for _, path := range []string{"/keep", "/drop"} {
+		if code, body := get(t, h, path); code != http.StatusOK || body != "a" {
+			t.Fatalf("GET %s = %d %q, want 200 \"a\"", path, code, body)
+		}
+	}
+	before, _ := gw.Controller().State("/keep")
+
+	next := testConfig(b.URL)
+	next.Routes = next.Routes[:1]
+	if err := gw.Reload("test", func() (*config.Config, error) { return next, nil }); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	// 仍然存在的 Key 保留价格，被删除路由的 Key 被清理
+	if after, ok := gw.Controller().State("/keep"); !ok || after != before {
+		t.Errorf("state of /keep = %+v (ok=%v), want %+v", after, ok, before)
+	}
+	if _, ok := gw.Controller().State("/drop"); ok {
+		t.Error("state of removed route /drop was kept")
+	}
+
+	if code, body := get(t, h, "/keep"); code != http.StatusOK || body != "b" {
+		t.Errorf("GET /keep after reload = %d %q, want 200 \"b\"", code, body)
+	}
+	if code, _ := get(t, h, "/drop"); code != http.StatusNotFound {
+		t.Errorf("GET /drop after reload = %d, want 404", code)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	a := backend(t, "a")
+	gw := newGateway(t, testConfig(a.URL))
+	h := gw.Handler()
+	failures := testutil.ToFloat64(metrics.ConfigReloads.WithLabelValues("failure"))
+
+	// 加载失败 (如配置文件校验不通过)
+	if err := gw.Reload("test", func() (*config.Config, error) { return nil, errors.New("bad config") }); err == nil {
+		t.Error("Reload with load error = nil, want error")
+	}
+
+	// 构建失败：路由引用了不存在的后端池
+	bad := testConfig(a.URL)
+	bad.Routes = []config.RouteConfig{{Path: "/other", Pool: "missing"}}
+	if err := gw.Reload("test", func() (*config.Config, error) { return bad, nil }); err == nil {
+		t.Error("Reload with undefined pool = nil, want error")
+	}
+
+	if got := testutil.ToFloat64(metrics.ConfigReloads.WithLabelValues("failure")) - failures; got != 2 {
+		t.Errorf("failure reloads = %v, want 2", got)
+	}
+	if code, body := get(t, h, "/keep"); code != http.StatusOK || body != "a" {
+		t.Errorf("GET /keep after failed reload = %d %q, want old route", code, body)
+	}
+	if gw.Config().Routes[0].Path != "/keep" {
+		t.Errorf("config after failed reload = %+v, want old config", gw.Config().Routes)
+	}
+}
+
+func TestReloadKeepsStaticFields(t *testing.T) {
+	a := backend(t, "a")
+	gw := newGateway(t, testConfig(a.URL))
+
+	next := testConfig(a.URL)
+	next.Listen = ":9999"
+	if err := gw.Reload("test", func() (*config.Config, error) { return next, nil }); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := gw.Config().Listen; got != ":8080" {
+		t.Errorf("listen after reload = %q, want %q", got, ":8080")
+	}
+}
+
+func TestReloadKeepsBackendState(t *testing.T) {
+	a := backend(t, "a")
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	cfg := testConfig(a.URL, dead.URL)
+	pool := cfg.Pools["default"]
+	pool.HealthCheck.EjectThreshold, pool.HealthCheck.EjectDuration = 1, config.Duration(time.Hour)
+	pool.Breaker = &config.BreakerConfig{Window: config.Duration(10 * time.Second), ConsecutiveFailures: 1, OpenDuration: config.Duration(time.Hour), HalfOpenRequests: 1}
+	cfg.Pools["default"] = pool
+	gw := newGateway(t, cfg)
+	deadHost := gw.Pools()["default"].Backends()[1].URL.Host
+	healthy := metrics.BackendHealthy.WithLabelValues(deadHost)
+
+	// 轮询到已经下线的后端：被动摘除并熔断
+	for range 2 {
+		get(t, gw.Handler(), "/keep")
+	}
+	if b := gw.Pools()["default"].Backends()[1]; b.State() != balancer.StateEjected || b.BreakerState() != balancer.BreakerOpen {
+		t.Fatalf("dead backend = %s / breaker %s, want ejected with an open breaker", b.State(), b.BreakerState())
+	}
+
+	// 构建失败的配置不能碰共享的按主机指标
+	bad := cfg.Clone()
+	pool = bad.Pools["default"]
+	pool.Strategy = "least_outstanding"
+	bad.Pools["default"] = pool
+	bad.Routes = append(bad.Routes, config.RouteConfig{Path: "/other", Pool: "missing"})
+	if err := gw.Reload("test", func() (*config.Config, error) { return bad, nil }); err == nil {
+		t.Fatal("Reload with undefined pool = nil, want error")
+	}
+	if got := testutil.ToFloat64(healthy); got != 0 {
+		t.Errorf("backend_healthy after a failed reload = %v, want 0", got)
+	}
+
+	// 后端池重建：同一个 URL 的后端沿用摘除和熔断状态
+	next := cfg.Clone()
+	pool = next.Pools["default"]
+	pool.Strategy = "least_outstanding"
+	next.Pools["default"] = pool
+	if err := gw.Reload("test", func() (*config.Config, error) { return next, nil }); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	b := gw.Pools()["default"].Backends()[1]
+	if b.State() != balancer.StateEjected || b.BreakerState() != balancer.BreakerOpen {
+		t.Errorf("dead backend after reload = %s / breaker %s, want still ejected with an open breaker", b.State(), b.BreakerState())
+	}
+	if got := testutil.ToFloat64(healthy); got != 0 {
+		t.Errorf("backend_healthy after reload = %v, want 0", got)
+	}
+	for range 4 {
+		if code, body := get(t, gw.Handler(), "/keep"); code != http.StatusOK || body != "a" {
+			t.Errorf("GET /keep after reload = %d %q, want every request on the live backend", code, body)
+		}
+	}
+}
@@ -35,20 +35,24 @@ func HandleMCP(w http.ResponseWriter, r *http.Request) {
 	// 3. 模拟分段输出内容 (Chunks)
 	chunks := []string{"你好，", "这是一个", "基于", "Rajomon", "治理的", "模拟", "AI回复。"}
 
-	// [新增] 预计算 Token (为了给中间件透传数据)
-	// 在真实场景中，可能是在流结束时通过 HTTP Trailers 发送，
-	// 但为了简化，我们在 Mock 时预先算好放在 Header 里
-	totalPrompt := 20
-	totalCompletion := len(chunks) * 2 // 假设每个 chunk 2 token
-	totalUsage := totalPrompt + totalCompletion
+	// 像真实 LLM 一样，用量只有在流结束时才知道：
+	// 网关会从 "event: usage" 帧中解析，同时通过 HTTP Trailer 再发一份
+	w.Header().Set("Trailer", "X-Token-Usage")
 
-	// 🔥 [关键] 写入侧信道 Header，中间件会读这个！
-	w.Header().Set("X-Token-Usage", fmt.Sprintf("%d", totalUsage))
+	totalPrompt := 20
+	totalCompletion := 0
 
+	ctx := r.Context()
 	for _, text := range chunks {
 		// 模拟思考延迟 (制造抖动，方便后续测试 Rajomon 的 EWMA 算法)
+		// 客户端断开或超时预算用完 (ctx 结束) 时立即停止生成
 		delay := time.Duration(rand.Intn(100)+50) * time.Millisecond
-		time.Sleep(delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			fmt.Printf("[Mock LLM] 生成被中断 (已生成 %d tokens): %v\n", totalCompletion, ctx.Err())
+			return
+		}
 
 		// 构造数据
 		respData := model.MockContent{Content: text}
@@ -56,7 +60,9 @@ func HandleMCP(w http.ResponseWriter, r *http.Request) {
 
 		// 🚀 立即推送给客户端
 		flusher.Flush()
+		totalCompletion += 2 // 假设每个 chunk 2 token
 	}
+	totalUsage := totalPrompt + totalCompletion
 
 	// 4. 发送最终的 Token Usage (这是 Rajomon 定价的关键依据)
 	usageData := model.MockUsage{
@@ -67,6 +73,9 @@ func HandleMCP(w http.ResponseWriter, r *http.Request) {
 	sendSSE(w, "usage", usageData)
 	flusher.Flush()
 
+	// 流结束后写入 Trailer
+	w.Header().Set("X-Token-Usage", fmt.Sprintf("%d", totalUsage))
+
 	fmt.Printf("[Mock LLM] 响应结束. 总消耗 Tokens: %d\n", usageData.TotalTokens)
 }
 
@@ -0,0 +1,609 @@
+// MCP over Streamable HTTP 的模拟服务端
+//
+// 单一端点 (如 /mcp) 同时处理:
+//   POST   JSON-RPC 请求/通知/批量请求；含请求时按 Accept 返回 application/json 或 text/event-stream
+//   GET    服务端主动推送流 (本 Mock 不支持，返回 405)
+//   DELETE 终止会话
+// initialize 成功后通过 Mcp-Session-Id 头下发会话 ID，后续请求必须携带；
+// 收到 notifications/initialized 之前只接受 ping。空闲超时或超过会话上限的会话会被回收 (之后返回 404，客户端需重新 initialize)。
+
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"os"
+	"rajomon-gateway/internal/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolSpec 一个模拟工具的行为配置
+type ToolSpec struct {
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	InputSchema   json.RawMessage `json:"input_schema,omitempty"`
+	LatencyMs     int             `json:"latency_ms"`      // 基础耗时
+	JitterMs      int             `json:"jitter_ms"`       // 随机抖动 (0 ~ jitter)
+	Tokens        int             `json:"tokens"`          // 每次调用消耗的 Token
+	Chunks        int             `json:"chunks"`          // 流式模式下分几段推送进度
+	ErrorRate     float64         `json:"error_rate"`      // 工具执行失败 (isError=true) 的概率
+	HTTPErrorRate float64         `json:"http_error_rate"` // 直接返回 HTTP 500 的概率 (模拟后端崩溃)
+}
+
+// ResourceSpec 一个模拟资源
+type ResourceSpec struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mime_type"`
+	Text        string `json:"text"`
+}
+
+// PromptSpec 一个模拟提示词模板
+type PromptSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Template    string `json:"template"`
+}
+
+// MCPServerConfig 模拟 MCP 服务端的配置
+type MCPServerConfig struct {
+	Tools     []ToolSpec     `json:"tools"`
+	Resources []ResourceSpec `json:"resources"`
+	Prompts   []PromptSpec   `json:"prompts"`
+
+	SessionIdleMs int `json:"session_idle_ms"` // 会话空闲多久后过期 (0 表示默认 10 分钟)
+	MaxSessions   int `json:"max_sessions"`    // 最多保留多少个会话，超过时回收最久未使用的 (0 表示默认 10000)
+}
+
+// 会话回收的默认参数
+const (
+	defaultSessionIdle = 10 * time.Minute
+	defaultMaxSessions = 10000
+)
+
+// DefaultMCPServerConfig 默认提供一快一慢两个工具，方便观察按工具定价
+func DefaultMCPServerConfig() MCPServerConfig {
+	return MCPServerConfig{
+		Tools: []ToolSpec{
+			{Name: "echo", Description: "原样返回输入 (廉价工具)", LatencyMs: 20, JitterMs: 10, Tokens: 5, Chunks: 1},
+			{Name: "web_search", Description: "模拟联网搜索 (慢工具)", LatencyMs: 300, JitterMs: 200, Tokens: 120, Chunks: 3, ErrorRate: 0.02},
+			{Name: "llm_generate", Description: "模拟大模型生成 (昂贵工具)", LatencyMs: 700, JitterMs: 300, Tokens: 800, Chunks: 7},
+		},
+		Resources: []ResourceSpec{
+			{URI: "file:///docs/rajomon.md", Name: "rajomon.md", Description: "Rajomon 简介", MimeType: "text/markdown",
+				Text: "# Rajomon\n基于价格的去中心化过载控制。"},
+		},
+		Prompts: []PromptSpec{
+			{Name: "summarize", Description: "总结一段文本", Template: "请总结以下内容: {{text}}"},
+		},
+	}
+}
+
+// LoadMCPServerConfig 从 JSON 文件加载配置
+func LoadMCPServerConfig(path string) (MCPServerConfig, error) {
+	var cfg MCPServerConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("读取 MCP 配置失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("解析 MCP 配置 %s 失败: %w", path, err)
+	}
+	for i, tool := range cfg.Tools {
+		if tool.Name == "" {
+			return cfg, fmt.Errorf("MCP 配置 %s: tools[%d] 缺少 name", path, i)
+		}
+	}
+	return cfg, nil
+}
+
+// mcpSession 一个 MCP 会话
+type mcpSession struct {
+	lastSeen    time.Time // 最近一次使用时间，用于空闲过期和超限回收
+	initialized bool      // 是否已收到 notifications/initialized
+}
+
+// MCPServer 模拟的 MCP 服务端 (Streamable HTTP)
+type MCPServer struct {
+	cfg         MCPServerConfig
+	tools       map[string]ToolSpec
+	idle        time.Duration
+	maxSessions int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*mcpSession
+}
+
+func NewMCPServer(cfg MCPServerConfig) *MCPServer {
+	tools := make(map[string]ToolSpec, len(cfg.Tools))
+	for _, tool := range cfg.Tools {
+		tools[tool.Name] = tool
+	}
+	s := &MCPServer{
+		cfg:         cfg,
+		tools:       tools,
+		idle:        time.Duration(cfg.SessionIdleMs) * time.Millisecond,
+		maxSessions: cfg.MaxSessions,
+		now:         time.Now,
+		sessions:    make(map[string]*mcpSession),
+	}
+	if s.idle <= 0 {
+		s.idle = defaultSessionIdle
+	}
+	if s.maxSessions <= 0 {
+		s.maxSessions = defaultMaxSessions
+	}
+	return s
+}
+
+func (s *MCPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		// GET 用于服务端主动推送，本 Mock 没有主动推送的消息
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *MCPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(model.MCPSessionHeader)
+	s.mu.Lock()
+	_, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	fmt.Printf("[Mock MCP] 会话已终止: %s\n", id)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *MCPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	// 1. 解析消息 (单条或批量)
+	msgs, batch, err := decodeJSONRPC(r)
+	if err != nil {
+		writeJSONRPCError(w, http.StatusBadRequest, nil, model.JSONRPCParseError, err.Error())
+		return
+	}
+
+	// 2. 会话校验：initialize 创建会话，其余消息必须携带有效的会话 ID
+	sessionID := r.Header.Get(model.MCPSessionHeader)
+	if len(msgs) == 1 && msgs[0].Method == "initialize" {
+		sessionID = s.newSession()
+		w.Header().Set(model.MCPSessionHeader, sessionID)
+	} else if sessionID == "" {
+		writeJSONRPCError(w, http.StatusBadRequest, nil, model.JSONRPCInvalidRequest, "missing "+model.MCPSessionHeader)
+		return
+	} else if !s.touchSession(sessionID) {
+		writeJSONRPCError(w, http.StatusNotFound, nil, model.JSONRPCInvalidRequest, "session not found")
+		return
+	}
+
+	// 3. 只有通知 (或客户端回给服务端的响应) 时返回 202，无响应体
+	var requests []*model.JSONRPCRequest
+	for _, msg := range msgs {
+		if msg.IsNotification() {
+			s.handleNotification(sessionID, msg)
+		} else if msg.Method != "" {
+			requests = append(requests, msg)
+		}
+	}
+	if len(requests) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// 3.1 握手完成 (notifications/initialized) 之前只接受 ping
+	if !s.ready(sessionID, requests) {
+		writeJSONRPCError(w, http.StatusBadRequest, requests[0].ID, model.JSONRPCInvalidRequest, "session not initialized")
+		return
+	}
+
+	// 4. 模拟后端崩溃
+	for _, req := range requests {
+		if tool, ok := s.toolFor(req); ok && mrand.Float64() < tool.HTTPErrorRate {
+			fmt.Printf("[Mock MCP] 💥 模拟后端故障: %s\n", tool.Name)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// 5. 客户端接受 SSE 时以流的形式返回 (进度通知 + usage 帧 + 最终结果)，否则一次性返回 JSON
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		s.streamResponses(w, r, requests)
+	} else {
+		s.jsonResponses(w, r, requests, batch)
+	}
+}
+
+// jsonResponses 一次性返回 JSON；用量在写响应前就已确定，可以直接放进 X-Token-Usage 头
+func (s *MCPServer) jsonResponses(w http.ResponseWriter, r *http.Request, requests []*model.JSONRPCRequest, batch bool) {
+	responses := make([]*model.JSONRPCResponse, 0, len(requests))
+	totalTokens := 0
+	for _, req := range requests {
+		resp, tokens := s.dispatch(r.Context(), req, nil)
+		responses = append(responses, resp)
+		totalTokens += tokens
+	}
+	if r.Context().Err() != nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if totalTokens > 0 {
+		w.Header().Set("X-Token-Usage", fmt.Sprintf("%d", totalTokens))
+	}
+	if batch {
+		json.NewEncoder(w).Encode(responses)
+	} else {
+		json.NewEncoder(w).Encode(responses[0])
+	}
+}
+
+// streamResponses 以 SSE 返回：每条 JSON-RPC 消息是一个 "event: message"，
+// 全部结束前再发一个 "event: usage" 帧，供网关统计真实 Token 消耗
+func (s *MCPServer) streamResponses(w http.ResponseWriter, r *http.Request, requests []*model.JSONRPCRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Trailer", "X-Token-Usage")
+	w.WriteHeader(http.StatusOK)
+
+	notify := func(n *model.JSONRPCNotification) {
+		sendSSE(w, "message", n)
+		flusher.Flush()
+	}
+
+	totalTokens := 0
+	for _, req := range requests {
+		resp, tokens := s.dispatch(r.Context(), req, notify)
+		if r.Context().Err() != nil {
+			return
+		}
+		totalTokens += tokens
+		if tokens > 0 {
+			sendSSE(w, "usage", model.MockUsage{CompletionTokens: tokens, TotalTokens: tokens})
+		}
+		sendSSE(w, "message", resp)
+		flusher.Flush()
+	}
+	w.Header().Set("X-Token-Usage", fmt.Sprintf("%d", totalTokens))
+}
+
+// dispatch 执行一个 JSON-RPC 请求，返回响应和消耗的 Token
+// notify 非空时可以推送进度通知
+func (s *MCPServer) dispatch(ctx context.Context, req *model.JSONRPCRequest, notify func(*model.JSONRPCNotification)) (*model.JSONRPCResponse, int) {
+	resp := &model.JSONRPCResponse{JSONRPC: model.JSONRPCVersion, ID: req.ID}
+	tokens := 0
+	var err *model.JSONRPCError
+
+	switch req.Method {
+	case "initialize":
+		resp.Result, err = s.initialize(req)
+	case "ping":
+		resp.Result = struct{}{}
+	case "tools/list":
+		resp.Result = s.listTools()
+	case "tools/call":
+		resp.Result, tokens, err = s.callTool(ctx, req, notify)
+	case "resources/list":
+		resp.Result = s.listResources()
+	case "resources/read":
+		resp.Result, err = s.readResource(req)
+	case "prompts/list":
+		resp.Result = s.listPrompts()
+	case "prompts/get":
+		resp.Result, err = s.getPrompt(req)
+	default:
+		err = &model.JSONRPCError{Code: model.JSONRPCMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	if err != nil {
+		resp.Result = nil
+		resp.Error = err
+	}
+	return resp, tokens
+}
+
+func (s *MCPServer) handleNotification(sessionID string, msg *model.JSONRPCRequest) {
+	switch msg.Method {
+	case "notifications/initialized":
+		s.mu.Lock()
+		if sess, ok := s.sessions[sessionID]; ok {
+			sess.initialized = true
+		}
+		s.mu.Unlock()
+		fmt.Printf("[Mock MCP] 会话初始化完成: %s\n", sessionID)
+	case "notifications/cancelled":
+		// 客户端取消请求：本 Mock 依赖 HTTP 连接断开 (ctx 取消) 来中断处理
+	}
+}
+
+func (s *MCPServer) initialize(req *model.JSONRPCRequest) (interface{}, *model.JSONRPCError) {
+	var params model.MCPInitializeParams
+	if err := unmarshalParams(req, &params); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[Mock MCP] 新会话: client=%s/%s protocol=%s\n",
+		params.ClientInfo.Name, params.ClientInfo.Version, params.ProtocolVersion)
+	return model.MCPInitializeResult{
+		ProtocolVersion: model.MCPProtocolVersion,
+		Capabilities: map[string]interface{}{
+			"tools":     map[string]interface{}{"listChanged": false},
+			"resources": map[string]interface{}{"listChanged": false, "subscribe": false},
+			"prompts":   map[string]interface{}{"listChanged": false},
+		},
+		ServerInfo: model.MCPImplementation{Name: "rajomon-mock-mcp", Version: "0.1.0"},
+	}, nil
+}
+
+func (s *MCPServer) listTools() interface{} {
+	tools := make([]model.MCPTool, 0, len(s.cfg.Tools))
+	for _, spec := range s.cfg.Tools {
+		schema := spec.InputSchema
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		tools = append(tools, model.MCPTool{Name: spec.Name, Description: spec.Description, InputSchema: schema})
+	}
+	return map[string]interface{}{"tools": tools}
+}
+
+// callTool 按工具配置模拟耗时、Token 消耗和错误
+func (s *MCPServer) callTool(ctx context.Context, req *model.JSONRPCRequest, notify func(*model.JSONRPCNotification)) (interface{}, int, *model.JSONRPCError) {
+	var params model.MCPCallToolParams
+	if err := unmarshalParams(req, &params); err != nil {
+		return nil, 0, err
+	}
+	tool, ok := s.tools[params.Name]
+	if !ok {
+		return nil, 0, &model.JSONRPCError{Code: model.JSONRPCInvalidParams, Message: "unknown tool: " + params.Name}
+	}
+
+	// 1. 模拟耗时，分段推送进度；客户端断开 (ctx 取消) 时立即停止
+	chunks := tool.Chunks
+	if chunks < 1 {
+		chunks = 1
+	}
+	delay := time.Duration(tool.LatencyMs) * time.Millisecond
+	if tool.JitterMs > 0 {
+		delay += time.Duration(mrand.Intn(tool.JitterMs)) * time.Millisecond
+	}
+	for i := 1; i <= chunks; i++ {
+		select {
+		case <-time.After(delay / time.Duration(chunks)):
+		case <-ctx.Done():
+			fmt.Printf("[Mock MCP] 工具 %s 被中断: %v\n", tool.Name, ctx.Err())
+			return nil, 0, &model.JSONRPCError{Code: model.JSONRPCInternalError, Message: ctx.Err().Error()}
+		}
+		if notify != nil && params.Meta != nil && params.Meta.ProgressToken != nil {
+			notify(&model.JSONRPCNotification{
+				JSONRPC: model.JSONRPCVersion,
+				Method:  "notifications/progress",
+				Params:  model.MCPProgressParams{ProgressToken: params.Meta.ProgressToken, Progress: i, Total: chunks},
+			})
+		}
+	}
+
+	// 2. 工具执行失败：按 MCP 规范放在结果里 (isError)，而不是 JSON-RPC 错误
+	usage := model.MockUsage{CompletionTokens: tool.Tokens, TotalTokens: tool.Tokens}
+	if mrand.Float64() < tool.ErrorRate {
+		return model.MCPCallToolResult{
+			Content: []model.MCPContent{{Type: "text", Text: fmt.Sprintf("tool %s failed (simulated)", tool.Name)}},
+			IsError: true,
+			Meta:    map[string]interface{}{"usage": usage},
+		}, tool.Tokens, nil
+	}
+
+	fmt.Printf("[Mock MCP] 工具 %s 完成 | 耗时 %v | Tokens %d\n", tool.Name, delay, tool.Tokens)
+	args, _ := json.Marshal(params.Arguments)
+	return model.MCPCallToolResult{
+		Content: []model.MCPContent{{Type: "text", Text: fmt.Sprintf("%s(%s) ok", tool.Name, args)}},
+		Meta:    map[string]interface{}{"usage": usage},
+	}, tool.Tokens, nil
+}
+
+func (s *MCPServer) listResources() interface{} {
+	resources := make([]model.MCPResource, 0, len(s.cfg.Resources))
+	for _, spec := range s.cfg.Resources {
+		resources = append(resources, model.MCPResource{URI: spec.URI, Name: spec.Name, Description: spec.Description, MimeType: spec.MimeType})
+	}
+	return map[string]interface{}{"resources": resources}
+}
+
+func (s *MCPServer) readResource(req *model.JSONRPCRequest) (interface{}, *model.JSONRPCError) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := unmarshalParams(req, &params); err != nil {
+		return nil, err
+	}
+	for _, spec := range s.cfg.Resources {
+		if spec.URI == params.URI {
+			return map[string]interface{}{
+				"contents": []model.MCPResourceContents{{URI: spec.URI, MimeType: spec.MimeType, Text: spec.Text}},
+			}, nil
+		}
+	}
+	return nil, &model.JSONRPCError{Code: model.JSONRPCInvalidParams, Message: "resource not found: " + params.URI}
+}
+
+func (s *MCPServer) listPrompts() interface{} {
+	prompts := make([]model.MCPPrompt, 0, len(s.cfg.Prompts))
+	for _, spec := range s.cfg.Prompts {
+		prompts = append(prompts, model.MCPPrompt{Name: spec.Name, Description: spec.Description})
+	}
+	return map[string]interface{}{"prompts": prompts}
+}
+
+func (s *MCPServer) getPrompt(req *model.JSONRPCRequest) (interface{}, *model.JSONRPCError) {
+	var params struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := unmarshalParams(req, &params); err != nil {
+		return nil, err
+	}
+	for _, spec := range s.cfg.Prompts {
+		if spec.Name == params.Name {
+			text := spec.Template
+			for k, v := range params.Arguments {
+				text = strings.ReplaceAll(text, "{{"+k+"}}", v)
+			}
+			return map[string]interface{}{
+				"description": spec.Description,
+				"messages":    []model.MCPPromptMessage{{Role: "user", Content: model.MCPContent{Type: "text", Text: text}}},
+			}, nil
+		}
+	}
+	return nil, &model.JSONRPCError{Code: model.JSONRPCInvalidParams, Message: "prompt not found: " + params.Name}
+}
+
+// toolFor tools/call 请求对应的工具配置
+func (s *MCPServer) toolFor(req *model.JSONRPCRequest) (ToolSpec, bool) {
+	if req.Method != "tools/call" {
+		return ToolSpec{}, false
+	}
+	var params model.MCPCallToolParams
+	if json.Unmarshal(req.Params, &params) != nil {
+		return ToolSpec{}, false
+	}
+	tool, ok := s.tools[params.Name]
+	return tool, ok
+}
+
+// newSession 创建会话；先回收过期会话，仍然超过上限时回收最久未使用的
+func (s *MCPServer) newSession() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if len(s.sessions) >= s.maxSessions {
+		s.expireLocked(now)
+	}
+	for len(s.sessions) >= s.maxSessions {
+		s.evictOldestLocked()
+	}
+	s.sessions[id] = &mcpSession{lastSeen: now}
+	return id
+}
+
+// touchSession 会话存在且未过期时刷新使用时间
+func (s *MCPServer) touchSession(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+	now := s.now()
+	if now.Sub(sess.lastSeen) > s.idle {
+		delete(s.sessions, id)
+		fmt.Printf("[Mock MCP] 会话空闲超时: %s\n", id)
+		return false
+	}
+	sess.lastSeen = now
+	return true
+}
+
+// ready 会话已完成握手，或者本次只有 ping
+func (s *MCPServer) ready(id string, requests []*model.JSONRPCRequest) bool {
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	initialized := ok && sess.initialized
+	s.mu.Unlock()
+	if initialized {
+		return true
+	}
+	for _, req := range requests {
+		if req.Method != "initialize" && req.Method != "ping" {
+			return false
+		}
+	}
+	return true
+}
+
+// expireLocked 回收所有空闲超时的会话 (调用方需持有 s.mu)
+func (s *MCPServer) expireLocked(now time.Time) {
+	for id, sess := range s.sessions {
+		if now.Sub(sess.lastSeen) > s.idle {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// evictOldestLocked 回收最久未使用的会话 (调用方需持有 s.mu)
+func (s *MCPServer) evictOldestLocked() {
+	var oldest string
+	var oldestSeen time.Time
+	for id, sess := range s.sessions {
+		if oldest == "" || sess.lastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = id, sess.lastSeen
+		}
+	}
+	delete(s.sessions, oldest)
+	fmt.Printf("[Mock MCP] 会话数达到上限 %d，回收最久未使用的会话: %s\n", s.maxSessions, oldest)
+}
+
+// decodeJSONRPC 解析请求体：单条消息或批量数组
+func decodeJSONRPC(r *http.Request) ([]*model.JSONRPCRequest, bool, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return nil, false, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if len(raw) > 0 && raw[0] == '[' {
+		var msgs []*model.JSONRPCRequest
+		if err := json.Unmarshal(raw, &msgs); err != nil {
+			return nil, true, fmt.Errorf("invalid JSON-RPC batch: %w", err)
+		}
+		if len(msgs) == 0 {
+			return nil, true, fmt.Errorf("empty JSON-RPC batch")
+		}
+		return msgs, true, nil
+	}
+	var msg model.JSONRPCRequest
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, false, fmt.Errorf("invalid JSON-RPC message: %w", err)
+	}
+	return []*model.JSONRPCRequest{&msg}, false, nil
+}
+
+func unmarshalParams(req *model.JSONRPCRequest, v interface{}) *model.JSONRPCError {
+	if len(req.Params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return &model.JSONRPCError{Code: model.JSONRPCInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func writeJSONRPCError(w http.ResponseWriter, status int, id json.RawMessage, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(model.JSONRPCResponse{
+		JSONRPC: model.JSONRPCVersion,
+		ID:      id,
+		Error:   &model.JSONRPCError{Code: code, Message: message},
+	})
+}
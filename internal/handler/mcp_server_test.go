@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestMCPServer 一个没有耗时、不会出错的 echo 工具 (分两段推送进度，消耗 5 个 Token)
+func newTestMCPServer(cfg MCPServerConfig) *MCPServer {
+	cfg.Tools = []ToolSpec{{Name: "echo", Tokens: 5, Chunks: 2}}
+	return NewMCPServer(cfg)
+}
+
+// post 发送一条 JSON-RPC 消息，session 为空时不带会话头
+func post(s *MCPServer, session, accept, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if session != "" {
+		req.Header.Set(model.MCPSessionHeader, session)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+// handshake initialize + notifications/initialized，返回会话 ID
+func handshake(t *testing.T, s *MCPServer) string {
+	t.Helper()
+	rec := post(s, "", "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"test","version":"1"}}}`)
+	session := rec.Header().Get(model.MCPSessionHeader)
+	if rec.Code != http.StatusOK || session == "" {
+		t.Fatalf("initialize = %d with session %q, want 200 with a session", rec.Code, session)
+	}
+	if rec := post(s, session, "", `{"jsonrpc":"2.0","method":"notifications/initialized"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("notifications/initialized = %d, want 202", rec.Code)
+	}
+	return session
+}
+
+func decodeResponse(t *testing.T, body string) model.JSONRPCResponse {
+	t.Helper()
+	var resp model.JSONRPCResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("decode %q: %v", body, err)
+	}
+	return resp
+}
+
+func TestMCPProtocol(t *testing.T) {
+	const call = `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"},"_meta":{"progressToken":"p1"}}}`
+
+	t.Run("json", func(t *testing.T) {
+		s := newTestMCPServer(MCPServerConfig{})
+		session := handshake(t, s)
+
+		rec := post(s, session, "application/json", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"echo"`) {
+			t.Fatalf("tools/list = %d %s, want 200 listing echo", rec.Code, rec.Body)
+		}
+
+		rec = post(s, session, "application/json", call)
+		resp := decodeResponse(t, rec.Body.String())
+		if rec.Code != http.StatusOK || resp.Error != nil || string(resp.ID) != "3" {
+			t.Fatalf("tools/call = %d %s, want 200 with a result for id 3", rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("X-Token-Usage"); got != "5" {
+			t.Errorf("X-Token-Usage = %q, want 5", got)
+		}
+	})
+
+	t.Run("sse", func(t *testing.T) {
+		s := newTestMCPServer(MCPServerConfig{})
+		session := handshake(t, s)
+
+		rec := post(s, session, "application/json, text/event-stream", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "event: message\ndata: ") || !strings.Contains(rec.Body.String(), `"name":"echo"`) {
+			t.Fatalf("tools/list over SSE = %d %s, want a message event listing echo", rec.Code, rec.Body)
+		}
+
+		rec = post(s, session, "application/json, text/event-stream", call)
+		body := rec.Body.String()
+		if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+		}
+		if n := strings.Count(body, `"method":"notifications/progress"`); n != 2 {
+			t.Errorf("progress notifications = %d, want 2 (one per chunk)\n%s", n, body)
+		}
+		usage := strings.Index(body, "event: usage\ndata: ")
+		result := strings.Index(body, `"id":3`)
+		if usage < 0 || result < 0 || usage > result {
+			t.Errorf("want a usage frame before the final result\n%s", body)
+		}
+		if got := rec.Result().Trailer.Get("X-Token-Usage"); got != "5" {
+			t.Errorf("X-Token-Usage trailer = %q, want 5", got)
+		}
+	})
+}
+
+func TestMCPRequiresInitializedNotification(t *testing.T) {
+	s := newTestMCPServer(MCPServerConfig{})
+	rec := post(s, "", "", `{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+	session := rec.Header().Get(model.MCPSessionHeader)
+
+	rec = post(s, session, "", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if resp := decodeResponse(t, rec.Body.String()); rec.Code != http.StatusBadRequest || resp.Error == nil || string(resp.ID) != "2" {
+		t.Errorf("tools/list before initialized = %d %s, want 400 with an error for id 2", rec.Code, rec.Body)
+	}
+	if rec := post(s, session, "", `{"jsonrpc":"2.0","id":3,"method":"ping"}`); rec.Code != http.StatusOK {
+		t.Errorf("ping before initialized = %d, want 200", rec.Code)
+	}
+
+	// 同一批里先发 initialized 通知再发请求也可以
+	rec = post(s, session, "", `[{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":4,"method":"tools/list"}]`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("batch with initialized + tools/list = %d %s, want 200", rec.Code, rec.Body)
+	}
+}
+
+func TestMCPSessionHeader(t *testing.T) {
+	s := newTestMCPServer(MCPServerConfig{})
+	session := handshake(t, s)
+	const list = `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+
+	if rec := post(s, "", "", list); rec.Code != http.StatusBadRequest {
+		t.Errorf("request without %s = %d, want 400", model.MCPSessionHeader, rec.Code)
+	}
+	if rec := post(s, "not-a-session", "", list); rec.Code != http.StatusNotFound {
+		t.Errorf("request with an unknown session = %d, want 404", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/mcp", nil)
+	req.Header.Set(model.MCPSessionHeader, session)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE = %d, want 200", rec.Code)
+	}
+	if rec := post(s, session, "", list); rec.Code != http.StatusNotFound {
+		t.Errorf("request on a terminated session = %d, want 404", rec.Code)
+	}
+}
+
+func TestMCPSessionExpiry(t *testing.T) {
+	s := newTestMCPServer(MCPServerConfig{SessionIdleMs: 60_000})
+	now := time.Unix(1_700_000_000, 0)
+	s.now = func() time.Time { return now }
+	session := handshake(t, s)
+	const ping = `{"jsonrpc":"2.0","id":2,"method":"ping"}`
+
+	// 使用会刷新空闲计时
+	now = now.Add(50 * time.Second)
+	if rec := post(s, session, "", ping); rec.Code != http.StatusOK {
+		t.Fatalf("ping after 50s = %d, want 200", rec.Code)
+	}
+	now = now.Add(50 * time.Second)
+	if rec := post(s, session, "", ping); rec.Code != http.StatusOK {
+		t.Fatalf("ping 50s after the last use = %d, want 200", rec.Code)
+	}
+
+	now = now.Add(61 * time.Second)
+	if rec := post(s, session, "", ping); rec.Code != http.StatusNotFound {
+		t.Errorf("ping after 61s idle = %d, want 404", rec.Code)
+	}
+	if n := len(s.sessions); n != 0 {
+		t.Errorf("sessions = %d, want the expired one removed", n)
+	}
+}
+
+func TestMCPMaxSessions(t *testing.T) {
+	s := newTestMCPServer(MCPServerConfig{MaxSessions: 2})
+	now := time.Unix(1_700_000_000, 0)
+	s.now = func() time.Time { return now }
+	const ping = `{"jsonrpc":"2.0","id":2,"method":"ping"}`
+
+	first := handshake(t, s)
+	now = now.Add(time.Second)
+	second := handshake(t, s)
+	now = now.Add(time.Second)
+	post(s, first, "", ping) // first 现在比 second 更近被使用
+
+	now = now.Add(time.Second)
+	third := handshake(t, s)
+	if n := len(s.sessions); n != 2 {
+		t.Fatalf("sessions = %d, want capped at 2", n)
+	}
+	for session, want := range map[string]int{first: http.StatusOK, second: http.StatusNotFound, third: http.StatusOK} {
+		if rec := post(s, session, "", ping); rec.Code != want {
+			t.Errorf("ping on session %s = %d, want %d", session, rec.Code, want)
+		}
+	}
+}
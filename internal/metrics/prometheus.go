@@ -12,7 +12,7 @@ var (
 			Name: "rajomon_requests_total",
 			Help: "Total number of requests processed by the gateway",
 		},
-		[]string{"status","handler"},// labels: status=accepted/rejected, handler=mcp/context
+		[]string{"status","handler"},// labels: status=accepted/rejected_*/cancelled (rejected_deadline=超时预算装不下预测耗时), handler=mcp/context
 	)
 
 	// 2. 直方图：记录请求延迟分布
@@ -41,7 +41,7 @@ var (
 			Name: "rajomon_current_price",
 			Help: "Current dynamic price of the service",
 		},
-		[]string{"handler"}, // 增加 handler 标签
+		[]string{"handler", "policy"}, // handler + 定价策略名，方便 A/B 对比
 	)
 
 	// 5. 仪表盘：当前综合成本 (帮助调试 EWMA 算法)
@@ -50,7 +50,151 @@ var (
 			Name: "rajomon_composite_cost",
 			Help: "Current calculated composite cost (latency + tokens)",
 		},
-		[]string{"handler"}, // 增加 handler 标签
+		[]string{"handler", "policy"}, // handler + 定价策略名，方便 A/B 对比
+	)
+
+	// 6. 计数器：网关账户体系的代币流水 (扣费/退款/充值)
+	LedgerTokens = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rajomon_ledger_tokens_total",
+			Help: "Total tokens moved through the gateway ledger",
+		},
+		[]string{"kind"}, // kind=debit/refund/refill
+	)
+
+	// 7. 仪表盘：准入等待队列的当前深度
+	QueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rajomon_queue_depth",
+			Help: "Number of requests currently waiting in the admission queue",
+		},
+		[]string{"handler"},
+	)
+
+	// 8. 直方图：请求在准入队列中的等待时间
+	QueueWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rajomon_queue_wait_seconds",
+			Help:    "Time requests spent waiting in the admission queue",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+		},
+		[]string{"handler", "result"}, // result=admitted/timeout
+	)
+
+	// 9. 仪表盘：后端健康状态 (1=可接流量, 0=下线/摘除)
+	BackendHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rajomon_backend_healthy",
+			Help: "Whether the backend is currently receiving traffic (1) or not (0)",
+		},
+		[]string{"backend"},
+	)
+
+	// 10. 计数器：后端因代理连续出错被摘除的次数
+	BackendEjections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rajomon_backend_ejections_total",
+			Help: "Number of times a backend was ejected after consecutive proxy errors",
+		},
+		[]string{"backend"},
+	)
+
+	// 11. 仪表盘：下游后端广播的价格 (多跳价格传播)
+	DownstreamPrice = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rajomon_downstream_price",
+			Help: "Latest price advertised by a downstream backend in its Price response header",
+		},
+		[]string{"handler", "backend"},
+	)
+
+	// 12. 计数器：请求追踪缓冲已满而丢弃的记录数
+	TraceDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rajomon_trace_dropped_total",
+			Help: "Number of trace records dropped because the recorder buffer was full",
+		},
+	)
+
+	// 13. 计数器：配置热加载的结果
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rajomon_config_reload_total",
+			Help: "Number of configuration reload attempts by result",
+		},
+		[]string{"result"}, // result=success/failure
+	)
+
+	// 14. 仪表盘：运维人员设置的价格干预 (pin/floor/ceil)，撤销或到期后删除
+	PriceOverride = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rajomon_price_override",
+			Help: "Active manual price override for a key",
+		},
+		[]string{"handler", "kind"}, // kind=pin/floor/ceil
+	)
+
+	// 15. 计数器：管理接口的变更操作 (审计)
+	AdminActions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rajomon_admin_actions_total",
+			Help: "Number of state-changing admin API calls",
+		},
+		[]string{"action", "result"}, // result=ok/error/denied
+	)
+
+	// 16. 仪表盘：后端熔断器状态 (0=closed, 1=half_open, 2=open)
+	BreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rajomon_breaker_state",
+			Help: "Circuit breaker state of the backend (0=closed, 1=half_open, 2=open)",
+		},
+		[]string{"backend"},
+	)
+
+	// 17. 计数器：熔断器状态切换次数
+	BreakerTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rajomon_breaker_transitions_total",
+			Help: "Number of circuit breaker state transitions",
+		},
+		[]string{"backend", "from", "to"},
+	)
+
+	// 18. 计数器：所有后端都熔断时快速失败的请求数
+	BreakerRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rajomon_breaker_rejections_total",
+			Help: "Number of requests failed fast because every backend circuit was open",
+		},
+		[]string{"handler"},
+	)
+
+	// 19. 计数器：负载均衡器的失败重试 (retried=换后端重试，其余为放弃重试的原因)
+	Retries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rajomon_retries_total",
+			Help: "Number of proxy retries on another backend, and retries given up by reason",
+		},
+		[]string{"handler", "result"}, // result=retried/budget_exhausted/max_retries/no_backend
+	)
+
+	// 20. 计数器：对冲请求 (sent=发出对冲副本，won=对冲副本先返回，suppressed=价格过高不对冲，budget_exhausted=重试预算用完不对冲)
+	Hedges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rajomon_hedges_total",
+			Help: "Number of hedged requests sent, won by the hedge, and suppressed by price or retry budget",
+		},
+		[]string{"handler", "result"}, // result=sent/won/suppressed/budget_exhausted
+	)
+
+	// 21. 计数器：被取消的请求 (不计入延迟分布和定价 EWMA)
+	Cancelled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rajomon_cancelled_total",
+			Help: "Number of requests cancelled by the client or by their deadline before completing",
+		},
+		[]string{"handler", "reason"}, // reason=client/deadline
 	)
 )
 
@@ -61,4 +205,20 @@ func Init() {
 	prometheus.MustRegister(TokenUsage)
 	prometheus.MustRegister(CurrentPrice)
 	prometheus.MustRegister(CompositeCost)
+	prometheus.MustRegister(LedgerTokens)
+	prometheus.MustRegister(QueueDepth)
+	prometheus.MustRegister(QueueWait)
+	prometheus.MustRegister(BackendHealthy)
+	prometheus.MustRegister(BackendEjections)
+	prometheus.MustRegister(DownstreamPrice)
+	prometheus.MustRegister(TraceDropped)
+	prometheus.MustRegister(ConfigReloads)
+	prometheus.MustRegister(PriceOverride)
+	prometheus.MustRegister(AdminActions)
+	prometheus.MustRegister(BreakerState)
+	prometheus.MustRegister(BreakerTransitions)
+	prometheus.MustRegister(BreakerRejections)
+	prometheus.MustRegister(Retries)
+	prometheus.MustRegister(Hedges)
+	prometheus.MustRegister(Cancelled)
 }
\ No newline at end of file
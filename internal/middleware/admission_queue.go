@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"rajomon-gateway/internal/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull 等待队列已满
+	ErrQueueFull = errors.New("admission queue full")
+	// ErrQueueTimeout 排队超过最长等待时间仍未被放行
+	ErrQueueTimeout = errors.New("admission queue wait timeout")
+)
+
+// AdmissionQueueConfig 准入等待队列参数
+type AdmissionQueueConfig struct {
+	MaxDepth     int           // 最多允许多少个请求同时排队
+	MaxWait      time.Duration // 单个请求最长等待时间，超时返回 429
+	MaxInFlight  int           // 放行后的并发上限 (0 表示不限，只看价格)
+	PollInterval time.Duration // 价格轮询周期：价格下降后多久能感知到
+}
+
+// DefaultAdmissionQueueConfig 默认参数：最多排 100 个，最多等 2s
+func DefaultAdmissionQueueConfig() AdmissionQueueConfig {
+	return AdmissionQueueConfig{
+		MaxDepth:     100,
+		MaxWait:      2 * time.Second,
+		MaxInFlight:  0,
+		PollInterval: 20 * time.Millisecond,
+	}
+}
+
+// waiter 一个正在排队的请求
+type waiter struct {
+	key      string
+	bid      int
+	seq      uint64        // 同价时先来先服务
+	enqueued time.Time     // 入队时间
+	ready    chan struct{} // 被放行时关闭
+	admitted bool
+}
+
+// AdmissionQueue 按出价排序的有界等待队列
+// 出价低于当前价格的请求不再直接 429，而是排队等待：
+// 价格回落到出价以下 (或并发槽位释放) 时按出价从高到低放行，超时才拒绝。
+// 这样可以把突发的 Agent 流量削峰填谷，同时不放弃过载保护 (放行条件仍然是 出价 >= 价格)。
+type AdmissionQueue struct {
+	cfg     AdmissionQueueConfig
+	priceFn func(key string) int
+
+	mu       sync.Mutex
+	waiters  []*waiter // 按 (出价降序, seq 升序) 排列
+	depth    map[string]int
+	inFlight int
+	seq      uint64
+}
+
+// NewAdmissionQueue 创建等待队列，priceFn 用于查询 Key 的当前价格 (通常是 ctrl.GetPrice)
+func NewAdmissionQueue(cfg AdmissionQueueConfig, priceFn func(key string) int) *AdmissionQueue {
+	return &AdmissionQueue{
+		cfg:     cfg,
+		priceFn: priceFn,
+		depth:   make(map[string]int),
+	}
+}
+
+// Start 启动价格轮询，价格下降后及时放行排队的请求，直到 ctx 被取消
+func (q *AdmissionQueue) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(q.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				q.mu.Lock()
+				q.dispatchLocked()
+				q.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Acquire 申请准入，放行后返回 release (请求处理完必须调用，用于释放并发槽位)
+// 可以立即放行时不排队；否则排队直到放行、超时 (ErrQueueTimeout) 或 ctx 取消
+func (q *AdmissionQueue) Acquire(ctx context.Context, key string, bid int) (func(), error) {
+	q.mu.Lock()
+	// 1. 队列为空且满足条件，直接放行 (不插队：有人排队时新请求也要进队列按出价排序)
+	if len(q.waiters) == 0 && q.admissibleLocked(key, bid) {
+		q.inFlight++
+		q.mu.Unlock()
+		return q.release, nil
+	}
+
+	// 2. 队列已满，直接拒绝
+	if len(q.waiters) >= q.cfg.MaxDepth {
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	// 3. 入队，并立即尝试调度一次 (可能本来就能放行，只是前面有人在等)
+	w := &waiter{key: key, bid: bid, seq: q.seq, enqueued: time.Now(), ready: make(chan struct{})}
+	q.seq++
+	q.pushLocked(w)
+	q.dispatchLocked()
+	q.mu.Unlock()
+
+	timer := time.NewTimer(q.cfg.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		metrics.QueueWait.WithLabelValues(key, "admitted").Observe(time.Since(w.enqueued).Seconds())
+		return q.release, nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	// 超时或客户端放弃：出队。注意可能恰好在这一刻被放行了
+	q.mu.Lock()
+	if w.admitted {
+		q.mu.Unlock()
+		metrics.QueueWait.WithLabelValues(key, "admitted").Observe(time.Since(w.enqueued).Seconds())
+		return q.release, nil
+	}
+	q.removeLocked(w)
+	q.mu.Unlock()
+
+	metrics.QueueWait.WithLabelValues(key, "timeout").Observe(time.Since(w.enqueued).Seconds())
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, ErrQueueTimeout
+}
+
+// RetryAfter 建议客户端的重试间隔 (秒)，写入 Retry-After 头
+func (q *AdmissionQueue) RetryAfter() int {
+	seconds := int(q.cfg.MaxWait.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// release 释放并发槽位，并把槽位让给排队中出价最高的请求
+func (q *AdmissionQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight--
+	q.dispatchLocked()
+}
+
+// admissibleLocked 出价不低于价格，且有空闲槽位
+func (q *AdmissionQueue) admissibleLocked(key string, bid int) bool {
+	if q.cfg.MaxInFlight > 0 && q.inFlight >= q.cfg.MaxInFlight {
+		return false
+	}
+	return bid >= q.priceFn(key)
+}
+
+// dispatchLocked 按出价从高到低放行所有满足条件的请求
+func (q *AdmissionQueue) dispatchLocked() {
+	for i := 0; i < len(q.waiters); {
+		if q.cfg.MaxInFlight > 0 && q.inFlight >= q.cfg.MaxInFlight {
+			return
+		}
+		w := q.waiters[i]
+		if w.bid < q.priceFn(w.key) {
+			// 出价不够的请求留在队列里，但不挡住后面其他接口的请求
+			i++
+			continue
+		}
+		q.removeLocked(w)
+		w.admitted = true
+		q.inFlight++
+		close(w.ready)
+	}
+}
+
+func (q *AdmissionQueue) pushLocked(w *waiter) {
+	i := sort.Search(len(q.waiters), func(i int) bool {
+		other := q.waiters[i]
+		return other.bid < w.bid || (other.bid == w.bid && other.seq > w.seq)
+	})
+	q.waiters = append(q.waiters, nil)
+	copy(q.waiters[i+1:], q.waiters[i:])
+	q.waiters[i] = w
+
+	q.depth[w.key]++
+	metrics.QueueDepth.WithLabelValues(w.key).Set(float64(q.depth[w.key]))
+}
+
+func (q *AdmissionQueue) removeLocked(w *waiter) {
+	for i, other := range q.waiters {
+		if other == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			break
+		}
+	}
+	q.depth[w.key]--
+	metrics.QueueDepth.WithLabelValues(w.key).Set(float64(q.depth[w.key]))
+}
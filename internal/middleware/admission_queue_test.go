@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/metrics"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestQueue(cfg AdmissionQueueConfig, price *atomic.Int64) *AdmissionQueue {
+	if cfg.MaxWait == 0 {
+		cfg.MaxWait = 5 * time.Second
+	}
+	return NewAdmissionQueue(cfg, func(string) int { return int(price.Load()) })
+}
+
+// waitQueued 等到队列里恰好有 n 个请求在排队
+func waitQueued(t *testing.T, q *AdmissionQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		q.mu.Lock()
+		queued := len(q.waiters)
+		q.mu.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue depth = %d, want %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAdmissionQueueDispatchesHighestBidFirst(t *testing.T) {
+	var price atomic.Int64
+	q := newTestQueue(AdmissionQueueConfig{MaxDepth: 10, MaxInFlight: 1}, &price)
+
+	// 先占住唯一的槽位，后面的请求全部排队
+	hold, err := q.Acquire(context.Background(), "/mcp", 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	bids := []struct {
+		name string
+		bid  int
+	}{{"low", 10}, {"high", 30}, {"mid-1", 20}, {"mid-2", 20}}
+	order := make(chan string, len(bids))
+	for i, b := range bids {
+		go func() {
+			release, err := q.Acquire(context.Background(), "/mcp", b.bid)
+			if err != nil {
+				order <- err.Error()
+				return
+			}
+			order <- b.name
+			release()
+		}()
+		waitQueued(t, q, i+1)
+	}
+
+	hold()
+	// 出价从高到低，同价先来先服务
+	for _, want := range []string{"high", "mid-1", "mid-2", "low"} {
+		if got := <-order; got != want {
+			t.Fatalf("dispatched %q, want %q", got, want)
+		}
+	}
+}
+
+func TestAdmissionQueueAdmitsWhenPriceDrops(t *testing.T) {
+	var price atomic.Int64
+	price.Store(50)
+	q := newTestQueue(AdmissionQueueConfig{MaxDepth: 10, PollInterval: time.Millisecond}, &price)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	done := make(chan error, 1)
+	go func() {
+		release, err := q.Acquire(ctx, "/mcp", 20)
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	waitQueued(t, q, 1)
+
+	price.Store(20)
+	if err := <-done; err != nil {
+		t.Errorf("Acquire after price drop = %v, want admitted", err)
+	}
+}
+
+func TestAdmissionQueueFull(t *testing.T) {
+	var price atomic.Int64
+	price.Store(100)
+	q := newTestQueue(AdmissionQueueConfig{MaxDepth: 1}, &price)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Acquire(ctx, "/mcp", 1)
+	waitQueued(t, q, 1)
+
+	if _, err := q.Acquire(context.Background(), "/mcp", 1); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Acquire at max depth = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestAdmissionQueueMaxInFlight(t *testing.T) {
+	var price atomic.Int64
+	q := newTestQueue(AdmissionQueueConfig{MaxDepth: 10, MaxInFlight: 2}, &price)
+
+	first, err := q.Acquire(context.Background(), "/mcp", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Acquire(context.Background(), "/mcp", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan struct{})
+	go func() {
+		if _, err := q.Acquire(context.Background(), "/mcp", 0); err == nil {
+			close(admitted)
+		}
+	}()
+	waitQueued(t, q, 1)
+	select {
+	case <-admitted:
+		t.Fatal("third request admitted while 2 of 2 slots are in use")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	first()
+	select {
+	case <-admitted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("queued request not admitted after a slot was released")
+	}
+}
+
+// newQueuedMiddleware 带等待队列的中间件，/mcp 的价格固定为 100 (测试请求都只出价 1)
+func newQueuedMiddleware(t *testing.T, cfg AdmissionQueueConfig) (http.Handler, *AdmissionQueue) {
+	t.Helper()
+	ctrl := controller.NewController()
+	if err := ctrl.SetOverride("/mcp", controller.Override{Kind: controller.OverridePin, Price: 100}); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	q := NewAdmissionQueue(cfg, ctrl.GetPrice)
+	h := RajomonMiddleware(ctrl, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request below the price reached the backend")
+	}), WithAdmissionQueue(q))
+	return h, q
+}
+
+func TestAdmissionQueueTimeoutRejects(t *testing.T) {
+	h, _ := newQueuedMiddleware(t, AdmissionQueueConfig{MaxDepth: 10, MaxWait: 20 * time.Millisecond})
+	rejected := metrics.RequestsTotal.WithLabelValues("rejected_queue_timeout", "/mcp")
+	before := testutil.ToFloat64(rejected)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set("Token", "1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("queue timeout = %d with Retry-After %q, want 429 with Retry-After 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if got := testutil.ToFloat64(rejected) - before; got != 1 {
+		t.Errorf("rejected_queue_timeout += %v, want 1", got)
+	}
+}
+
+func TestAdmissionQueueClientCancel(t *testing.T) {
+	h, q := newQueuedMiddleware(t, AdmissionQueueConfig{MaxDepth: 10, MaxWait: 5 * time.Second})
+	cancelled := metrics.RequestsTotal.WithLabelValues("cancelled", "/mcp")
+	timedOut := metrics.RequestsTotal.WithLabelValues("rejected_queue_timeout", "/mcp")
+	beforeCancelled, beforeTimeout := testutil.ToFloat64(cancelled), testutil.ToFloat64(timedOut)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil).WithContext(ctx)
+	req.Header.Set("Token", "1")
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(rec, req)
+		close(done)
+	}()
+	waitQueued(t, q, 1)
+
+	cancel()
+	<-done
+	if got := testutil.ToFloat64(cancelled) - beforeCancelled; got != 1 {
+		t.Errorf("cancelled += %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(timedOut) - beforeTimeout; got != 0 {
+		t.Errorf("rejected_queue_timeout += %v, want 0 (the client gave up)", got)
+	}
+	waitQueued(t, q, 0)
+}
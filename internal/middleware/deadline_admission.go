@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RejectHeader 治理拒绝的原因，客户端据此区分截止时间拒绝和价格拒绝 (两者都是 429)
+const RejectHeader = "X-Rajomon-Reject"
+
+// RejectDeadline 超时预算装不下预测耗时
+const RejectDeadline = "deadline"
+
+// DeadlineAdmissionConfig 截止时间准入参数
+type DeadlineAdmissionConfig struct {
+	Headroom      float64       // 预测耗时 × Headroom 超过剩余预算时拒绝 (大于 1 更保守)
+	ProbeInterval time.Duration // 每个 Key 每隔多久放行一个本该拒绝的请求作为探测 (0 表示不探测)
+}
+
+// DefaultDeadlineAdmissionConfig 预测耗时超过剩余预算即拒绝，每个 Key 每秒放行一个探测
+func DefaultDeadlineAdmissionConfig() DeadlineAdmissionConfig {
+	return DeadlineAdmissionConfig{Headroom: 1, ProbeInterval: time.Second}
+}
+
+// DeadlineAdmission 截止时间准入：请求的剩余超时预算装不下该 Key 的预测耗时，放进来注定超时，只会白白占用后端容量
+// 延迟预测来自被放行的请求，全部拒绝会让预测永远停在过去的高点，所以周期性地放行探测请求
+type DeadlineAdmission struct {
+	cfg     DeadlineAdmissionConfig
+	predict func(key string) (time.Duration, bool)
+	now     func() time.Time
+
+	mu     sync.Mutex
+	probes map[string]time.Time // Key -> 上次放行探测的时间
+}
+
+// NewDeadlineAdmission predict 返回 Key 的预测耗时 (通常是 RajomonController.PredictLatency)，没有数据时不拒绝
+func NewDeadlineAdmission(cfg DeadlineAdmissionConfig, predict func(key string) (time.Duration, bool)) *DeadlineAdmission {
+	return &DeadlineAdmission{cfg: cfg, predict: predict, now: time.Now, probes: make(map[string]time.Time)}
+}
+
+// Check 判断请求能否在截止时间之前完成；ok 为 false 时应当拒绝，同时返回预测耗时和剩余预算
+// 没有截止时间或者没有预测数据的请求总是放行
+func (d *DeadlineAdmission) Check(ctx context.Context, key string) (predicted, remaining time.Duration, ok bool) {
+	dl, has := ctx.Deadline()
+	if !has {
+		return 0, 0, true
+	}
+	predicted, known := d.predict(key)
+	if !known {
+		return 0, 0, true
+	}
+	now := d.now()
+	remaining = dl.Sub(now)
+	if float64(predicted)*d.cfg.Headroom <= float64(remaining) {
+		return predicted, remaining, true
+	}
+	return predicted, remaining, d.probe(key, now)
+}
+
+// probe 距离该 Key 上次探测已经超过 ProbeInterval 时放行一个
+func (d *DeadlineAdmission) probe(key string, now time.Time) bool {
+	if d.cfg.ProbeInterval <= 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.probes[key]; ok && now.Sub(last) < d.cfg.ProbeInterval {
+		return false
+	}
+	d.probes[key] = now
+	return true
+}
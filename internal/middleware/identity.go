@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+)
+
+// IdentityFunc 从请求中取出经过网关验证的客户端身份 (账户)，验证失败返回 false
+// 账户体系必须使用网关能验证的身份：Client-ID 之类的请求头可以随意伪造，换一个 ID 就能花别人的钱
+type IdentityFunc func(r *http.Request) (string, bool)
+
+// APIKeyIdentity 按 API Key 识别客户端：Authorization: Bearer <key> 或 X-API-Key: <key>
+// keys: 客户端 -> API Key；按 Key 的摘要查表，查找耗时与 Key 的内容无关
+func APIKeyIdentity(keys map[string]string) IdentityFunc {
+	clients := make(map[[sha256.Size]byte]string, len(keys))
+	for client, key := range keys {
+		clients[sha256.Sum256([]byte(key))] = client
+	}
+	return func(r *http.Request) (string, bool) {
+		key := apiKey(r)
+		if key == "" {
+			return "", false
+		}
+		client, ok := clients[sha256.Sum256([]byte(key))]
+		return client, ok
+	}
+}
+
+func apiKey(r *http.Request) string {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return key
+	}
+	return r.Header.Get("X-API-Key")
+}
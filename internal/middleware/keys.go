@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"rajomon-gateway/internal/model"
+	"slices"
+	"strings"
+)
+
+// maxPeekBody 最多窥探多少字节的请求体；更大的请求体不解析，退化为按路径定价
+const maxPeekBody = 1 << 20
+
+// KeyFunc 从请求中提取定价 Key
+// 派生出来的 Key 统一为 "<路由>:<子键>" 的格式 (如 "/mcp:tools/call/web_search")，
+// 控制器据此把按路由配置的定价策略应用到该路由下的所有子键上
+type KeyFunc func(r *http.Request) string
+
+// PathKey 按 URL Path 定价 (默认)
+func PathKey(r *http.Request) string {
+	return r.URL.Path
+}
+
+// HeaderKey 按指定请求头的值定价 (如租户、API Key 分组)，缺失时退化为路径
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return r.URL.Path + ":" + v
+		}
+		return r.URL.Path
+	}
+}
+
+// JSONRPCMethodKey 按 JSON-RPC method 定价 (如 "/mcp:tools/list")
+func JSONRPCMethodKey(r *http.Request) string {
+	return jsonRPCKey(r, func(msg *model.JSONRPCRequest) string { return msg.Method })
+}
+
+// ToolKey 按 MCP 工具定价：tools/call 细分到工具名 (如 "/mcp:tools/call/web_search")，
+// 其它方法按 method 定价
+// 在 MCP 中所有调用都打到同一个端点，真正决定成本的是调用了哪个工具
+func ToolKey(r *http.Request) string {
+	return jsonRPCKey(r, func(msg *model.JSONRPCRequest) string {
+		if msg.Method == "tools/call" {
+			var params struct {
+				Name string `json:"name"`
+			}
+			if json.Unmarshal(msg.Params, &params) == nil && params.Name != "" {
+				return "tools/call/" + params.Name
+			}
+		}
+		return msg.Method
+	})
+}
+
+// jsonRPCKey 按 sub 从 JSON-RPC 消息中提取子键，拼成 "<路由>:<子键>"
+// 批量请求里所有消息的子键相同时沿用该子键，否则归入 "<路由>:batch"：
+// 只按第一条定价的话，把便宜的调用放在最前面就能让整批请求按低价准入
+func jsonRPCKey(r *http.Request, sub func(msg *model.JSONRPCRequest) string) string {
+	msgs := peekJSONRPC(r)
+	if len(msgs) == 0 {
+		return r.URL.Path
+	}
+	key := sub(msgs[0])
+	for _, msg := range msgs[1:] {
+		if sub(msg) != key {
+			return r.URL.Path + ":batch"
+		}
+	}
+	if key == "" {
+		return r.URL.Path
+	}
+	return r.URL.Path + ":" + key
+}
+
+// ModelKey 按模型名定价：优先读 X-Model 头，其次读请求体中的 "model" 字段 (OpenAI 风格)
+func ModelKey(r *http.Request) string {
+	name := r.Header.Get("X-Model")
+	if name == "" {
+		var body struct {
+			Model string `json:"model"`
+		}
+		if buf := peekBody(r); buf != nil && json.Unmarshal(buf, &body) == nil {
+			name = body.Model
+		}
+	}
+	if name == "" {
+		return r.URL.Path
+	}
+	return r.URL.Path + ":model/" + name
+}
+
+// KeyFuncByName 按名称选择 Key 提取方式: path / method / tool / model / header:<Name>
+func KeyFuncByName(name string) (KeyFunc, error) {
+	switch name {
+	case "", "path":
+		return PathKey, nil
+	case "method":
+		return JSONRPCMethodKey, nil
+	case "tool":
+		return ToolKey, nil
+	case "model":
+		return ModelKey, nil
+	}
+	if header, ok := strings.CutPrefix(name, "header:"); ok && header != "" {
+		return HeaderKey(header), nil
+	}
+	return nil, fmt.Errorf("未知的 Key 提取方式: %q", name)
+}
+
+// mcpMethods MCP 规范定义的方法：集合是固定的，按 method / tool 定价时不需要写进白名单
+var mcpMethods = []string{
+	"initialize", "ping", "tools/list", "tools/call",
+	"resources/list", "resources/templates/list", "resources/read", "resources/subscribe", "resources/unsubscribe",
+	"prompts/list", "prompts/get", "completion/complete", "logging/setLevel",
+	"notifications/initialized", "notifications/cancelled", "notifications/progress", "notifications/roots/list_changed",
+}
+
+// AllowKeys 只保留白名单里的派生 Key，其余子键统一归入 "<路由>:other"
+// 子键 (工具名、模型名、请求头的值) 直接来自客户端：不加限制的话每换一个值就多一份控制器状态和指标序列，
+// 客户端还能换一个没用过的名字拿到初始价格，绕开已经涨上去的价格
+// 路由本身的 Key、"<路由>:batch" 和 MCP 规范中的方法名总是允许
+func AllowKeys(fn KeyFunc, allowed []string) KeyFunc {
+	set := make(map[string]bool, len(allowed))
+	for _, key := range allowed {
+		set[key] = true
+	}
+	return func(r *http.Request) string {
+		key := fn(r)
+		sub, derived := strings.CutPrefix(key, r.URL.Path+":")
+		if !derived || set[key] || sub == "batch" || slices.Contains(mcpMethods, sub) {
+			return key
+		}
+		return r.URL.Path + ":other"
+	}
+}
+
+// peekJSONRPC 窥探请求体中的 JSON-RPC 消息 (单条请求返回一条，批量请求返回全部)
+// 请求体不是合法的 JSON-RPC 时返回 nil
+func peekJSONRPC(r *http.Request) []*model.JSONRPCRequest {
+	buf := peekBody(r)
+	buf = bytes.TrimSpace(buf)
+	if len(buf) == 0 {
+		return nil
+	}
+	if buf[0] == '[' {
+		var batch []*model.JSONRPCRequest
+		if json.Unmarshal(buf, &batch) != nil {
+			return nil
+		}
+		for _, msg := range batch {
+			if msg == nil {
+				return nil
+			}
+		}
+		return batch
+	}
+	var msg model.JSONRPCRequest
+	if json.Unmarshal(buf, &msg) != nil {
+		return nil
+	}
+	return []*model.JSONRPCRequest{&msg}
+}
+
+// peekBody 读取请求体的前 maxPeekBody 字节，并把读过的部分拼回去，
+// 保证后续的反向代理看到的请求体与原始请求完全一致
+// 请求体超过上限时返回 nil (不解析)
+func peekBody(r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBody+1))
+	r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+	if err != nil || len(buf) > maxPeekBody {
+		return nil
+	}
+	return buf
+}
+
+// replayBody 已窥探部分 + 剩余部分，Close 时关闭原始请求体
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONRPCKeys(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       string
+		wantMethod string
+		wantTool   string
+	}{
+		{
+			name:       "single call",
+			body:       `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"web_search"}}`,
+			wantMethod: "/mcp:tools/call",
+			wantTool:   "/mcp:tools/call/web_search",
+		},
+		{
+			name:       "tools/call without a name",
+			body:       `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{}}`,
+			wantMethod: "/mcp:tools/call",
+			wantTool:   "/mcp:tools/call",
+		},
+		{
+			name:       "notification",
+			body:       `{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+			wantMethod: "/mcp:notifications/initialized",
+			wantTool:   "/mcp:notifications/initialized",
+		},
+		{
+			name:       "batch of the same call",
+			body:       `[{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"web_search"}},{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"web_search"}}]`,
+			wantMethod: "/mcp:tools/call",
+			wantTool:   "/mcp:tools/call/web_search",
+		},
+		{
+			name:       "batch with a cheap call first",
+			body:       `[{"jsonrpc":"2.0","id":1,"method":"tools/list"},{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"web_search"}}]`,
+			wantMethod: "/mcp:batch",
+			wantTool:   "/mcp:batch",
+		},
+		{
+			name:       "batch of different tools",
+			body:       `[{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"a"}},{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"b"}}]`,
+			wantMethod: "/mcp:tools/call",
+			wantTool:   "/mcp:batch",
+		},
+		{name: "empty batch", body: `[]`, wantMethod: "/mcp", wantTool: "/mcp"},
+		{name: "batch with null", body: `[null]`, wantMethod: "/mcp", wantTool: "/mcp"},
+		{name: "malformed JSON", body: `{"jsonrpc":"2.0","method":`, wantMethod: "/mcp", wantTool: "/mcp"},
+		{name: "not JSON-RPC", body: `{"model":"gpt"}`, wantMethod: "/mcp", wantTool: "/mcp"},
+		{name: "empty body", body: ``, wantMethod: "/mcp", wantTool: "/mcp"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, fn := range []struct {
+				name string
+				key  KeyFunc
+				want string
+			}{{"JSONRPCMethodKey", JSONRPCMethodKey, tc.wantMethod}, {"ToolKey", ToolKey, tc.wantTool}} {
+				r := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(tc.body))
+				if got := fn.key(r); got != fn.want {
+					t.Errorf("%s() = %q, want %q", fn.name, got, fn.want)
+				}
+				// 提取 Key 之后请求体必须原样留给后端
+				if body, _ := io.ReadAll(r.Body); string(body) != tc.body {
+					t.Errorf("%s() consumed the body: %q left, want %q", fn.name, body, tc.body)
+				}
+			}
+		})
+	}
+}
+
+func TestPeekBodyOverLimitReachesBackendUnchanged(t *testing.T) {
+	// 超过 maxPeekBody 的合法 JSON-RPC 请求：不解析，按路径定价，但后端收到完整的请求体
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"web_search","pad":"` +
+		strings.Repeat("x", maxPeekBody) + `"}}`)
+	var received []byte
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+	}))
+	defer backend.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewReader(body))
+	if got := ToolKey(r); got != "/mcp" {
+		t.Errorf("ToolKey() for an oversized body = %q, want the path key", got)
+	}
+	req, _ := http.NewRequest(http.MethodPost, backend.URL, r.Body)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("forward: %v", err)
+	}
+	resp.Body.Close()
+	if !bytes.Equal(received, body) {
+		t.Errorf("backend received %d bytes, want the original %d bytes unchanged", len(received), len(body))
+	}
+}
+
+func TestKeyFuncByName(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"model":"gpt-4o"}`))
+	r.Header.Set("X-Tenant", "acme")
+	for name, want := range map[string]string{"": "/mcp", "path": "/mcp", "model": "/mcp:model/gpt-4o", "header:X-Tenant": "/mcp:acme"} {
+		fn, err := KeyFuncByName(name)
+		if err != nil {
+			t.Errorf("KeyFuncByName(%q) error = %v", name, err)
+			continue
+		}
+		if got := fn(r); got != want {
+			t.Errorf("KeyFuncByName(%q)() = %q, want %q", name, got, want)
+		}
+	}
+	for _, name := range []string{"header:", "random"} {
+		if _, err := KeyFuncByName(name); err == nil {
+			t.Errorf("KeyFuncByName(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestAllowKeys(t *testing.T) {
+	allowed := []string{"/mcp:tools/call/web_search", "/mcp:model/gpt-4o", "/mcp:acme"}
+	cases := []struct {
+		name   string
+		key    KeyFunc
+		body   string
+		header string
+		want   string
+	}{
+		{name: "allowed tool", key: ToolKey, body: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"web_search"}}`, want: "/mcp:tools/call/web_search"},
+		{name: "unknown tool", key: ToolKey, body: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"web_search_2"}}`, want: "/mcp:other"},
+		{name: "MCP method", key: ToolKey, body: `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, want: "/mcp:tools/list"},
+		{name: "unknown method", key: JSONRPCMethodKey, body: `{"jsonrpc":"2.0","id":1,"method":"x-1234"}`, want: "/mcp:other"},
+		{name: "mixed batch", key: ToolKey, body: `[{"jsonrpc":"2.0","id":1,"method":"tools/list"},{"jsonrpc":"2.0","id":2,"method":"ping"}]`, want: "/mcp:batch"},
+		{name: "route key", key: ToolKey, body: ``, want: "/mcp"},
+		{name: "allowed model", key: ModelKey, body: `{"model":"gpt-4o"}`, want: "/mcp:model/gpt-4o"},
+		{name: "unknown model", key: ModelKey, body: `{"model":"gpt-4o-0513"}`, want: "/mcp:other"},
+		{name: "allowed header", key: HeaderKey("X-Tenant"), header: "acme", want: "/mcp:acme"},
+		{name: "unknown header", key: HeaderKey("X-Tenant"), header: "acme-2", want: "/mcp:other"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(tc.body))
+			if tc.header != "" {
+				r.Header.Set("X-Tenant", tc.header)
+			}
+			if got := AllowKeys(tc.key, allowed)(r); got != tc.want {
+				t.Errorf("key = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
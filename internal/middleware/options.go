@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"rajomon-gateway/internal/account"
+	"rajomon-gateway/internal/trace"
+)
+
+// Option RajomonMiddleware 的可选配置
+type Option func(*options)
+
+type options struct {
+	ledger   *account.Ledger
+	identity IdentityFunc
+	queue    *AdmissionQueue
+	keyFunc  KeyFunc
+	trace    *trace.Recorder
+	deadline *DeadlineAdmission
+}
+
+// WithLedger 启用网关侧账户：准入时从客户端账户中扣除成交价格，后端失败时退款
+// identity 识别请求属于哪个账户，识别不了的请求返回 401
+// 不启用时沿用旧行为：只比较 Token 头和价格，不做任何扣费
+func WithLedger(ledger *account.Ledger, identity IdentityFunc) Option {
+	return func(o *options) { o.ledger, o.identity = ledger, identity }
+}
+
+// WithAdmissionQueue 启用按出价排序的准入等待队列 (见 AdmissionQueue)
+// 队列需要调用方自行 Start，以便价格下降时及时放行
+func WithAdmissionQueue(queue *AdmissionQueue) Option {
+	return func(o *options) { o.queue = queue }
+}
+
+// WithKeyFunc 指定定价 Key 的提取方式 (默认 PathKey)
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(o *options) { o.keyFunc = fn }
+}
+
+// WithTrace 启用请求追踪：每个请求结束时把准入决策和请求形态写入 JSONL (见 trace.Recorder)
+func WithTrace(rec *trace.Recorder) Option {
+	return func(o *options) { o.trace = rec }
+}
+
+// WithDeadlineAdmission 启用截止时间准入：剩余超时预算装不下预测耗时的请求直接拒绝 (见 DeadlineAdmission)
+func WithDeadlineAdmission(d *DeadlineAdmission) Option {
+	return func(o *options) { o.deadline = d }
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/account"
+	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/trace"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newLedgerMiddleware alice (sk-alice) 账户余额 50，/mcp 的价格固定为 10
+func newLedgerMiddleware(t *testing.T, backend http.HandlerFunc) (http.Handler, *account.Ledger) {
+	t.Helper()
+	ctrl := controller.NewController()
+	if err := ctrl.SetOverride("/mcp", controller.Override{Kind: controller.OverridePin, Price: 10}); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	ledger := account.NewLedger(account.NewMemoryStore(), account.LedgerConfig{InitialBalance: 50, MaxBalance: 500})
+	if _, _, err := ledger.Provision([]string{"alice"}); err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	h := RajomonMiddleware(ctrl, backend, WithLedger(ledger, APIKeyIdentity(map[string]string{"alice": "sk-alice"})))
+	return h, ledger
+}
+
+func TestLedgerChargesAndRefunds(t *testing.T) {
+	cases := []struct {
+		name        string
+		apiKey      string
+		status      int // 后端返回的状态码
+		wantCode    int
+		wantBalance int64
+	}{
+		{name: "success is charged", apiKey: "sk-alice", status: http.StatusOK, wantCode: http.StatusOK, wantBalance: 40},
+		{name: "client error is charged", apiKey: "sk-alice", status: http.StatusNotFound, wantCode: http.StatusNotFound, wantBalance: 40},
+		{name: "backend 5xx is refunded", apiKey: "sk-alice", status: http.StatusBadGateway, wantCode: http.StatusBadGateway, wantBalance: 50},
+		{name: "unknown key is rejected", apiKey: "sk-mallory", wantCode: http.StatusUnauthorized, wantBalance: 50},
+		{name: "missing key is rejected", wantCode: http.StatusUnauthorized, wantBalance: 50},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			h, ledger := newLedgerMiddleware(t, func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(tc.status)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+			if tc.apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.apiKey)
+			}
+			// 伪造的 Client-ID 不影响记账
+			req.Header.Set("Client-ID", "bob")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantCode)
+			}
+			if tc.wantCode == http.StatusUnauthorized && called {
+				t.Error("unauthenticated request reached the backend")
+			}
+			if balance, _ := ledger.Balance("alice"); balance != tc.wantBalance {
+				t.Errorf("alice balance = %d, want %d", balance, tc.wantBalance)
+			}
+			if balance, _ := ledger.Balance("bob"); balance != 0 {
+				t.Errorf("bob balance = %d, want 0 (no account is opened on the request path)", balance)
+			}
+		})
+	}
+}
+
+func TestLedgerForwardsEffectiveBid(t *testing.T) {
+	var forwarded string
+	h, _ := newLedgerMiddleware(t, func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("Token")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set("X-API-Key", "sk-alice")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	// 没带出价时按 All-in 出价 (余额 50)，后端的治理中间件需要看到这个出价
+	if rec.Code != http.StatusOK || forwarded != "50" {
+		t.Errorf("all-in request = %d with Token %q forwarded, want 200 with Token \"50\"", rec.Code, forwarded)
+	}
+}
+
+func TestDeadlineProbeRequiresAuthentication(t *testing.T) {
+	ctrl := controller.NewController()
+	ledger := account.NewLedger(account.NewMemoryStore(), account.LedgerConfig{InitialBalance: 50, MaxBalance: 500})
+	if _, _, err := ledger.Provision([]string{"alice"}); err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	// 预测耗时 2s，超时预算只有 1s：每小时只放行一个探测请求
+	admission := NewDeadlineAdmission(DeadlineAdmissionConfig{Headroom: 1, ProbeInterval: time.Hour},
+		func(string) (time.Duration, bool) { return 2 * time.Second, true })
+	h := RajomonMiddleware(ctrl, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithLedger(ledger, APIKeyIdentity(map[string]string{"alice": "sk-alice"})), WithDeadlineAdmission(admission))
+
+	serve := func(apiKey string) *httptest.ResponseRecorder {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodGet, "/mcp", nil).WithContext(ctx)
+		req.Header.Set("Token", "10")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for range 3 {
+		if rec := serve("sk-mallory"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("unauthenticated request = %d, want 401 before any deadline check", rec.Code)
+		}
+	}
+	// 未认证的请求没有占用探测名额
+	if rec := serve("sk-alice"); rec.Code != http.StatusOK {
+		t.Errorf("first authenticated request = %d, want 200 (the probe)", rec.Code)
+	}
+	if rec := serve("sk-alice"); rec.Code != http.StatusTooManyRequests || rec.Header().Get(RejectHeader) != RejectDeadline {
+		t.Errorf("second authenticated request = %d, want 429 rejected for deadline", rec.Code)
+	}
+}
+
+func TestTraceRecordsRequestURIWithoutCredentials(t *testing.T) {
+	var buf bytes.Buffer
+	rec := trace.NewRecorder(&buf)
+	ctrl := controller.NewController()
+	ledger := account.NewLedger(account.NewMemoryStore(), account.LedgerConfig{InitialBalance: 50, MaxBalance: 500})
+	if _, _, err := ledger.Provision([]string{"alice"}); err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	h := RajomonMiddleware(ctrl, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithLedger(ledger, APIKeyIdentity(map[string]string{"alice": "sk-alice"})), WithTrace(rec))
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp?session=1", nil)
+	req.Header.Set("Authorization", "Bearer sk-alice")
+	req.Header.Set("Token", "10")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "sk-alice") {
+		t.Errorf("trace contains the API key:\n%s", buf.String())
+	}
+	records, err := trace.Decode(&buf)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Decode = %d records, %v; want 1", len(records), err)
+	}
+	if got := records[0]; got.Path != "/mcp?session=1" || got.Client != "alice" {
+		t.Errorf("record path %q client %q, want /mcp?session=1 and alice", got.Path, got.Client)
+	}
+}
@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"rajomon-gateway/internal/account"
 	"rajomon-gateway/internal/controller"
+	"rajomon-gateway/internal/deadline"
 	"rajomon-gateway/internal/metrics"
 	"strconv"
 	"time"
@@ -12,15 +16,36 @@ import (
 // 模拟一个全局控制器（实际项目中应该注入 Controller 实例）
 // var currentPrice = 5
 
-func RajomonMiddleware(ctrl *controller.RajomonController, next http.Handler) http.Handler {
+func RajomonMiddleware(ctrl *controller.RajomonController, next http.Handler, opts ...Option) http.Handler {
+	o := &options{keyFunc: PathKey}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 🔥 策略实现：接口粒度控制
-		// 我们使用 URL Path 作为资源的唯一标识 (Key)
+		// 默认使用 URL Path 作为资源的唯一标识 (Key)
 		// 这样 "/mcp/chat" 和 "/mcp/image" 会有独立的价格体系，互不干扰
-		path := r.URL.Path // 用作 metrics 的 label
+		// 对于 MCP 这种单端点协议，可以换成按 JSON-RPC method / 工具名提取 Key (见 keys.go)
+		key := o.keyFunc(r) // 用作 metrics 的 label
+		// 把 Key 带给下游 (负载均衡器据此把后端广播的价格记到对应 Key 上)
+		// 同时挂上重试计数器，负载均衡器换后端重试的次数计入该 Key 的成本
+		ctx, retries := controller.WithRetryCounter(controller.WithKey(r.Context(), key))
+		r = r.WithContext(ctx)
+
+		// 包装 ResponseWriter：记录状态码 (包括拒绝分支)，并旁路解析 SSE usage 帧
+		rec := newResponseRecorder(w)
+		w = rec
+
+		// 准入结果，同时写入 metrics 和请求追踪
+		status := ""
+		count := func(s string) {
+			status = s
+			metrics.RequestsTotal.WithLabelValues(s, key).Inc()
+		}
 
 		// 1. 获取该接口的最新价格 (传入 Key)
-		price := ctrl.GetPrice(path)
+		price := ctrl.GetPrice(key)
 
 		// 2. 价格回传 (Piggybacking) - 告知客户端当前接口的价格
 		w.Header().Set("Price", fmt.Sprintf("%d", price))
@@ -40,17 +65,106 @@ func RajomonMiddleware(ctrl *controller.RajomonController, next http.Handler) ht
 		tokenStr := r.Header.Get("Token")
 		clientToken, _ := strconv.Atoi(tokenStr)
 
+		// 3.1 请求追踪 (可选)：请求结束时记录准入决策和请求形态
+		var latency time.Duration
+		tokenUsage := 0
+		client := "" // 账户身份 (启用账户体系时)
+		if o.trace != nil {
+			entry := newTraceRecord(r, key, o.trace.BodyLimit)
+			defer func() {
+				entry.Client = clientID(r)
+				if client != "" {
+					entry.Client = client
+				}
+				entry.Bid = clientToken
+				entry.Price = price
+				entry.Status = status
+				if entry.Status == "" {
+					entry.Status = "error"
+				}
+				entry.Code = rec.Status()
+				entry.LatencyMs = float64(latency.Microseconds()) / 1000
+				entry.Tokens = tokenUsage
+				o.trace.Record(entry)
+			}()
+		}
+
+		// 3.2 身份验证：启用账户体系时，Token 头只是出价，不带出价则视为 All-in (出价 = 账户余额)
+		// 账户按网关验证过的身份 (API Key) 索引，不认 Client-ID 这类可以伪造的请求头
+		if o.ledger != nil {
+			id, ok := o.identity(r)
+			if !ok {
+				count("rejected_unauthenticated")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="rajomon"`)
+				http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+				return
+			}
+			client = id
+			if tokenStr == "" {
+				balance, err := o.ledger.Balance(client)
+				if err != nil {
+					fmt.Printf("⚠️ [Ledger] 查询余额失败 [%s]: %v\n", client, err)
+					http.Error(w, "Account store unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				tokenStr = strconv.FormatInt(balance, 10)
+				clientToken = int(balance)
+				// 把实际出价转发给后端：开启治理模式的后端按 Token 头做自己的准入，没有 Token 会直接 403
+				r.Header.Set("Token", tokenStr)
+			}
+		}
+
+		// 3.3 截止时间准入 (可选)：客户端给的超时预算装不下预测耗时，放进来注定超时，趁早拒绝
+		// 放在身份验证之后：未认证的请求不能占用每个 Key 的探测名额
+		if o.deadline != nil {
+			if predicted, remaining, ok := o.deadline.Check(r.Context(), key); !ok {
+				fmt.Printf("⏱️ [拒绝] 超时预算不足! 剩余:%dms < 预测耗时:%dms [%s]\n", remaining.Milliseconds(), predicted.Milliseconds(), key)
+				count("rejected_deadline")
+				// 429 + 拒绝原因：与价格拒绝同属治理拒绝，和上游真正超时的 504 区分开
+				w.Header().Set(RejectHeader, RejectDeadline)
+				w.Header().Set("X-Predicted-Latency", fmt.Sprintf("%dms", predicted.Milliseconds()))
+				http.Error(w, fmt.Sprintf("Deadline too short (predicted %dms > remaining %dms)", predicted.Milliseconds(), remaining.Milliseconds()), http.StatusTooManyRequests)
+				return
+			}
+		}
+
 		// 4. 准入检查
 		if tokenStr == "" {
 			// [新增] 埋点：记录被拒绝的请求 (No Token)
-			metrics.RequestsTotal.WithLabelValues("rejected_no_token", path).Inc()
+			count("rejected_no_token")
 			http.Error(w, "No Token", http.StatusForbidden)
 			return
+		} else if o.queue != nil {
+			// 启用等待队列：出价不足时排队等价格回落，而不是立即 429
+			release, err := o.queue.Acquire(r.Context(), key, clientToken)
+			if err != nil {
+				if reason := deadline.Reason(r.Context()); reason != "" {
+					// 客户端在排队期间放弃了 (或者超时预算用完)，无需再写响应
+					count("cancelled")
+					metrics.Cancelled.WithLabelValues(key, reason).Inc()
+					return
+				}
+				reason := "rejected_queue_timeout"
+				if errors.Is(err, ErrQueueFull) {
+					reason = "rejected_queue_full"
+				}
+				fmt.Printf("⛔ [拒绝] 排队失败(%v)! 客户带了:%d | 当前价格:%d\n", err, clientToken, ctrl.GetPrice(key))
+				count(reason)
+				w.Header().Set("Price", fmt.Sprintf("%d", ctrl.GetPrice(key)))
+				w.Header().Set("Retry-After", strconv.Itoa(o.queue.RetryAfter()))
+				http.Error(w, "System is busy (Price > Token)", http.StatusTooManyRequests)
+				return
+			}
+			defer release()
+
+			// 排队期间价格可能已经变化，按放行时的价格成交
+			price = ctrl.GetPrice(key)
+			w.Header().Set("Price", fmt.Sprintf("%d", price))
 		} else if clientToken < price {
 			// Log 一下，方便观察
 			fmt.Printf("⛔ [拒绝] Token不足! 客户带了:%d < 当前价格:%d\n", clientToken, price)
 			// [新增] 埋点：记录被 Rajomon 算法拦截的请求 (核心指标！)
-			metrics.RequestsTotal.WithLabelValues("rejected_rajomon", path).Inc()
+			count("rejected_rajomon")
 			// 返回 429 错误
 			http.Error(w, "System is busy (Price > Token)", http.StatusTooManyRequests)
 			// 🛑 核心：直接返回，不要执行 next.ServeHTTP！
@@ -58,32 +172,73 @@ func RajomonMiddleware(ctrl *controller.RajomonController, next http.Handler) ht
 			return
 		}
 
+		// 4.1 网关侧扣费：从账户余额中原子扣除成交价格 (而不是出价)
+		if o.ledger != nil {
+			balance, err := o.ledger.Debit(client, key, int64(price))
+			if errors.Is(err, account.ErrInsufficientBalance) {
+				fmt.Printf("⛔ [拒绝] 余额不足! 客户:%s 余额:%d < 当前价格:%d\n", client, balance, price)
+				count("rejected_balance")
+				w.Header().Set("Balance", strconv.FormatInt(balance, 10))
+				http.Error(w, "Insufficient balance", http.StatusTooManyRequests)
+				return
+			} else if err != nil {
+				fmt.Printf("⚠️ [Ledger] 扣费失败 [%s]: %v\n", client, err)
+				http.Error(w, "Account store unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Balance", strconv.FormatInt(balance, 10))
+		}
+
 		// [新增] 埋点：记录被接受的请求
-		metrics.RequestsTotal.WithLabelValues("accepted", path).Inc()
+		count("accepted")
 
 		start := time.Now()
 
+		// 请求中途被取消 (客户端断开或超时预算用完)：单独计数，延迟只是被截断的值，不计入延迟分布和 EWMA
+		cancelled := func(reason string) {
+			latency = time.Since(start)
+			metrics.Cancelled.WithLabelValues(key, reason).Inc()
+			fmt.Printf("🚫 [取消][%s] 请求在 %.2fms 后被取消 (%s)，不计入定价\n", key, float64(latency.Milliseconds()), reason)
+		}
+		// 流式响应写到一半被取消时，ReverseProxy 以 ErrAbortHandler 中止连接，走不到下面的采样
+		defer func() {
+			if p := recover(); p != nil {
+				if reason := deadline.Reason(r.Context()); reason != "" {
+					cancelled(reason)
+				}
+				panic(p)
+			}
+		}()
+
 		// 5. 执行业务 (Wrapper)
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(rec, r)
+
+		// 5.1 后端处理失败 (5xx) 时退款，客户端不为网关/后端的故障买单
+		if o.ledger != nil && rec.Status() >= http.StatusInternalServerError {
+			if _, err := o.ledger.Refund(client, key, int64(price)); err != nil {
+				fmt.Printf("⚠️ [Ledger] 退款失败 [%s]: %v\n", client, err)
+			} else {
+				fmt.Printf("↩️ [Ledger] 后端失败(%d)，已退款 %d -> %s\n", rec.Status(), price, client)
+			}
+		}
 
 		// 6. 采样数据
-		latency := time.Since(start)
+		if reason := deadline.Reason(r.Context()); reason != "" {
+			cancelled(reason)
+			return
+		}
+		latency = time.Since(start)
+
 		// 埋点：记录请求耗时 (秒)
-		metrics.RequestLatency.WithLabelValues(path).Observe(latency.Seconds())
+		metrics.RequestLatency.WithLabelValues(key).Observe(latency.Seconds())
 
-		// 从响应头中获取后端回传的 Token 消耗
-		tokenUsageStr := w.Header().Get("X-Token-Usage")
-		tokenUsage := 0
-		if tokenUsageStr != "" {
-			var err error
-			tokenUsage, err = strconv.Atoi(tokenUsageStr)
-			if err != nil {
-				fmt.Printf("⚠️ [中间件警报] 解析 Token Usage 失败: %v\n", err)
-				tokenUsage = 0
-			}
-		} else {
-			// 这可能是普通 HTTP 请求，不是 LLM 请求
-			// fmt.Println("ℹ️ [中间件] 本次响应未包含 Token Usage 数据")
+		// Token 消耗来源 (按优先级):
+		// 1. 流经网关的 SSE "event: usage" 帧 (真实观测值)
+		// 2. HTTP Trailer 中的 X-Token-Usage (流结束后才发送)
+		// 3. 后端预先写好的 X-Token-Usage 响应头 (兼容旧后端)
+		tokenUsage = rec.ObservedTokens()
+		if tokenUsage == 0 {
+			tokenUsage = headerTokenUsage(w.Header())
 		}
 
 		//因为 SSE 是流式请求，next.ServeHTTP(w, r) 会一直阻塞直到流结束。
@@ -92,10 +247,42 @@ func RajomonMiddleware(ctrl *controller.RajomonController, next http.Handler) ht
 
 		if tokenUsage > 0 {
 			// [新增] 埋点：记录 Token 消耗
-			metrics.TokenUsage.WithLabelValues(path).Observe(float64(tokenUsage))
+			metrics.TokenUsage.WithLabelValues(key).Observe(float64(tokenUsage))
 			fmt.Printf("📊 [审计][%s] ⏳latency %.2fms | tokenUsage %d | ⚖️ 触发定价计算...\n",
-				path, float64(latency.Milliseconds()), tokenUsage)
+				key, float64(latency.Milliseconds()), tokenUsage)
 		}
-		ctrl.RecordLatency(path, latency, tokenUsage)
+		ctrl.RecordObservation(key, controller.Observation{Latency: latency, Tokens: tokenUsage, Retries: float64(retries.Load())})
 	})
 }
+
+// clientID 客户端身份：优先使用 Client-ID 头，没有则退化为来源 IP
+func clientID(r *http.Request) string {
+	if id := r.Header.Get("Client-ID"); id != "" {
+		return id
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// headerTokenUsage 从 Trailer 或响应头中读取 X-Token-Usage
+// 未预先声明的 Trailer 会以 http.TrailerPrefix 为前缀出现在 Header 中；
+// 预先声明的 Trailer 在流结束后直接写回同名 Header
+func headerTokenUsage(h http.Header) int {
+	tokenUsageStr := h.Get(http.TrailerPrefix + "X-Token-Usage")
+	if tokenUsageStr == "" {
+		tokenUsageStr = h.Get("X-Token-Usage")
+	}
+	if tokenUsageStr == "" {
+		// 这可能是普通 HTTP 请求，不是 LLM 请求
+		return 0
+	}
+	tokenUsage, err := strconv.Atoi(tokenUsageStr)
+	if err != nil {
+		fmt.Printf("⚠️ [中间件警报] 解析 Token Usage 失败: %v\n", err)
+		return 0
+	}
+	return tokenUsage
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// responseRecorder 包装 ResponseWriter，记录后端写回的状态码，
+// 并在响应是 SSE 时旁路解析其中的 usage 帧 (数据原样透传给客户端)
+// 必须实现 Flush，否则 SSE 流式响应会被缓冲
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	sse    *sseUsageParser // 仅当 Content-Type 为 text/event-stream 时非空
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w}
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+		r.detectSSE()
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+		r.detectSSE()
+	}
+	n, err := r.ResponseWriter.Write(b)
+	if r.sse != nil {
+		r.sse.Feed(b[:n])
+	}
+	return n, err
+}
+
+// detectSSE 响应头确定后判断是否需要解析 SSE
+func (r *responseRecorder) detectSSE() {
+	if strings.HasPrefix(r.Header().Get("Content-Type"), "text/event-stream") {
+		r.sse = &sseUsageParser{}
+	}
+}
+
+// ObservedTokens 从 SSE usage 帧中观测到的 Token 消耗 (0 表示没有观测到)
+func (r *responseRecorder) ObservedTokens() int {
+	if r.sse == nil {
+		return 0
+	}
+	return r.sse.Tokens()
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 让 http.ResponseController 能找到底层的 ResponseWriter
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Status 返回最终状态码 (什么都没写时按 200 处理)
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
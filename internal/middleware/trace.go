@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"rajomon-gateway/internal/trace"
+	"time"
+)
+
+// newTraceRecord 记录请求形态 (方法、路径、回放需要的请求头、请求体)
+// 请求体超过 bodyLimit 时不记录，bodyLimit 为 0 时不窥探请求体
+func newTraceRecord(r *http.Request, key string, bodyLimit int) trace.Record {
+	entry := trace.Record{
+		Time:   time.Now(),
+		Method: r.Method,
+		Path:   r.URL.RequestURI(),
+		Key:    key,
+		Header: trace.RequestHeader(r.Header),
+	}
+	if bodyLimit > 0 {
+		if body := peekBody(r); len(body) <= bodyLimit {
+			entry.Body = string(body)
+		}
+	}
+	return entry
+}
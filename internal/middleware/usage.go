@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"rajomon-gateway/internal/model"
+	"strings"
+)
+
+// maxSSELine 单行最大长度，超过后丢弃该行，防止异常流把缓冲区撑爆
+const maxSSELine = 64 * 1024
+
+// sseUsageParser 旁路解析流经网关的 SSE 帧，从 "event: usage" 中提取真实的 Token 消耗
+// 后端无需再提前算好 X-Token-Usage 头，真实 LLM 后端也只在流结束时才知道用量
+type sseUsageParser struct {
+	line     []byte // 未凑满一行的残余数据
+	overflow bool   // 当前行是否已超长
+	event    string // 当前事件类型
+	data     []string
+	tokens   int // 观测到的 Token 总量 (0 表示还没看到 usage 帧)
+}
+
+// Feed 喂入一段响应体数据 (可能跨越多个 SSE 帧，也可能只是半行)
+func (p *sseUsageParser) Feed(b []byte) {
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			p.appendLine(b)
+			return
+		}
+		p.appendLine(b[:i])
+		b = b[i+1:]
+
+		if !p.overflow {
+			p.handleLine(string(bytes.TrimSuffix(p.line, []byte("\r"))))
+		}
+		p.line = p.line[:0]
+		p.overflow = false
+	}
+}
+
+func (p *sseUsageParser) appendLine(b []byte) {
+	if p.overflow || len(p.line)+len(b) > maxSSELine {
+		p.overflow = true
+		return
+	}
+	p.line = append(p.line, b...)
+}
+
+// handleLine 按 SSE 规范处理一行：空行表示一个事件结束
+func (p *sseUsageParser) handleLine(line string) {
+	switch {
+	case line == "":
+		p.dispatch()
+	case strings.HasPrefix(line, "event:"):
+		p.event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+	case strings.HasPrefix(line, "data:"):
+		p.data = append(p.data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+	}
+}
+
+func (p *sseUsageParser) dispatch() {
+	defer func() {
+		p.event = ""
+		p.data = p.data[:0]
+	}()
+	if p.event != "usage" || len(p.data) == 0 {
+		return
+	}
+
+	var usage model.MockUsage
+	if err := json.Unmarshal([]byte(strings.Join(p.data, "\n")), &usage); err != nil {
+		return
+	}
+	total := usage.TotalTokens
+	if total == 0 {
+		total = usage.PromptTokens + usage.CompletionTokens
+	}
+	// 同一个流里出现多个 usage 帧时累加 (例如多轮工具调用)
+	p.tokens += total
+}
+
+// Tokens 返回目前为止观测到的 Token 消耗
+func (p *sseUsageParser) Tokens() int {
+	return p.tokens
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSSEUsageParser(t *testing.T) {
+	cases := []struct {
+		name   string
+		writes []string // 每个元素是一次 Write
+		want   int
+	}{
+		{
+			name:   "single frame",
+			writes: []string{"event: usage\ndata: {\"total_tokens\":42}\n\n"},
+			want:   42,
+		},
+		{
+			name:   "frame split mid-line across writes",
+			writes: []string{"event: us", "age\ndata: {\"total_tok", "ens\":42}", "\n", "\n"},
+			want:   42,
+		},
+		{
+			name:   "CRLF line endings",
+			writes: []string{"event: usage\r\ndata: {\"total_tokens\":42}\r\n\r\n"},
+			want:   42,
+		},
+		{
+			name:   "multi-line data is joined",
+			writes: []string{"event: usage\ndata: {\"prompt_tokens\":10,\ndata: \"completion_tokens\":5}\n\n"},
+			want:   15,
+		},
+		{
+			name: "several usage frames are summed",
+			writes: []string{
+				"event: usage\ndata: {\"total_tokens\":10}\n\n",
+				"event: message\ndata: {\"total_tokens\":1000}\n\n",
+				"event: usage\ndata: {\"total_tokens\":20}\n\n",
+			},
+			want: 30,
+		},
+		{
+			name:   "frame without a trailing blank line is not counted",
+			writes: []string{"event: usage\ndata: {\"total_tokens\":42}\n"},
+			want:   0,
+		},
+		{
+			name:   "malformed usage is ignored",
+			writes: []string{"event: usage\ndata: {not json}\n\nevent: usage\ndata: {\"total_tokens\":7}\n\n"},
+			want:   7,
+		},
+		{
+			name: "line longer than maxSSELine is dropped",
+			writes: []string{
+				"event: usage\ndata: {\"total_tokens\":1" + strings.Repeat(" ", maxSSELine) + "}\n\n",
+				"event: usage\ndata: {\"total_tokens\":5}\n\n",
+			},
+			want: 5,
+		},
+		{
+			name: "overlong line split across writes is dropped",
+			writes: []string{
+				"event: usage\ndata: {\"total_tokens\":1",
+				strings.Repeat(" ", maxSSELine),
+				"}\n\nevent: usage\ndata: {\"total_tokens\":5}\n\n",
+			},
+			want: 5,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var p sseUsageParser
+			for _, w := range tc.writes {
+				p.Feed([]byte(w))
+			}
+			if got := p.Tokens(); got != tc.want {
+				t.Errorf("Tokens() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHeaderTokenUsage(t *testing.T) {
+	cases := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{name: "undeclared trailer", header: http.Header{http.TrailerPrefix + "X-Token-Usage": {"42"}}, want: 42},
+		{name: "declared trailer", header: http.Header{"X-Token-Usage": {"42"}}, want: 42},
+		{name: "trailer wins over header", header: http.Header{http.TrailerPrefix + "X-Token-Usage": {"42"}, "X-Token-Usage": {"1"}}, want: 42},
+		{name: "missing", header: http.Header{}, want: 0},
+		{name: "malformed", header: http.Header{"X-Token-Usage": {"lots"}}, want: 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := headerTokenUsage(tc.header); got != tc.want {
+				t.Errorf("headerTokenUsage() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package model
+
+import "encoding/json"
+
+// JSONRPCVersion JSON-RPC 协议版本 (MCP 基于 JSON-RPC 2.0)
+const JSONRPCVersion = "2.0"
+
+// JSON-RPC 2.0 标准错误码
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+)
+
+// JSONRPCRequest JSON-RPC 请求/通知 (没有 id 的就是通知)
+type JSONRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// IsNotification 通知不需要响应
+func (r *JSONRPCRequest) IsNotification() bool {
+	return len(r.ID) == 0
+}
+
+// JSONRPCResponse JSON-RPC 响应 (Result 和 Error 二选一)
+type JSONRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+}
+
+// JSONRPCNotification 服务端主动发出的通知 (如进度)
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// JSONRPCError JSON-RPC 错误对象
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,94 @@
+package model
+
+import "encoding/json"
+
+// MCPProtocolVersion 支持 Streamable HTTP 传输的 MCP 协议版本
+const MCPProtocolVersion = "2025-03-26"
+
+// MCPSessionHeader Streamable HTTP 的会话头
+const MCPSessionHeader = "Mcp-Session-Id"
+
+// MCPImplementation 客户端/服务端身份信息
+type MCPImplementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// MCPInitializeParams initialize 请求参数
+type MCPInitializeParams struct {
+	ProtocolVersion string            `json:"protocolVersion"`
+	ClientInfo      MCPImplementation `json:"clientInfo"`
+}
+
+// MCPInitializeResult initialize 响应
+type MCPInitializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ServerInfo      MCPImplementation      `json:"serverInfo"`
+}
+
+// MCPTool 工具描述 (tools/list)
+type MCPTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// MCPCallToolParams tools/call 请求参数
+type MCPCallToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Meta      *MCPRequestMeta        `json:"_meta,omitempty"`
+}
+
+// MCPRequestMeta 请求的 _meta 字段 (携带进度令牌)
+type MCPRequestMeta struct {
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+}
+
+// MCPContent 工具/提示词返回的内容块
+type MCPContent struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// MCPCallToolResult tools/call 响应
+type MCPCallToolResult struct {
+	Content []MCPContent           `json:"content"`
+	IsError bool                   `json:"isError,omitempty"`
+	Meta    map[string]interface{} `json:"_meta,omitempty"`
+}
+
+// MCPResource 资源描述 (resources/list)
+type MCPResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// MCPResourceContents 资源内容 (resources/read)
+type MCPResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// MCPPrompt 提示词模板描述 (prompts/list)
+type MCPPrompt struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// MCPPromptMessage 提示词消息 (prompts/get)
+type MCPPromptMessage struct {
+	Role    string     `json:"role"`
+	Content MCPContent `json:"content"`
+}
+
+// MCPProgressParams notifications/progress 参数
+type MCPProgressParams struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      int         `json:"progress"`
+	Total         int         `json:"total,omitempty"`
+}
@@ -0,0 +1,58 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Distribution 随机分布，用于服务时间 (秒)、Token 数量和出价
+// 所有采样都走仿真器的随机源，同一个种子结果完全可复现
+type Distribution interface {
+	Sample(r *rand.Rand) float64
+	Mean() float64
+}
+
+// Const 常数
+type Const float64
+
+func (c Const) Sample(*rand.Rand) float64 { return float64(c) }
+func (c Const) Mean() float64             { return float64(c) }
+
+// Exponential 指数分布 (M/M/c 中的 "M")
+type Exponential struct {
+	MeanValue float64
+}
+
+func (e Exponential) Sample(r *rand.Rand) float64 { return r.ExpFloat64() * e.MeanValue }
+func (e Exponential) Mean() float64               { return e.MeanValue }
+
+// Uniform [Min, Max) 均匀分布
+type Uniform struct {
+	Min, Max float64
+}
+
+func (u Uniform) Sample(r *rand.Rand) float64 { return u.Min + r.Float64()*(u.Max-u.Min) }
+func (u Uniform) Mean() float64               { return (u.Min + u.Max) / 2 }
+
+// LogNormal 对数正态分布，按均值和 Sigma (对数尺度的标准差) 参数化
+// LLM 的输出长度、工具调用耗时通常是长尾的，用它比指数分布更贴近真实
+type LogNormal struct {
+	MeanValue float64
+	Sigma     float64
+}
+
+func (l LogNormal) Sample(r *rand.Rand) float64 {
+	mu := math.Log(l.MeanValue) - l.Sigma*l.Sigma/2
+	return math.Exp(mu + l.Sigma*r.NormFloat64())
+}
+
+func (l LogNormal) Mean() float64 { return l.MeanValue }
+
+// seconds 把以秒为单位的采样值转换成 time.Duration
+func seconds(x float64) time.Duration {
+	if x < 0 {
+		return 0
+	}
+	return time.Duration(x * float64(time.Second))
+}
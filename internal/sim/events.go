@@ -0,0 +1,68 @@
+package sim
+
+import (
+	"container/heap"
+	"time"
+)
+
+// eventKind 事件类型
+type eventKind int
+
+const (
+	eventArrival   eventKind = iota // 客户端请求到达
+	eventDeparture                  // 请求在后端处理完成
+	eventTick                       // 控制器调价周期
+	eventSample                     // 时间序列采样
+)
+
+type event struct {
+	at   time.Duration // 虚拟时间 (相对仿真开始)
+	seq  uint64        // 同一时刻按入队顺序处理，保证确定性
+	kind eventKind
+	pop  int  // eventArrival: 客户端群体下标
+	job  *job // eventDeparture: 完成的请求
+}
+
+// eventQueue 按 (at, seq) 排序的最小堆
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	n := len(old)
+	ev := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return ev
+}
+
+// clock 虚拟时钟 + 事件队列
+type clock struct {
+	now   time.Duration
+	seq   uint64
+	queue eventQueue
+}
+
+func (c *clock) schedule(ev *event) {
+	c.seq++
+	ev.seq = c.seq
+	heap.Push(&c.queue, ev)
+}
+
+// next 取出下一个事件并把时钟拨到该事件的时间
+func (c *clock) next() *event {
+	if len(c.queue) == 0 {
+		return nil
+	}
+	ev := heap.Pop(&c.queue).(*event)
+	c.now = ev.at
+	return ev
+}
@@ -0,0 +1,137 @@
+package sim
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Point 一个采样区间的时间序列点 (计数都是区间内的增量)
+type Point struct {
+	T             time.Duration // 区间结束时刻 (虚拟时间)
+	Price         int
+	Cost          float64
+	EWMALatencyMs float64
+	Arrivals      int
+	Admitted      int
+	Rejected      int // 出价低于价格被网关拒绝
+	Dropped       int // 放行后后端队列已满被丢弃
+	Completed     int
+	Good          int     // 满足 SLO 的完成数
+	Goodput       float64 // Good / 区间长度 (请求/秒)
+	MeanLatencyMs float64
+	QueueLen      int
+	Busy          int
+}
+
+// PopulationStats 一类客户端在整个仿真期间的汇总
+type PopulationStats struct {
+	Name        string
+	Arrivals    int
+	Admitted    int
+	Rejected    int
+	Dropped     int
+	Completed   int
+	Good        int
+	Goodput     float64 // 请求/秒
+	MeanLatency time.Duration
+	P99Latency  time.Duration
+}
+
+// RejectionRate 被网关拒绝的比例
+func (p PopulationStats) RejectionRate() float64 {
+	if p.Arrivals == 0 {
+		return 0
+	}
+	return float64(p.Rejected) / float64(p.Arrivals)
+}
+
+// Result 一次仿真的结果
+type Result struct {
+	Config      Config
+	Series      []Point
+	Populations []PopulationStats
+	Events      int // 处理的事件总数
+}
+
+// Goodput 整体 goodput (请求/秒)
+func (r *Result) Goodput() float64 {
+	total := 0.0
+	for _, p := range r.Populations {
+		total += p.Goodput
+	}
+	return total
+}
+
+// RejectionRate 整体被网关拒绝的比例
+func (r *Result) RejectionRate() float64 {
+	arrivals, rejected := 0, 0
+	for _, p := range r.Populations {
+		arrivals += p.Arrivals
+		rejected += p.Rejected
+	}
+	if arrivals == 0 {
+		return 0
+	}
+	return float64(rejected) / float64(arrivals)
+}
+
+// MeanPrice 时间序列上的平均价格
+func (r *Result) MeanPrice() float64 {
+	if len(r.Series) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, p := range r.Series {
+		sum += p.Price
+	}
+	return float64(sum) / float64(len(r.Series))
+}
+
+// MaxPrice 时间序列上的最高价格
+func (r *Result) MaxPrice() int {
+	max := 0
+	for _, p := range r.Series {
+		if p.Price > max {
+			max = p.Price
+		}
+	}
+	return max
+}
+
+// Population 按名称查找客户端汇总
+func (r *Result) Population(name string) (PopulationStats, bool) {
+	for _, p := range r.Populations {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PopulationStats{}, false
+}
+
+// WriteCSV 把时间序列写成 CSV，方便画图
+func (r *Result) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"t_seconds", "price", "cost", "ewma_latency_ms", "arrivals", "admitted", "rejected",
+		"dropped", "completed", "goodput", "mean_latency_ms", "queue_len", "busy"})
+	for _, p := range r.Series {
+		cw.Write([]string{
+			strconv.FormatFloat(p.T.Seconds(), 'f', 3, 64),
+			strconv.Itoa(p.Price),
+			strconv.FormatFloat(p.Cost, 'f', 2, 64),
+			strconv.FormatFloat(p.EWMALatencyMs, 'f', 2, 64),
+			strconv.Itoa(p.Arrivals),
+			strconv.Itoa(p.Admitted),
+			strconv.Itoa(p.Rejected),
+			strconv.Itoa(p.Dropped),
+			strconv.Itoa(p.Completed),
+			strconv.FormatFloat(p.Goodput, 'f', 2, 64),
+			strconv.FormatFloat(p.MeanLatencyMs, 'f', 2, 64),
+			strconv.Itoa(p.QueueLen),
+			strconv.Itoa(p.Busy),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,328 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"rajomon-gateway/internal/controller"
+	"sort"
+	"time"
+)
+
+// Population 一类客户端：泊松到达，每个请求按 Bid 分布出价
+type Population struct {
+	Name string
+	Rate float64 // 平均到达速率 (请求/秒)
+	// RateAt 可选：随虚拟时间变化的到达速率 (阶跃、突发)，设置后覆盖 Rate
+	RateAt func(t time.Duration) float64
+	Bid    Distribution
+}
+
+func (p *Population) rate(t time.Duration) float64 {
+	if p.RateAt != nil {
+		return p.RateAt(t)
+	}
+	return p.Rate
+}
+
+// BackendConfig M/M/c 后端：Servers 个并行服务台 + FIFO 队列
+// 服务时间和 Token 消耗分布可以替换成常数、均匀、对数正态 (此时是 M/G/c)
+type BackendConfig struct {
+	Servers    int
+	Service    Distribution // 服务时间 (秒)
+	Tokens     Distribution // 每个请求的 Token 消耗
+	QueueLimit int          // 队列上限，0 表示不限；队列满时请求被丢弃 (dropped)
+}
+
+// Config 仿真配置
+type Config struct {
+	Duration       time.Duration // 仿真的虚拟时长
+	SampleInterval time.Duration // 时间序列的采样间隔
+	Seed           int64
+	Key            string        // 定价 Key
+	SLO            time.Duration // 完成耗时不超过 SLO 的请求才计入 goodput，0 表示都计入
+
+	Backend     BackendConfig
+	Populations []Population
+
+	// Policy 定价策略，为空时使用默认的综合成本策略
+	// 采样驱动的策略 (queuing) 读取的是真实的调度器延迟，不能用于仿真
+	Policy controller.PricingPolicy
+	// UpdateLoop 非空时按虚拟时钟周期调价，否则每个请求结束都调价
+	UpdateLoop *controller.UpdateLoopConfig
+}
+
+// DefaultConfig 4 个服务台、平均 200ms 服务时间 (容量约 20 req/s)，
+// 三类客户端合计 25 req/s 的轻微过载，仿真 5 分钟
+// 默认使用周期性调价：逐请求调价模式下价格一旦高于所有出价就不再有完成的请求，价格也就不会回落
+func DefaultConfig() Config {
+	loop := controller.DefaultUpdateLoopConfig()
+	return Config{
+		UpdateLoop:     &loop,
+		Duration:       5 * time.Minute,
+		SampleInterval: time.Second,
+		Seed:           1,
+		Key:            "/sim",
+		Backend: BackendConfig{
+			Servers: 4,
+			Service: Exponential{MeanValue: 0.2},
+			Tokens:  Exponential{MeanValue: 150},
+		},
+		Populations: []Population{
+			{Name: "low", Rate: 10, Bid: Const(10)},
+			{Name: "mid", Rate: 10, Bid: Const(30)},
+			{Name: "high", Rate: 5, Bid: Const(100)},
+		},
+	}
+}
+
+// job 一个被放行的请求
+type job struct {
+	pop     int
+	arrival time.Duration
+	tokens  int
+	service time.Duration
+}
+
+// Simulator 离散事件仿真器：虚拟时钟驱动，不 sleep，不起 goroutine
+type Simulator struct {
+	cfg   Config
+	rng   *rand.Rand
+	clock clock
+	ctrl  *controller.RajomonController
+
+	busy  int
+	queue []*job
+
+	series  []Point
+	current Point // 当前采样区间的累计
+	latSum  time.Duration
+	pops    []PopulationStats
+	popLat  [][]time.Duration
+	events  int
+}
+
+// New 校验配置并创建仿真器
+func New(cfg Config) (*Simulator, error) {
+	if cfg.Duration <= 0 {
+		return nil, errors.New("sim: Duration 必须大于 0")
+	}
+	if cfg.SampleInterval <= 0 {
+		cfg.SampleInterval = time.Second
+	}
+	if cfg.Key == "" {
+		cfg.Key = "/sim"
+	}
+	if cfg.Backend.Servers <= 0 || cfg.Backend.Service == nil {
+		return nil, errors.New("sim: 后端需要至少一个服务台和服务时间分布")
+	}
+	if cfg.Backend.Tokens == nil {
+		cfg.Backend.Tokens = Const(0)
+	}
+	if len(cfg.Populations) == 0 {
+		return nil, errors.New("sim: 至少需要一类客户端")
+	}
+	for i, p := range cfg.Populations {
+		if p.Bid == nil {
+			return nil, fmt.Errorf("sim: 客户端 %d (%s) 缺少出价分布", i, p.Name)
+		}
+	}
+
+	policy := cfg.Policy
+	if policy == nil {
+		policy = controller.NewCompositeCostPolicy(controller.DefaultCompositeCostConfig())
+	}
+	if _, ok := policy.(controller.SampledPolicy); ok {
+		return nil, fmt.Errorf("sim: 采样驱动的策略 %q 依赖真实调度器延迟，不能用于仿真", policy.Name())
+	}
+	ctrl := controller.NewControllerWithPolicy(policy)
+	if cfg.UpdateLoop != nil {
+		ctrl.UseUpdateLoop(*cfg.UpdateLoop)
+	}
+
+	s := &Simulator{
+		cfg:    cfg,
+		rng:    rand.New(rand.NewSource(cfg.Seed)),
+		ctrl:   ctrl,
+		pops:   make([]PopulationStats, len(cfg.Populations)),
+		popLat: make([][]time.Duration, len(cfg.Populations)),
+	}
+	for i, p := range cfg.Populations {
+		s.pops[i].Name = p.Name
+	}
+	return s, nil
+}
+
+// Run 使用给定配置跑一次仿真
+func Run(cfg Config) (*Result, error) {
+	s, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s.Run(), nil
+}
+
+// Controller 被仿真驱动的控制器 (可以在 Run 之前调整策略)
+func (s *Simulator) Controller() *controller.RajomonController {
+	return s.ctrl
+}
+
+// Run 推进虚拟时钟直到 Duration，返回时间序列和汇总
+func (s *Simulator) Run() *Result {
+	for i := range s.cfg.Populations {
+		s.scheduleArrival(i)
+	}
+	if s.cfg.UpdateLoop != nil {
+		s.clock.schedule(&event{at: s.cfg.UpdateLoop.Interval, kind: eventTick})
+	}
+	s.clock.schedule(&event{at: s.cfg.SampleInterval, kind: eventSample})
+
+	for {
+		ev := s.clock.next()
+		if ev == nil || ev.at > s.cfg.Duration {
+			break
+		}
+		s.events++
+		switch ev.kind {
+		case eventArrival:
+			s.onArrival(ev)
+		case eventDeparture:
+			s.onDeparture(ev.job)
+		case eventTick:
+			s.ctrl.Tick()
+			s.clock.schedule(&event{at: ev.at + s.cfg.UpdateLoop.Interval, kind: eventTick})
+		case eventSample:
+			s.sample()
+			s.clock.schedule(&event{at: ev.at + s.cfg.SampleInterval, kind: eventSample})
+		}
+	}
+	return s.result()
+}
+
+// idlePoll 到达速率为 0 时，隔多久再检查一次速率
+const idlePoll = 100 * time.Millisecond
+
+// scheduleArrival 按当前速率抽取下一次到达的间隔 (指数分布)
+func (s *Simulator) scheduleArrival(pop int) {
+	rate := s.cfg.Populations[pop].rate(s.clock.now)
+	if rate <= 0 {
+		// 空闲阶段：不产生请求，稍后重新检查速率
+		s.clock.schedule(&event{at: s.clock.now + idlePoll, kind: eventArrival, pop: pop, job: idleMarker})
+		return
+	}
+	gap := seconds(s.rng.ExpFloat64() / rate)
+	s.clock.schedule(&event{at: s.clock.now + gap, kind: eventArrival, pop: pop})
+}
+
+// idleMarker 标记 "只是重新检查速率" 的到达事件
+var idleMarker = &job{}
+
+// onArrival 网关准入：出价 >= 价格才放行，放行后进入后端 (空闲服务台或队列)
+func (s *Simulator) onArrival(ev *event) {
+	defer s.scheduleArrival(ev.pop)
+	if ev.job == idleMarker {
+		return
+	}
+
+	p := &s.cfg.Populations[ev.pop]
+	stats := &s.pops[ev.pop]
+	stats.Arrivals++
+	s.current.Arrivals++
+
+	price := s.ctrl.GetPrice(s.cfg.Key)
+	bid := int(math.Round(p.Bid.Sample(s.rng)))
+	if bid < price {
+		stats.Rejected++
+		s.current.Rejected++
+		return
+	}
+
+	j := &job{
+		pop:     ev.pop,
+		arrival: s.clock.now,
+		tokens:  int(math.Round(s.cfg.Backend.Tokens.Sample(s.rng))),
+		service: seconds(s.cfg.Backend.Service.Sample(s.rng)),
+	}
+	if s.busy < s.cfg.Backend.Servers {
+		s.start(j)
+	} else if s.cfg.Backend.QueueLimit > 0 && len(s.queue) >= s.cfg.Backend.QueueLimit {
+		stats.Dropped++
+		s.current.Dropped++
+		return
+	} else {
+		s.queue = append(s.queue, j)
+	}
+	stats.Admitted++
+	s.current.Admitted++
+}
+
+func (s *Simulator) start(j *job) {
+	s.busy++
+	s.clock.schedule(&event{at: s.clock.now + j.service, kind: eventDeparture, job: j})
+}
+
+// onDeparture 请求完成：把端到端耗时 (排队 + 服务) 和 Token 消耗交给控制器
+func (s *Simulator) onDeparture(j *job) {
+	s.busy--
+	if len(s.queue) > 0 {
+		next := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.start(next)
+	}
+
+	latency := s.clock.now - j.arrival
+	s.ctrl.RecordLatency(s.cfg.Key, latency, j.tokens)
+
+	stats := &s.pops[j.pop]
+	stats.Completed++
+	s.current.Completed++
+	s.latSum += latency
+	s.popLat[j.pop] = append(s.popLat[j.pop], latency)
+	if s.cfg.SLO == 0 || latency <= s.cfg.SLO {
+		stats.Good++
+		s.current.Good++
+	}
+}
+
+// sample 结束一个采样区间
+func (s *Simulator) sample() {
+	p := s.current
+	p.T = s.clock.now
+	p.Price = s.ctrl.GetPrice(s.cfg.Key)
+	if state, ok := s.ctrl.State(s.cfg.Key); ok {
+		p.Cost = state.Cost
+		p.EWMALatencyMs = state.EWMALatency
+	}
+	p.Goodput = float64(p.Good) / s.cfg.SampleInterval.Seconds()
+	if p.Completed > 0 {
+		p.MeanLatencyMs = float64(s.latSum.Microseconds()) / 1000 / float64(p.Completed)
+	}
+	p.QueueLen = len(s.queue)
+	p.Busy = s.busy
+	s.series = append(s.series, p)
+
+	s.current = Point{}
+	s.latSum = 0
+}
+
+func (s *Simulator) result() *Result {
+	r := &Result{Config: s.cfg, Series: s.series, Events: s.events}
+	for i := range s.pops {
+		stats := s.pops[i]
+		lat := s.popLat[i]
+		stats.Goodput = float64(stats.Good) / s.cfg.Duration.Seconds()
+		if len(lat) > 0 {
+			var sum time.Duration
+			for _, l := range lat {
+				sum += l
+			}
+			stats.MeanLatency = sum / time.Duration(len(lat))
+			sort.Slice(lat, func(a, b int) bool { return lat[a] < lat[b] })
+			stats.P99Latency = lat[int(math.Ceil(0.99*float64(len(lat))))-1]
+		}
+		r.Populations = append(r.Populations, stats)
+	}
+	return r
+}
@@ -0,0 +1,159 @@
+package sim
+
+import (
+	"io"
+	"os"
+	"rajomon-gateway/internal/controller"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// 每次调价都会打日志，仿真时关掉
+	controller.SetLogOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	a, err := Run(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Run(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a.Series, b.Series) || !reflect.DeepEqual(a.Populations, b.Populations) {
+		t.Fatal("same seed produced different results")
+	}
+
+	cfg := DefaultConfig()
+	cfg.Seed = 2
+	c, _ := Run(cfg)
+	if reflect.DeepEqual(a.Series, c.Series) {
+		t.Fatal("different seeds produced identical series")
+	}
+}
+
+func TestSimulatesMinutesQuickly(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Duration = 10 * time.Minute
+
+	start := time.Now()
+	r, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("10 virtual minutes took %v of wall time", elapsed)
+	}
+	if got, want := len(r.Series), 600; got != want {
+		t.Fatalf("len(Series) = %d, want %d", got, want)
+	}
+}
+
+func TestOverloadPricesOutLowBidders(t *testing.T) {
+	r, err := Run(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, _ := r.Population("low")
+	high, _ := r.Population("high")
+
+	if low.RejectionRate() < 0.5 {
+		t.Errorf("low bidders rejection rate = %.2f, want >= 0.5 under overload", low.RejectionRate())
+	}
+	if high.RejectionRate() > 0.1 {
+		t.Errorf("high bidders rejection rate = %.2f, want <= 0.1", high.RejectionRate())
+	}
+	// 容量约 20 req/s，goodput 不应超过容量
+	if g := r.Goodput(); g <= 0 || g > 21 {
+		t.Errorf("Goodput() = %.2f, want in (0, 21]", g)
+	}
+}
+
+func TestUnderloadKeepsPriceLow(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Populations = []Population{{Name: "light", Rate: 4, Bid: Const(5)}}
+
+	r, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate := r.RejectionRate(); rate > 0.05 {
+		t.Fatalf("RejectionRate() = %.2f at 20%% utilisation, want <= 0.05", rate)
+	}
+}
+
+func TestSpikeRaisesPriceThenRecovers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Duration = 3 * time.Minute
+	cfg.Populations = []Population{{
+		Name: "spiky",
+		Bid:  Uniform{Min: 0, Max: 100},
+		RateAt: func(t time.Duration) float64 {
+			if t >= time.Minute && t < 90*time.Second {
+				return 60 // 3 倍容量的突发
+			}
+			return 5
+		},
+	}}
+
+	r, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := r.Series[55].Price
+	peak := 0
+	for _, p := range r.Series[60:90] {
+		if p.Price > peak {
+			peak = p.Price
+		}
+	}
+	after := r.Series[len(r.Series)-1].Price
+	if peak <= before {
+		t.Errorf("peak price during spike = %d, want above pre-spike price %d", peak, before)
+	}
+	if after >= peak {
+		t.Errorf("price after spike = %d, want below peak %d", after, peak)
+	}
+}
+
+func TestRejectsSampledPolicy(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Policy = controller.NewQueuingDelayPolicy(controller.DefaultQueuingDelayConfig())
+	if _, err := New(cfg); err == nil {
+		t.Fatal("New with queuing policy succeeded, want error")
+	}
+}
+
+// TestSweepCompositeParams 对综合成本策略的三个核心参数做网格扫描
+// go test ./internal/sim -run Sweep -v 可以看到每组参数的结果
+func TestSweepCompositeParams(t *testing.T) {
+	for _, alpha := range []float64{0.1, 0.2, 0.5} {
+		for _, threshold := range []float64{150, 200, 300} {
+			for _, stepUnit := range []float64{25, 50, 100} {
+				pc := controller.DefaultCompositeCostConfig()
+				pc.Alpha, pc.BaseThreshold, pc.PriceStepUnit = alpha, threshold, stepUnit
+
+				cfg := DefaultConfig()
+				cfg.Policy = controller.NewCompositeCostPolicy(pc)
+				cfg.SLO = time.Second
+
+				r, err := Run(cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				high, _ := r.Population("high")
+				t.Logf("alpha=%.1f threshold=%3.0f step=%3.0f | goodput %5.2f/s | reject %4.1f%% | price mean %6.1f max %4d | high p99 %v",
+					alpha, threshold, stepUnit, r.Goodput(), r.RejectionRate()*100, r.MeanPrice(), r.MaxPrice(),
+					high.P99Latency.Round(time.Millisecond))
+
+				if r.Goodput() <= 0 {
+					t.Errorf("alpha=%v threshold=%v step=%v: no goodput", alpha, threshold, stepUnit)
+				}
+			}
+		}
+	}
+}
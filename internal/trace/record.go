@@ -0,0 +1,144 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"rajomon-gateway/internal/metrics"
+	"sync"
+	"time"
+)
+
+// Record 一条准入决策 + 请求形态，JSONL 中的一行
+type Record struct {
+	Time      time.Time         `json:"ts"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`             // 请求 URI (路径 + 查询参数)
+	Key       string            `json:"key"`              // 定价 Key
+	Client    string            `json:"client,omitempty"` // 账户身份 (启用账户时)，否则为 Client-ID 或来源 IP
+	Bid       int               `json:"bid"`              // 生效的出价
+	Price     int               `json:"price"`            // 决策时的价格
+	Status    string            `json:"status"`           // 准入结果，与 rajomon_requests_total 的 status 标签一致
+	Code      int               `json:"code"`             // HTTP 状态码
+	LatencyMs float64           `json:"latency_ms"`
+	Tokens    int               `json:"tokens"`
+	Header    map[string]string `json:"header,omitempty"` // 回放需要的原始请求头 (Token / Client-ID / Content-Type ...)
+	Body      string            `json:"body,omitempty"`   // 请求体 (超过 BodyLimit 时不记录)
+}
+
+// replayHeaders 记录并在回放时原样还原的请求头
+// Bid 是生效的出价 (启用账户时可能是余额)，回放时以原始 Token 头为准，才能复现同样的 All-in 行为
+// 凭证 (Authorization / X-API-Key) 不落盘，回放时按 Client 从 ReplayConfig.APIKeys 重新附加
+var replayHeaders = []string{"Token", "Client-ID", "Content-Type", "Accept", "Mcp-Session-Id"}
+
+// RequestHeader 提取回放需要的请求头
+func RequestHeader(h interface{ Get(string) string }) map[string]string {
+	var out map[string]string
+	for _, name := range replayHeaders {
+		if v := h.Get(name); v != "" {
+			if out == nil {
+				out = make(map[string]string)
+			}
+			out[name] = v
+		}
+	}
+	return out
+}
+
+// DefaultBodyLimit 默认最多记录 64KB 的请求体
+const DefaultBodyLimit = 64 << 10
+
+// Recorder 异步把 Record 写成 JSONL
+// 写入走带缓冲的 channel，不阻塞请求路径；缓冲满时丢弃并计数 (rajomon_trace_dropped_total)
+type Recorder struct {
+	// BodyLimit 最多记录多少字节的请求体，0 表示不记录请求体
+	BodyLimit int
+
+	mu     sync.RWMutex
+	closed bool
+	ch     chan Record
+	done   chan struct{}
+	w      *bufio.Writer
+	closer io.Closer
+}
+
+// NewRecorder 写入任意 io.Writer；如果 w 同时实现 io.Closer，Close 时一并关闭
+func NewRecorder(w io.Writer) *Recorder {
+	r := &Recorder{
+		BodyLimit: DefaultBodyLimit,
+		ch:        make(chan Record, 4096),
+		done:      make(chan struct{}),
+		w:         bufio.NewWriter(w),
+	}
+	if c, ok := w.(io.Closer); ok {
+		r.closer = c
+	}
+	go r.loop()
+	return r
+}
+
+// OpenFile 以追加方式打开 (或创建) JSONL 文件
+func OpenFile(path string) (*Recorder, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder(f), nil
+}
+
+// Record 提交一条记录，不阻塞
+func (r *Recorder) Record(rec Record) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.ch <- rec:
+	default:
+		metrics.TraceDropped.Inc()
+	}
+}
+
+// Close 写完缓冲中的记录后关闭
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.ch)
+	r.mu.Unlock()
+
+	<-r.done
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+func (r *Recorder) loop() {
+	defer close(r.done)
+	enc := json.NewEncoder(r.w)
+	for rec := range r.ch {
+		if err := enc.Encode(rec); err != nil {
+			fmt.Printf("⚠️ [Trace] 写入失败: %v\n", err)
+		}
+		// 缓冲里暂时没有更多记录时落盘，进程被直接杀掉也最多丢失一批
+		if len(r.ch) == 0 {
+			if err := r.w.Flush(); err != nil {
+				fmt.Printf("⚠️ [Trace] 落盘失败: %v\n", err)
+			}
+		}
+	}
+	r.w.Flush()
+}
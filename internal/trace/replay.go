@@ -0,0 +1,165 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayConfig 回放配置
+type ReplayConfig struct {
+	Target string // 网关或后端的基地址，例如 http://localhost:8080
+	// Speed 回放倍速：1 按原始节奏，2 两倍速，0 不等待尽快发出
+	Speed       float64
+	Timeout     time.Duration // 单个请求超时
+	MaxInFlight int           // 在途请求上限，超出时等待 (回放以完整重放为准，不丢请求)
+	Client      *http.Client  // 为空时按 Timeout 新建
+	// APIKeys 客户端 -> API Key：网关启用账户时，按记录的 Client 附加 Authorization: Bearer <key>
+	// 追踪文件里不保存凭证，没有对应 Key 的请求原样发出
+	APIKeys map[string]string
+}
+
+// DefaultReplayConfig 默认实时回放
+func DefaultReplayConfig() ReplayConfig {
+	return ReplayConfig{
+		Target:      "http://localhost:8080",
+		Speed:       1,
+		Timeout:     30 * time.Second,
+		MaxInFlight: 1000,
+	}
+}
+
+// KeyResult 按定价 Key 对比录制时与回放时的结果
+type KeyResult struct {
+	Sent     int         `json:"sent"`
+	Recorded map[int]int `json:"recorded"` // 录制时的状态码分布
+	Replayed map[int]int `json:"replayed"` // 回放时的状态码分布 (0 表示网络错误)
+}
+
+// ReplayResult 回放结果
+type ReplayResult struct {
+	Elapsed time.Duration         `json:"elapsed"`
+	Sent    int                   `json:"sent"`
+	Errors  int                   `json:"errors"`
+	Keys    map[string]*KeyResult `json:"keys"`
+}
+
+// Replay 按录制的相对时间 (除以 Speed) 重新发出请求，开环：不等待前一个请求结束
+func Replay(ctx context.Context, records []Record, cfg ReplayConfig) (*ReplayResult, error) {
+	if cfg.Speed < 0 {
+		return nil, fmt.Errorf("回放倍速不能为负数: %v", cfg.Speed)
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 1000
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+	target := strings.TrimRight(cfg.Target, "/")
+
+	result := &ReplayResult{Keys: make(map[string]*KeyResult)}
+	if len(records) == 0 {
+		return result, nil
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, cfg.MaxInFlight)
+	)
+	start := time.Now()
+	origin := records[0].Time
+	// 回放被取消：等在途请求结束，已发出部分的结果照常返回
+	cancelled := func() (*ReplayResult, error) {
+		wg.Wait()
+		result.Elapsed = time.Since(start)
+		return result, ctx.Err()
+	}
+
+	for _, rec := range records {
+		// 1. 等到该请求的回放时刻
+		if cfg.Speed > 0 {
+			due := time.Duration(float64(rec.Time.Sub(origin)) / cfg.Speed)
+			if wait := due - time.Since(start); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return cancelled()
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		// 2. 控制在途数量
+		select {
+		case <-ctx.Done():
+			return cancelled()
+		case sem <- struct{}{}:
+		}
+
+		mu.Lock()
+		kr := result.Keys[rec.Key]
+		if kr == nil {
+			kr = &KeyResult{Recorded: make(map[int]int), Replayed: make(map[int]int)}
+			result.Keys[rec.Key] = kr
+		}
+		kr.Sent++
+		kr.Recorded[rec.Code]++
+		result.Sent++
+		mu.Unlock()
+
+		// 3. 异步发出
+		wg.Add(1)
+		go func(rec Record) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			code := send(ctx, client, target, rec, cfg.APIKeys[rec.Client])
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Keys[rec.Key].Replayed[code]++
+			if code == 0 {
+				result.Errors++
+			}
+		}(rec)
+	}
+
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	return result, nil
+}
+
+// send 还原一个请求并读完响应，返回状态码 (网络错误返回 0)；apiKey 非空时附加为 Bearer 凭证
+func send(ctx context.Context, client *http.Client, target string, rec Record, apiKey string) int {
+	var body io.Reader
+	if rec.Body != "" {
+		body = strings.NewReader(rec.Body)
+	}
+	method := rec.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target+rec.Path, body)
+	if err != nil {
+		return 0
+	}
+	for k, v := range rec.Header {
+		req.Header.Set(k, v)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	// 读完响应体，复现流式响应在后端的真实占用时间
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
@@ -0,0 +1,232 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"rajomon-gateway/internal/metrics"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordCloseDecodeRoundTrip(t *testing.T) {
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := []Record{
+		{Time: base, Method: "POST", Path: "/mcp", Key: "/mcp:tools/call/echo", Client: "alice", Bid: 20, Price: 5,
+			Status: "accepted", Code: 200, LatencyMs: 12.5, Tokens: 5,
+			Header: map[string]string{"Token": "20", "Content-Type": "application/json"}, Body: `{"jsonrpc":"2.0"}`},
+		{Time: base.Add(time.Second), Method: "GET", Path: "/mcp/chat", Key: "/mcp/chat", Bid: 1, Price: 9, Status: "rejected_rajomon", Code: 429},
+	}
+
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	// 异步写入可能乱序，Decode 按时间排序
+	rec.Record(want[1])
+	rec.Record(want[0])
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	rec.Record(want[0]) // 关闭之后的记录被忽略
+
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("decoded %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !g.Time.Equal(w.Time) || g.Key != w.Key || g.Client != w.Client || g.Bid != w.Bid || g.Price != w.Price ||
+			g.Status != w.Status || g.Code != w.Code || g.LatencyMs != w.LatencyMs || g.Tokens != w.Tokens ||
+			g.Body != w.Body || len(g.Header) != len(w.Header) || g.Header["Token"] != w.Header["Token"] {
+			t.Errorf("record %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestDecodeReportsBadLine(t *testing.T) {
+	_, err := Decode(strings.NewReader("{\"path\":\"/a\"}\n\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "第 3 行") {
+		t.Errorf("Decode() error = %v, want it to name line 3", err)
+	}
+}
+
+// blockingWriter 第一次 Write 时通知 started，然后一直阻塞到 release 被关闭
+type blockingWriter struct {
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	return w.buf.Write(p)
+}
+
+func TestRecorderDropsWhenBufferFull(t *testing.T) {
+	w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	rec := NewRecorder(w)
+	before := testutil.ToFloat64(metrics.TraceDropped)
+
+	// 第一条记录让写入协程卡在落盘上，之后的记录只能进缓冲
+	rec.Record(Record{Path: "/first"})
+	<-w.started
+	for range cap(rec.ch) + 3 {
+		rec.Record(Record{Path: "/mcp"})
+	}
+	if got := testutil.ToFloat64(metrics.TraceDropped) - before; got != 3 {
+		t.Errorf("TraceDropped += %v, want 3", got)
+	}
+
+	close(w.release)
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	records, err := Decode(&w.buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if want := 1 + cap(rec.ch); len(records) != want {
+		t.Errorf("written %d records, want %d (everything that fit in the buffer)", len(records), want)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var mu sync.Mutex
+	tokens := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		tokens[r.Header.Get("Token")]++
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case r.Method == http.MethodPost && string(body) != `{"id":1}`:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	base := time.Now()
+	var records []Record
+	for i := range 3 {
+		records = append(records, Record{Time: base.Add(time.Duration(i) * time.Hour), Method: http.MethodPost, Path: "/ok", Key: "/ok",
+			Code: 200, Header: map[string]string{"Token": "7"}, Body: `{"id":1}`})
+	}
+	for i := range 2 {
+		records = append(records, Record{Time: base.Add(time.Duration(i) * time.Hour), Path: "/busy", Key: "/busy", Code: 200})
+	}
+
+	cfg := DefaultReplayConfig()
+	cfg.Target, cfg.Speed = srv.URL+"/", 0 // 不等待：相隔一小时的请求也立即发出
+	result, err := Replay(context.Background(), records, cfg)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	if result.Sent != 5 || result.Errors != 0 || result.Elapsed <= 0 {
+		t.Errorf("result = sent %d errors %d elapsed %v, want 5 sent, 0 errors and a positive elapsed time", result.Sent, result.Errors, result.Elapsed)
+	}
+	want := map[string]struct{ recorded, replayed map[int]int }{
+		"/ok":   {recorded: map[int]int{200: 3}, replayed: map[int]int{200: 3}},
+		"/busy": {recorded: map[int]int{200: 2}, replayed: map[int]int{429: 2}},
+	}
+	for key, w := range want {
+		kr := result.Keys[key]
+		if kr == nil {
+			t.Fatalf("no result for key %s", key)
+		}
+		if !equalCounts(kr.Recorded, w.recorded) || !equalCounts(kr.Replayed, w.replayed) {
+			t.Errorf("%s recorded %v replayed %v, want %v and %v", key, kr.Recorded, kr.Replayed, w.recorded, w.replayed)
+		}
+	}
+	if tokens["7"] != 3 {
+		t.Errorf("requests with the recorded Token header = %d, want 3", tokens["7"])
+	}
+}
+
+func TestReplayCancelledKeepsElapsed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	base := time.Now()
+	records := []Record{
+		{Time: base, Path: "/a", Key: "/a", Code: 200},
+		{Time: base.Add(time.Hour), Path: "/a", Key: "/a", Code: 200},
+	}
+	cfg := DefaultReplayConfig()
+	cfg.Target = srv.URL
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	result, err := Replay(ctx, records, cfg)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Replay() error = %v, want DeadlineExceeded", err)
+	}
+	if result.Sent != 1 || result.Keys["/a"].Replayed[200] != 1 {
+		t.Errorf("result = sent %d replayed %v, want the first request replayed", result.Sent, result.Keys["/a"].Replayed)
+	}
+	if result.Elapsed < 20*time.Millisecond {
+		t.Errorf("Elapsed = %v, want the time until cancellation", result.Elapsed)
+	}
+}
+
+func TestReplayRejectsNegativeSpeed(t *testing.T) {
+	cfg := DefaultReplayConfig()
+	cfg.Speed = -1
+	if _, err := Replay(context.Background(), []Record{{Path: "/a"}}, cfg); err == nil {
+		t.Error("Replay() with a negative speed succeeded, want an error")
+	}
+}
+
+func equalCounts(a, b map[int]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReplayAttachesAPIKeys(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]string) // 请求 URI -> Authorization
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.RequestURI()] = r.Header.Get("Authorization")
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	records := []Record{
+		{Path: "/mcp?client=alice", Key: "/mcp", Client: "alice"},
+		{Path: "/mcp?client=carol", Key: "/mcp", Client: "carol"},
+	}
+	cfg := DefaultReplayConfig()
+	cfg.Target, cfg.Speed = srv.URL, 0
+	cfg.APIKeys = map[string]string{"alice": "sk-alice"}
+	if _, err := Replay(context.Background(), records, cfg); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	want := map[string]string{"/mcp?client=alice": "Bearer sk-alice", "/mcp?client=carol": ""}
+	for uri, auth := range want {
+		if got, ok := seen[uri]; !ok || got != auth {
+			t.Errorf("%s replayed with Authorization %q (seen=%v), want %q", uri, got, ok, auth)
+		}
+	}
+}
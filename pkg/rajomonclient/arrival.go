@@ -0,0 +1,155 @@
+package rajomonclient
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ArrivalProcess 到达过程：每次调用 Next 返回距离下一次事件的间隔
+// 既用于代币生成 (多久充一次值)，也可以用于开环压测 (多久发一个请求)
+type ArrivalProcess interface {
+	Next() time.Duration
+}
+
+// minInterval 间隔下限，防止指数分布采样到 0 时空转
+const minInterval = time.Millisecond
+
+// randFloat64 / randExp 允许注入随机源，nil 时使用全局随机源
+func randFloat64(r *rand.Rand) float64 {
+	if r != nil {
+		return r.Float64()
+	}
+	return rand.Float64()
+}
+
+func randExp(r *rand.Rand) float64 {
+	if r != nil {
+		return r.ExpFloat64()
+	}
+	return rand.ExpFloat64()
+}
+
+// PoissonProcess 泊松过程：间隔服从均值为 Mean 的指数分布
+// 模拟真实世界中具有随机性和突发性的到达
+type PoissonProcess struct {
+	Mean time.Duration
+	Rand *rand.Rand
+}
+
+func (p *PoissonProcess) Next() time.Duration {
+	// 公式: interval = -ln(U) / lambda = Exp(1) * Mean
+	interval := time.Duration(randExp(p.Rand) * float64(p.Mean))
+	if interval < minInterval {
+		interval = minInterval
+	}
+	return interval
+}
+
+// FixedProcess 固定间隔 - 最死板，容易造成惊群效应
+type FixedProcess struct {
+	Interval time.Duration
+}
+
+func (p *FixedProcess) Next() time.Duration {
+	return p.Interval
+}
+
+// UniformProcess 间隔在 [0, 2*Mean] 之间均匀分布，平均值依然是 Mean
+type UniformProcess struct {
+	Mean time.Duration
+	Rand *rand.Rand
+}
+
+func (p *UniformProcess) Next() time.Duration {
+	interval := time.Duration(randFloat64(p.Rand) * 2 * float64(p.Mean))
+	if interval < minInterval {
+		interval = minInterval
+	}
+	return interval
+}
+
+// OnOffProcess 突发的开/关过程 (两状态 MMPP)
+// ON 状态下按 OnMean 的泊松过程到达，OFF 状态下按 OffMean 到达 (OffMean 为 0 表示完全静默)；
+// 两个状态的持续时间分别服从均值为 OnDuration / OffDuration 的指数分布
+type OnOffProcess struct {
+	OnMean      time.Duration
+	OffMean     time.Duration
+	OnDuration  time.Duration
+	OffDuration time.Duration
+	Rand        *rand.Rand
+
+	started   bool
+	on        bool
+	remaining time.Duration // 当前状态还剩多久
+}
+
+func (p *OnOffProcess) Next() time.Duration {
+	if !p.started {
+		p.started = true
+		p.on = true
+		p.remaining = p.stateDuration()
+	}
+
+	var elapsed time.Duration
+	for {
+		mean := p.OffMean
+		if p.on {
+			mean = p.OnMean
+		}
+		if mean > 0 {
+			// 指数分布无记忆，状态切换后重新采样不影响正确性
+			interval := time.Duration(randExp(p.Rand) * float64(mean))
+			if interval < p.remaining {
+				p.remaining -= interval
+				elapsed += interval
+				if elapsed < minInterval {
+					elapsed = minInterval
+				}
+				return elapsed
+			}
+		}
+		// 当前状态内没有下一次到达：切换状态
+		elapsed += p.remaining
+		p.on = !p.on
+		p.remaining = p.stateDuration()
+	}
+}
+
+func (p *OnOffProcess) stateDuration() time.Duration {
+	mean := p.OffDuration
+	if p.on {
+		mean = p.OnDuration
+	}
+	d := time.Duration(randExp(p.Rand) * float64(mean))
+	if d < minInterval {
+		d = minInterval
+	}
+	return d
+}
+
+// NewArrivalProcess 按名称创建到达过程，mean 为平均间隔
+// dist: "poisson" / "fixed" / "uniform" / "mmpp"
+// mmpp 模式下 ON 期间速率为平均值的 4 倍，OFF 期间静默，ON/OFF 各持续平均 2s/6s，整体平均速率与 mean 一致
+func NewArrivalProcess(dist string, mean time.Duration, r *rand.Rand) (ArrivalProcess, error) {
+	if mean <= 0 {
+		return nil, fmt.Errorf("平均间隔必须大于 0: %v", mean)
+	}
+	switch dist {
+	case "poisson":
+		return &PoissonProcess{Mean: mean, Rand: r}, nil
+	case "fixed":
+		return &FixedProcess{Interval: mean}, nil
+	case "uniform":
+		return &UniformProcess{Mean: mean, Rand: r}, nil
+	case "mmpp", "onoff":
+		return &OnOffProcess{
+			OnMean:      mean / 4,
+			OnDuration:  2 * time.Second,
+			OffDuration: 6 * time.Second,
+			Rand:        r,
+		}, nil
+	default:
+		return nil, fmt.Errorf("未知的到达过程: %q", dist)
+	}
+}
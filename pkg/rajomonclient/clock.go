@@ -0,0 +1,16 @@
+package rajomonclient
+
+import "time"
+
+// Clock 时间源，生成器通过它等待，测试时可以替换成手动推进的假时钟
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock 使用系统时间
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
+
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
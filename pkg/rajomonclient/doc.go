@@ -0,0 +1,18 @@
+// Package rajomonclient 是 Rajomon 网关的 Go 客户端 SDK。
+//
+// 它提供:
+//   - Wallet: 本地代币钱包，按出价策略 (BidStrategy) 出价
+//   - 代币生成器: 按到达过程 (泊松/固定/均匀/突发开关) 给钱包充值，时钟可注入
+//   - PriceCache: 从响应的 Price 头中嗅探并缓存各路由的最新价格，过期后重新探测
+//   - Transport: http.RoundTripper，自动附加出价 (Token 头)，
+//     出价低于缓存价格时在本地直接丢弃请求，避免白白消耗网关和网络资源
+//
+// 典型用法:
+//
+//	wallet := rajomonclient.NewWallet(50, 500)
+//	client := &http.Client{Transport: rajomonclient.NewTransport(wallet, rajomonclient.BidStrategyRandom)}
+//	resp, err := client.Get("http://localhost:8080/mcp/chat")
+//	if errors.Is(err, rajomonclient.ErrShed) {
+//		// 本地拦截：出价低于已知市价
+//	}
+package rajomonclient
@@ -0,0 +1,96 @@
+package rajomonclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenGenerator 代币生成器：按到达过程定期给钱包充值
+type TokenGenerator struct {
+	Wallet  *Wallet
+	Process ArrivalProcess
+	Step    int64 // 每次生成的数量
+	Clock   Clock // 为空时使用 RealClock
+
+	mu        sync.Mutex
+	started   time.Time
+	events    int64
+	generated int64
+	credited  int64 // 实际入账的数量 (钱包满了会被截断)
+}
+
+// GeneratorStats 生成器运行统计
+type GeneratorStats struct {
+	Elapsed   time.Duration
+	Events    int64
+	Generated int64   // 生成的代币总数
+	Credited  int64   // 实际入账的代币总数
+	Rate      float64 // 实际达到的生成速率 (个/秒)
+}
+
+// Run 阻塞运行直到 ctx 被取消
+func (g *TokenGenerator) Run(ctx context.Context) {
+	clock := g.clock()
+	g.mu.Lock()
+	g.started = clock.Now()
+	g.mu.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-clock.After(g.Process.Next()):
+			credited := g.Wallet.add(g.Step)
+			g.mu.Lock()
+			g.events++
+			g.generated += g.Step
+			g.credited += credited
+			g.mu.Unlock()
+		}
+	}
+}
+
+// Stats 返回运行统计
+func (g *TokenGenerator) Stats() GeneratorStats {
+	now := g.clock().Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := GeneratorStats{Events: g.events, Generated: g.generated, Credited: g.credited}
+	if !g.started.IsZero() {
+		stats.Elapsed = now.Sub(g.started)
+	}
+	if stats.Elapsed > 0 {
+		stats.Rate = float64(g.generated) / stats.Elapsed.Seconds()
+	}
+	return stats
+}
+
+// Rate 实际达到的生成速率 (个/秒)
+func (g *TokenGenerator) Rate() float64 {
+	return g.Stats().Rate
+}
+
+func (g *TokenGenerator) clock() Clock {
+	if g.Clock != nil {
+		return g.Clock
+	}
+	return RealClock{}
+}
+
+// StartTokenGenerator 启动代币生成器，ctx 取消时停止
+// distType: "poisson" (泊松), "fixed" (固定), "uniform" (均匀随机), "mmpp" (突发开关)
+// rate: 平均生成间隔 (例如 200ms 一次)
+// step: 每次生成的数量 (例如 10 个)
+func (w *Wallet) StartTokenGenerator(ctx context.Context, distType string, rate time.Duration, step int64) (*TokenGenerator, error) {
+	process, err := NewArrivalProcess(distType, rate, nil)
+	if err != nil {
+		return nil, err
+	}
+	g := &TokenGenerator{Wallet: w, Process: process, Step: step}
+	fmt.Printf("🔋 [Generator] 代币生成器启动 | 模式: %s | 速率: %v/次 | 步长: %d\n", distType, rate, step)
+	go g.Run(ctx)
+	return g, nil
+}
@@ -0,0 +1,182 @@
+package rajomonclient
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟，Advance 时触发到期的定时器
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []fakeTimer
+	waiting chan struct{}
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0), waiting: make(chan struct{}, 1)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	select {
+	case c.waiting <- struct{}{}:
+	default:
+	}
+	return ch
+}
+
+// step 等生成器挂上定时器后，直接跳到该定时器的到期时间
+func (c *fakeClock) step(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.waiting:
+	case <-time.After(time.Second):
+		t.Fatal("generator never waited on the clock")
+	}
+	c.mu.Lock()
+	timer := c.timers[0]
+	c.timers = c.timers[1:]
+	c.now = timer.at
+	c.mu.Unlock()
+	timer.ch <- timer.at
+}
+
+// runGenerator 推进 n 个事件后停止生成器，返回统计
+func runGenerator(t *testing.T, process ArrivalProcess, step int64, n int) (GeneratorStats, *Wallet) {
+	t.Helper()
+	clock := newFakeClock()
+	wallet := NewWallet(0, math.MaxInt64)
+	g := &TokenGenerator{Wallet: wallet, Process: process, Step: step, Clock: clock}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		g.Run(ctx)
+		close(done)
+	}()
+	for i := 0; i < n; i++ {
+		clock.step(t)
+	}
+	// 等最后一个事件入账 (生成器挂上下一个定时器)
+	<-clock.waiting
+	cancel()
+	<-done
+	return g.Stats(), wallet
+}
+
+func TestFixedGeneratorRate(t *testing.T) {
+	stats, wallet := runGenerator(t, &FixedProcess{Interval: 100 * time.Millisecond}, 10, 50)
+
+	if stats.Events != 50 || stats.Generated != 500 || wallet.Balance() != 500 {
+		t.Fatalf("Stats() = %+v, balance %d; want 50 events, 500 tokens", stats, wallet.Balance())
+	}
+	if stats.Elapsed != 5*time.Second {
+		t.Fatalf("Elapsed = %v, want 5s", stats.Elapsed)
+	}
+	if stats.Rate != 100 {
+		t.Fatalf("Rate = %v, want 100/s", stats.Rate)
+	}
+}
+
+func TestArrivalProcessMeanRate(t *testing.T) {
+	mean := 200 * time.Millisecond
+	tests := []struct {
+		dist string
+		tol  float64 // 允许的相对误差
+	}{
+		{"poisson", 0.05},
+		{"uniform", 0.05},
+		{"mmpp", 0.15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dist, func(t *testing.T) {
+			process, err := NewArrivalProcess(tt.dist, mean, rand.New(rand.NewSource(42)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, _ := runGenerator(t, process, 1, 5000)
+
+			want := float64(time.Second) / float64(mean)
+			if math.Abs(stats.Rate-want)/want > tt.tol {
+				t.Fatalf("Rate = %.2f/s, want about %.2f/s", stats.Rate, want)
+			}
+		})
+	}
+}
+
+func TestPoissonIsDeterministicWithSeed(t *testing.T) {
+	a := &PoissonProcess{Mean: time.Second, Rand: rand.New(rand.NewSource(7))}
+	b := &PoissonProcess{Mean: time.Second, Rand: rand.New(rand.NewSource(7))}
+	for i := 0; i < 100; i++ {
+		if x, y := a.Next(), b.Next(); x != y {
+			t.Fatalf("interval %d differs: %v != %v", i, x, y)
+		}
+	}
+}
+
+func TestOnOffProcessIsBursty(t *testing.T) {
+	process := &OnOffProcess{
+		OnMean:      10 * time.Millisecond,
+		OnDuration:  time.Second,
+		OffDuration: 3 * time.Second,
+		Rand:        rand.New(rand.NewSource(1)),
+	}
+	// 突发过程的间隔变异系数应明显大于泊松过程 (CV = 1)
+	var sum, sumSq float64
+	const n = 20000
+	for i := 0; i < n; i++ {
+		d := float64(process.Next())
+		sum += d
+		sumSq += d * d
+	}
+	mean := sum / n
+	cv := math.Sqrt(sumSq/n-mean*mean) / mean
+	if cv < 2 {
+		t.Fatalf("coefficient of variation = %.2f, want > 2 for bursty traffic", cv)
+	}
+}
+
+func TestGeneratorStopsOnCancel(t *testing.T) {
+	wallet := NewWallet(0, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	g, err := wallet.StartTokenGenerator(ctx, "fixed", time.Millisecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	events := g.Stats().Events
+	time.Sleep(20 * time.Millisecond)
+	if g.Stats().Events != events {
+		t.Fatal("generator kept running after cancel")
+	}
+}
+
+func TestNewArrivalProcessRejectsUnknown(t *testing.T) {
+	if _, err := NewArrivalProcess("zipf", time.Second, nil); err == nil {
+		t.Fatal("NewArrivalProcess(zipf) succeeded, want error")
+	}
+	if _, err := NewArrivalProcess("poisson", 0, nil); err == nil {
+		t.Fatal("NewArrivalProcess with zero mean succeeded, want error")
+	}
+}
@@ -0,0 +1,97 @@
+package rajomonclient
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPriceTTL 缓存价格的默认有效期
+const DefaultPriceTTL = 5 * time.Second
+
+// PriceCache 各路由最新价格的本地缓存 (从响应的 Price 头中嗅探)
+//
+// 价格只在响应里更新，而出价低于缓存价格的请求会在本地丢弃、拿不到响应，
+// 所以缓存价格必须会过期：过期后视为未知，下一个请求照常发出，充当探测，
+// 否则一次价格尖峰之后该路由会被永久拉黑，即使网关价格早已回落
+type PriceCache struct {
+	// TTL 价格的有效期，<= 0 时永不过期
+	TTL time.Duration
+	// Clock 时间源，为空时使用系统时间
+	Clock Clock
+
+	mu     sync.RWMutex
+	prices map[string]pricePoint
+}
+
+type pricePoint struct {
+	price int64
+	at    time.Time
+}
+
+// NewPriceCache 使用默认有效期 (DefaultPriceTTL) 创建价格缓存
+func NewPriceCache() *PriceCache {
+	return &PriceCache{TTL: DefaultPriceTTL, prices: make(map[string]pricePoint)}
+}
+
+// Get 返回路由的已知价格，不知道或已过期时 ok=false
+func (c *PriceCache) Get(route string) (int64, bool) {
+	now := c.now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	p, ok := c.prices[route]
+	if !ok || c.expired(p, now) {
+		return 0, false
+	}
+	return p.price, true
+}
+
+// Set 更新路由价格，返回更新前的价格 (已过期的旧价格同样返回)
+func (c *PriceCache) Set(route string, price int64) (old int64, ok bool) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, ok := c.prices[route]
+	c.prices[route] = pricePoint{price: price, at: now}
+	return prev.price, ok
+}
+
+// Observe 从响应头中嗅探价格，返回是否读到了价格
+func (c *PriceCache) Observe(route string, header http.Header) bool {
+	priceStr := header.Get("Price")
+	if priceStr == "" {
+		return false
+	}
+	price, err := strconv.ParseInt(priceStr, 10, 64)
+	if err != nil {
+		return false
+	}
+	c.Set(route, price)
+	return true
+}
+
+// Snapshot 所有未过期的路由价格的快照
+func (c *PriceCache) Snapshot() map[string]int64 {
+	now := c.now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshot := make(map[string]int64, len(c.prices))
+	for route, p := range c.prices {
+		if !c.expired(p, now) {
+			snapshot[route] = p.price
+		}
+	}
+	return snapshot
+}
+
+func (c *PriceCache) expired(p pricePoint, now time.Time) bool {
+	return c.TTL > 0 && now.Sub(p.at) >= c.TTL
+}
+
+func (c *PriceCache) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,112 @@
+package rajomonclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+var (
+	// ErrShed 请求在本地被丢弃，没有发往网关
+	ErrShed = errors.New("rajomonclient: request shed locally")
+	// ErrEmptyWallet 钱包余额为 0，无法出价
+	ErrEmptyWallet = errors.New("rajomonclient: wallet is empty")
+)
+
+// ShedError 出价低于已知市价，请求在本地被丢弃
+type ShedError struct {
+	Route    string
+	Bid      int64
+	Price    int64
+	Refunded bool // 出价是否已退回钱包
+}
+
+func (e *ShedError) Error() string {
+	return fmt.Sprintf("rajomonclient: bid %d < known price %d for %s, shed locally", e.Bid, e.Price, e.Route)
+}
+
+// Unwrap 支持 errors.Is(err, ErrShed)
+func (e *ShedError) Unwrap() error {
+	return ErrShed
+}
+
+// Transport 自动出价的 http.RoundTripper
+//  1. 按出价策略从钱包里出价，写入 Token 头
+//  2. 出价低于该路由的缓存价格 (未过期) 时直接在本地丢弃 (返回 *ShedError)，不发请求
+//  3. 从响应的 Price 头中更新价格缓存
+type Transport struct {
+	// Base 实际发请求的 RoundTripper，为空时使用 http.DefaultTransport
+	Base http.RoundTripper
+	// Wallet 出价来源
+	Wallet *Wallet
+	// Strategy 出价策略
+	Strategy BidStrategy
+	// Prices 价格缓存，可在多个 Transport 之间共享
+	Prices *PriceCache
+	// RefundOnShed 本地丢弃时是否把出价退回钱包
+	// 默认不退：模拟 "尝试成本"，让低优先级流量在高价时自然衰减
+	RefundOnShed bool
+	// RouteFunc 价格缓存的路由 Key，为空时使用 URL.Path
+	RouteFunc func(*http.Request) string
+}
+
+// NewTransport 使用默认的 http.DefaultTransport 和独立的价格缓存创建 Transport
+func NewTransport(wallet *Wallet, strategy BidStrategy) *Transport {
+	return &Transport{Wallet: wallet, Strategy: strategy, Prices: NewPriceCache()}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	route := t.route(req)
+
+	// 1. 根据策略获取出价并扣费
+	bid, ok := t.Wallet.GetBalanceAndSpend(t.Strategy)
+	if !ok || bid == 0 {
+		closeBody(req)
+		return nil, ErrEmptyWallet
+	}
+
+	// 2. 价格检查 (本地熔断)
+	// 如果我们出的价(bid) 甚至低于 市场价，那就没必要发请求了，必挂。
+	if price, known := t.Prices.Get(route); known && bid < price {
+		if t.RefundOnShed {
+			t.Wallet.Add(bid)
+		}
+		closeBody(req)
+		return nil, &ShedError{Route: route, Bid: bid, Price: price, Refunded: t.RefundOnShed}
+	}
+
+	// 3. 附加出价 (RoundTripper 不能修改原请求，需要克隆)
+	out := req.Clone(req.Context())
+	out.Header.Set("Token", strconv.FormatInt(bid, 10))
+
+	resp, err := t.base().RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. 更新价格感知
+	t.Prices.Observe(route, resp.Header)
+	return resp, nil
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) route(req *http.Request) string {
+	if t.RouteFunc != nil {
+		return t.RouteFunc(req)
+	}
+	return req.URL.Path
+}
+
+// closeBody RoundTripper 约定：即使出错也要关闭请求体
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
@@ -0,0 +1,147 @@
+package rajomonclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newPricedServer 返回固定价格的网关替身，并记录收到的出价
+func newPricedServer(t *testing.T, price int64, bids *[]int64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bid, _ := strconv.ParseInt(r.Header.Get("Token"), 10, 64)
+		*bids = append(*bids, bid)
+		w.Header().Set("Price", strconv.FormatInt(price, 10))
+		if bid < price {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTransportAttachesBidAndCachesPrice(t *testing.T) {
+	var bids []int64
+	srv := newPricedServer(t, 7, &bids)
+
+	wallet := NewWallet(30, 100)
+	tr := NewTransport(wallet, BidStrategyFixed)
+	client := &http.Client{Transport: tr}
+
+	resp, err := client.Get(srv.URL + "/mcp/chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(bids) != 1 || bids[0] != 30 {
+		t.Fatalf("server saw bids %v, want [30]", bids)
+	}
+	if price, ok := tr.Prices.Get("/mcp/chat"); !ok || price != 7 {
+		t.Fatalf("cached price = %d, %v; want 7, true", price, ok)
+	}
+}
+
+func TestTransportShedsBelowCachedPrice(t *testing.T) {
+	for _, refund := range []bool{false, true} {
+		var bids []int64
+		srv := newPricedServer(t, 50, &bids)
+
+		wallet := NewWallet(20, 100)
+		tr := NewTransport(wallet, BidStrategyFixed)
+		tr.RefundOnShed = refund
+		tr.Prices.Set("/mcp/chat", 50)
+
+		_, err := (&http.Client{Transport: tr}).Get(srv.URL + "/mcp/chat")
+
+		var shed *ShedError
+		if !errors.As(err, &shed) || !errors.Is(err, ErrShed) {
+			t.Fatalf("refund=%v: err = %v, want *ShedError", refund, err)
+		}
+		if shed.Bid != 20 || shed.Price != 50 || shed.Refunded != refund {
+			t.Fatalf("refund=%v: shed = %+v", refund, shed)
+		}
+		if len(bids) != 0 {
+			t.Fatalf("refund=%v: request reached server with bids %v", refund, bids)
+		}
+
+		wantBalance := int64(0)
+		if refund {
+			wantBalance = 20
+		}
+		if got := wallet.Balance(); got != wantBalance {
+			t.Fatalf("refund=%v: balance = %d, want %d", refund, got, wantBalance)
+		}
+	}
+}
+
+func TestTransportEmptyWallet(t *testing.T) {
+	var bids []int64
+	srv := newPricedServer(t, 1, &bids)
+
+	tr := NewTransport(NewWallet(0, 100), BidStrategyRandom)
+	_, err := (&http.Client{Transport: tr}).Get(srv.URL + "/mcp/chat")
+	if !errors.Is(err, ErrEmptyWallet) {
+		t.Fatalf("err = %v, want ErrEmptyWallet", err)
+	}
+	if len(bids) != 0 {
+		t.Fatalf("request reached server with bids %v", bids)
+	}
+}
+
+func TestTransportProbesAfterPriceExpires(t *testing.T) {
+	var bids []int64
+	price := int64(50)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bid, _ := strconv.ParseInt(r.Header.Get("Token"), 10, 64)
+		bids = append(bids, bid)
+		w.Header().Set("Price", strconv.FormatInt(price, 10))
+		if bid < price {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	clock := newFakeClock()
+	tr := NewTransport(NewWallet(0, 100), BidStrategyFixed)
+	tr.Prices.Clock = clock
+	tr.Prices.TTL = time.Second
+	client := &http.Client{Transport: tr}
+	get := func() (*http.Response, error) {
+		tr.Wallet.Add(20) // All-in 出价，每次都出 20
+		resp, err := client.Get(srv.URL + "/mcp/chat")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	// 价格尖峰：第一个请求被网关拒绝并带回价格 50，之后在有效期内都在本地丢弃
+	if resp, err := get(); err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("first request = %v, %v; want 429", resp, err)
+	}
+	if _, err := get(); !errors.Is(err, ErrShed) {
+		t.Fatalf("request within TTL: err = %v, want ErrShed", err)
+	}
+
+	// 网关价格回落后，缓存过期的下一个请求照常发出并拿到新价格
+	price = 5
+	clock.mu.Lock()
+	clock.now = clock.now.Add(time.Second)
+	clock.mu.Unlock()
+	if resp, err := get(); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("request after TTL = %v, %v; want 200", resp, err)
+	}
+	if got, ok := tr.Prices.Get("/mcp/chat"); !ok || got != 5 {
+		t.Errorf("cached price = %d, %v; want 5, true", got, ok)
+	}
+	if len(bids) != 2 {
+		t.Errorf("server saw %d requests, want 2 (the shed one never left the client)", len(bids))
+	}
+}
@@ -0,0 +1,101 @@
+package rajomonclient
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// 定义出价策略类型
+type BidStrategy string
+
+const (
+	// 策略 A: 随机出价 (Rajomon 论文 Section 3.3 "Randomized Token Spending")
+	// 行为: 在 0 到 当前余额 之间随机选择一个值作为出价。
+	// 效果: 随价格上涨，请求被丢弃的概率线性增加。实现 "概率性负载丢弃"。
+	BidStrategyRandom BidStrategy = "random"
+
+	// 策略 B: 全额/固定出价
+	// 行为: 总是使用当前钱包里的所有余额进行出价 (All-in)。
+	// 效果: 只要余额 > 价格就一定通过。适合高优先级/VIP流量。
+	BidStrategyFixed BidStrategy = "fixed"
+)
+
+// Wallet 本地代币钱包
+type Wallet struct {
+	balance int64
+	mu      sync.Mutex
+	max     int64
+}
+
+// NewWallet 创建钱包，max 为余额上限
+func NewWallet(initial, max int64) *Wallet {
+	return &Wallet{balance: initial, max: max}
+}
+
+// Add 充值代币
+func (w *Wallet) Add(amount int64) {
+	w.add(amount)
+}
+
+// add 充值并返回实际入账的数量
+func (w *Wallet) add(amount int64) int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	before := w.balance
+	w.balance += amount
+	// 限制最大余额，防止无限囤积
+	if w.balance > w.max {
+		w.balance = w.max
+	}
+	return w.balance - before
+}
+
+// TrySpend 余额足够时扣除指定数量
+func (w *Wallet) TrySpend(amount int64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.balance >= amount {
+		w.balance -= amount
+		return true
+	}
+	return false
+}
+
+// Balance 当前余额
+func (w *Wallet) Balance() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.balance
+}
+
+// GetBalanceAndSpend 结合查询和扣费的原子操作
+// strategy: 出价策略
+// 返回值: 实际出价(bid), 是否成功扣费
+func (w *Wallet) GetBalanceAndSpend(strategy BidStrategy) (int64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	currentBalance := w.balance
+	if currentBalance <= 0 {
+		return 0, false
+	}
+
+	var bid int64
+
+	if strategy == BidStrategyFixed {
+		// --- 策略: 全力出价 (All-in) ---
+		// 我有多少钱，就出多少价，确保最大概率通过
+		bid = currentBalance
+	} else {
+		// --- 策略: 随机出价 (Random Uniform) ---
+		// 模拟请求的“紧迫程度”是随机的。
+		// Rajomon 论文核心: token = fastrand.Int63n(tok)
+		// 注意: Int63n 参数必须 > 0
+		bid = rand.Int63n(currentBalance) + 1
+	}
+
+	// 扣除钱包余额 (注意：这里简化为出价即扣除，真实场景可能是预扣或仅扣除实际价格)
+	// 在 Rajomon 参考代码中，是先计算 tok，然后 DeductTokens(tok)
+	w.balance -= bid
+	return bid, true
+}
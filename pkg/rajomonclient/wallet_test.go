@@ -0,0 +1,58 @@
+package rajomonclient
+
+import "testing"
+
+func TestWalletAddCapsAtMax(t *testing.T) {
+	w := NewWallet(10, 50)
+	w.Add(100)
+	if got := w.Balance(); got != 50 {
+		t.Fatalf("Balance() = %d, want 50", got)
+	}
+}
+
+func TestWalletTrySpend(t *testing.T) {
+	w := NewWallet(10, 100)
+	if !w.TrySpend(7) {
+		t.Fatal("TrySpend(7) = false, want true")
+	}
+	if w.TrySpend(7) {
+		t.Fatal("TrySpend(7) with balance 3 = true, want false")
+	}
+	if got := w.Balance(); got != 3 {
+		t.Fatalf("Balance() = %d, want 3", got)
+	}
+}
+
+func TestGetBalanceAndSpend(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy BidStrategy
+		balance  int64
+		wantOK   bool
+	}{
+		{"fixed spends everything", BidStrategyFixed, 40, true},
+		{"random spends at most balance", BidStrategyRandom, 40, true},
+		{"empty wallet", BidStrategyRandom, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWallet(tt.balance, 100)
+			bid, ok := w.GetBalanceAndSpend(tt.strategy)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if bid < 1 || bid > tt.balance {
+				t.Fatalf("bid = %d, want in [1, %d]", bid, tt.balance)
+			}
+			if tt.strategy == BidStrategyFixed && bid != tt.balance {
+				t.Fatalf("fixed bid = %d, want %d", bid, tt.balance)
+			}
+			if got := w.Balance(); got != tt.balance-bid {
+				t.Fatalf("Balance() = %d, want %d", got, tt.balance-bid)
+			}
+		})
+	}
+}